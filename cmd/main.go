@@ -1,4 +1,500 @@
 package main
 
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/scenario"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const usage = `Usage: k8s-scheduler-sim <command> [flags] <args>
+
+Commands:
+  run <scenario>                     运行场景文件描述的模拟
+  validate <scenario>                检查场景文件是否合法
+  compare <scenario> --profiles a,b  分别使用各个调度器Profile运行同一场景，并比较结果
+  fork <scenario> --at T --profiles a,b
+                                     运行到第T个Tick后分出各个调度器Profile的分支，并发运行并比较结果
+  report <result-dir>                汇总run或compare输出目录中的监控数据
+
+使用 k8s-scheduler-sim <command> -h 查看命令的参数。
+`
+
+// schedulerConfigUsage --scheduler-config参数的说明
+const schedulerConfigUsage = "KubeSchedulerConfiguration文件，定义各个调度器Profile及其插件，为空时使用场景文件中的配置"
+
+// scenarioFileName 输出目录中保存实际运行的场景的文件名
+const scenarioFileName = "scenario.yaml"
+
+// comparisonFileName compare的输出目录中保存比较结果的文件名
+const comparisonFileName = "comparison.json"
+
+// unschedulableFileName 输出目录中保存调度失败诊断报告的文件名
+const unschedulableFileName = "unschedulable.json"
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "validate":
+		err = validateCommand(os.Args[2:])
+	case "compare":
+		err = compareCommand(os.Args[2:])
+	case "fork":
+		err = forkCommand(os.Args[2:])
+	case "report":
+		err = reportCommand(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// options 各个命令共用的参数
+type options struct {
+	ticks           int
+	logLevel        string
+	output          string
+	seed            int64
+	metrics         string
+	podMetrics      bool
+	decisions       bool
+	schedulerName   string
+	schedulerConfig string
+	profiles        string
+	parallel        bool
+	snapshot        string
+	restore         string
+	at              int
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.IntVar(&opts.ticks, "ticks", 0, "模拟的总Tick数，为0时使用场景文件中的totalTick")
+	fs.StringVar(&opts.logLevel, "log-level", "info", "日志级别，可选trace、debug、info、warn、error")
+	fs.StringVar(&opts.output, "output", "", "输出目录，为空时将监控数据输出到标准输出")
+	fs.Int64Var(&opts.seed, "seed", 0, "随机数种子，不为0时以确定性模式运行，相同种子的两次运行输出相同的统计数据。"+
+		"为0时使用场景文件中的seed")
+	fs.StringVar(&opts.metrics, "metrics", "", "逗号分隔的统计数据格式，可选table、csv、jsonl、csv.gz。"+
+		"为空时，若指定了输出目录则为csv，否则为table")
+	fs.BoolVar(&opts.podMetrics, "pod-metrics", false, "输出各个Pod每个Tick的资源分配与使用情况，数据量较大")
+	return fs
+}
+
+// parseArgs 解析参数，允许参数与位置参数交替出现，返回所有的位置参数
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	positional := make([]string, 0, 1)
+	for {
+		_ = fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func requireArg(fs *flag.FlagSet, args []string, name string) (string, error) {
+	if len(args) != 1 {
+		fs.Usage()
+		return "", fmt.Errorf("%s requires exactly one argument <%s>", fs.Name(), name)
+	}
+	return args[0], nil
+}
+
+func (opts *options) apply() error {
+	level, err := logrus.ParseLevel(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(level)
+	return nil
+}
+
+func runCommand(args []string) error {
+	opts := &options{}
+	fs := newFlagSet("run", opts)
+	fs.StringVar(&opts.schedulerName, "scheduler-name", "", "所有Pod使用的调度器Profile，为空时使用场景文件中的配置")
+	fs.StringVar(&opts.schedulerConfig, "scheduler-config", "", schedulerConfigUsage)
+	fs.StringVar(&opts.snapshot, "snapshot", "", "模拟结束后将模拟器的状态保存到该快照文件")
+	fs.BoolVar(&opts.decisions, "decisions", false, "以JSON Lines格式记录每次调度尝试中各个节点的过滤与打分结果，"+
+		"写入输出目录下的"+metrics.DecisionFileName+"，没有指定输出目录时输出到标准输出")
+	fs.StringVar(&opts.restore, "restore", "", "从该快照文件恢复模拟器的状态，从保存时的下一个Tick继续运行。场景文件需要与保存时相同")
+	path, err := requireArg(fs, parseArgs(fs, args), "scenario")
+	if err != nil {
+		return err
+	}
+	if err = opts.apply(); err != nil {
+		return err
+	}
+
+	s, err := scenario.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	if opts.ticks > 0 {
+		s.TotalTick = opts.ticks
+	}
+	if opts.schedulerName != "" {
+		s.SetSchedulerName(opts.schedulerName)
+	}
+	if opts.schedulerConfig != "" {
+		s.SchedulerConfig = opts.schedulerConfig
+	}
+	if opts.seed != 0 {
+		s.Seed = opts.seed
+	}
+	return runScenario(s, opts)
+}
+
+// runScenario 运行场景。若指定了输出目录，则在目录中保存实际运行的场景，并将统计数据写入目录下的文件。
+func runScenario(s *scenario.Scenario, opts *options) error {
+	if opts.output != "" {
+		if err := os.MkdirAll(opts.output, 0755); err != nil {
+			return errors.Wrap(err, "error creating output directory")
+		}
+		data, err := yaml.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(opts.output, scenarioFileName), data, 0644); err != nil {
+			return errors.Wrap(err, "error saving scenario")
+		}
+	}
+
+	sinks, err := newSinks(opts, opts.output)
+	if err != nil {
+		return err
+	}
+	sim, err := scenario.Build(s)
+	if err != nil {
+		return err
+	}
+	sim.SetMetricsSinks(sinks.Metrics...)
+	sim.SetClusterMetricsSinks(sinks.Cluster...)
+	sim.SetPodMetricsSinks(sinks.Pods...)
+	if opts.decisions {
+		path := ""
+		if opts.output != "" {
+			path = filepath.Join(opts.output, metrics.DecisionFileName)
+		}
+		decisionSink, err := metrics.NewDecisionSink(path)
+		if err != nil {
+			return err
+		}
+		sim.SetDecisionSinks(decisionSink)
+	}
+	if opts.restore != "" {
+		snapshot, err := core.LoadSnapshot(opts.restore)
+		if err != nil {
+			return err
+		}
+		if err = sim.Restore(snapshot); err != nil {
+			return errors.Wrap(err, "error restoring snapshot")
+		}
+		logrus.Infof("Restored snapshot %s at tick %d", opts.restore, snapshot.Tick)
+	}
+	logrus.Infof("Running scenario %s for %d ticks", s.Name, s.TotalTick)
+	sim.Run()
+	if opts.snapshot != "" {
+		snapshot, err := sim.Snapshot()
+		if err != nil {
+			return errors.Wrap(err, "error taking snapshot")
+		}
+		if err = core.SaveSnapshot(snapshot, opts.snapshot); err != nil {
+			return err
+		}
+	}
+
+	records := sim.GetPodRecords()
+	if err = savePodRecords(opts, opts.output, records); err != nil {
+		return err
+	}
+	if err = metrics.WritePodSummary(os.Stdout, metrics.SummarizePods(records)); err != nil {
+		return err
+	}
+	report := sim.DiagnoseUnschedulable()
+	if opts.output != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(opts.output, unschedulableFileName), data, 0644); err != nil {
+			return errors.Wrap(err, "error saving unschedulable report")
+		}
+	}
+	return metrics.WriteUnschedulableReport(os.Stdout, report)
+}
+
+// sinkFormats 返回统计数据的输出格式。没有指定时，若指定了输出目录则为csv，否则为table
+func sinkFormats(opts *options) []string {
+	formats := opts.metrics
+	if formats == "" {
+		formats = metrics.SinkTable
+		if opts.output != "" {
+			formats = metrics.SinkCSV
+		}
+	}
+	return strings.Split(formats, ",")
+}
+
+// newSinks 按照各个格式构造统计数据的输出，dir为空时输出到标准输出
+func newSinks(opts *options, dir string) (*scenario.Sinks, error) {
+	sinks := &scenario.Sinks{}
+	for _, format := range sinkFormats(opts) {
+		path, clusterPath, podPath := "", "", ""
+		if dir != "" {
+			path = filepath.Join(dir, metrics.SinkFileName(format))
+			clusterPath = filepath.Join(dir, metrics.ClusterSinkFileName(format))
+			podPath = filepath.Join(dir, metrics.PodSinkFileName(format))
+		}
+		sink, err := metrics.NewSink(format, path)
+		if err != nil {
+			return nil, err
+		}
+		sinks.Metrics = append(sinks.Metrics, sink)
+		clusterSink, err := metrics.NewClusterSink(format, clusterPath)
+		if err != nil {
+			return nil, err
+		}
+		sinks.Cluster = append(sinks.Cluster, clusterSink)
+		if opts.podMetrics {
+			podSink, err := metrics.NewPodSink(format, podPath)
+			if err != nil {
+				return nil, err
+			}
+			sinks.Pods = append(sinks.Pods, podSink)
+		}
+	}
+	return sinks, nil
+}
+
+// savePodRecords 将Pod调度记录按照各个格式保存到dir目录下，dir为空时不保存
+func savePodRecords(opts *options, dir string, records []*metrics.PodRecord) error {
+	if dir == "" {
+		return nil
+	}
+	for _, format := range sinkFormats(opts) {
+		path := filepath.Join(dir, metrics.PodRecordFileName(format))
+		if err := metrics.SavePodRecords(format, path, records); err != nil {
+			return errors.Wrap(err, "error saving pod records")
+		}
+	}
+	return nil
+}
+
+func validateCommand(args []string) error {
+	opts := &options{}
+	fs := newFlagSet("validate", opts)
+	path, err := requireArg(fs, parseArgs(fs, args), "scenario")
+	if err != nil {
+		return err
+	}
+
+	s, err := scenario.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Scenario %s is valid: %d ticks, %d node pools, %d workloads\n", s.Name, s.TotalTick,
+		len(s.NodePools), len(s.Workloads))
+	return nil
+}
+
+// compareCommand 使用相同的种子在同一个进程中分别以各个Profile运行场景，并输出逐项比较的结果
+func compareCommand(args []string) error {
+	opts := &options{}
+	fs := newFlagSet("compare", opts)
+	fs.StringVar(&opts.profiles, "profiles", "", "逗号分隔的调度器Profile名称，第一个为比较的基线")
+	fs.StringVar(&opts.schedulerConfig, "scheduler-config", "", schedulerConfigUsage)
+	fs.BoolVar(&opts.parallel, "parallel", false, "并发运行各个Profile，结果与顺序运行相同")
+	path, err := requireArg(fs, parseArgs(fs, args), "scenario")
+	if err != nil {
+		return err
+	}
+	if opts.profiles == "" {
+		return fmt.Errorf("--profiles is required")
+	}
+	if err = opts.apply(); err != nil {
+		return err
+	}
+
+	s, err := scenario.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	if opts.ticks > 0 {
+		s.TotalTick = opts.ticks
+	}
+	if opts.seed != 0 {
+		s.Seed = opts.seed
+	}
+	if opts.schedulerConfig != "" {
+		s.SchedulerConfig = opts.schedulerConfig
+	}
+	variants := make([]*scenario.Variant, 0, 2)
+	for _, profile := range strings.Split(opts.profiles, ",") {
+		profile = strings.TrimSpace(profile)
+		if profile == "" {
+			continue
+		}
+		variants = append(variants, &scenario.Variant{Name: profile, SchedulerName: profile})
+	}
+
+	compareOpts := &scenario.CompareOptions{Parallel: opts.parallel}
+	if opts.output != "" {
+		compareOpts.Sinks = func(v *scenario.Variant) (*scenario.Sinks, error) {
+			dir := filepath.Join(opts.output, v.Name)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, errors.Wrap(err, "error creating output directory")
+			}
+			return newSinks(opts, dir)
+		}
+	}
+	result, err := scenario.Compare(s, variants, compareOpts)
+	if err != nil {
+		return err
+	}
+
+	if opts.output != "" {
+		// 保存使用实际种子的场景，以便复现本次比较
+		s.Seed = result.Seed
+		if err = saveComparison(opts, s, result); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Seed %d\n", result.Seed)
+	return metrics.WriteComparison(os.Stdout, result.Comparison)
+}
+
+// saveComparison 在输出目录中保存场景与比较结果，并在各个Profile的目录中保存Pod调度记录
+func saveComparison(opts *options, s *scenario.Scenario, result *scenario.ComparisonResult) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(opts.output, scenarioFileName), data, 0644); err != nil {
+		return errors.Wrap(err, "error saving scenario")
+	}
+	data, err = json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(opts.output, comparisonFileName), data, 0644); err != nil {
+		return errors.Wrap(err, "error saving comparison")
+	}
+	for i, sim := range result.Simulators {
+		dir := filepath.Join(opts.output, result.Summaries[i].Name)
+		if err = savePodRecords(opts, dir, sim.GetPodRecords()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forkCommand 在同一个进程中运行到指定的Tick，之后从该状态分出各个Profile的分支，省去重复运行之前的Tick
+func forkCommand(args []string) error {
+	opts := &options{}
+	fs := newFlagSet("fork", opts)
+	fs.StringVar(&opts.profiles, "profiles", "", "逗号分隔的调度器Profile名称")
+	fs.StringVar(&opts.schedulerConfig, "scheduler-config", "", schedulerConfigUsage)
+	fs.IntVar(&opts.at, "at", 0, "分出分支的Tick")
+	path, err := requireArg(fs, parseArgs(fs, args), "scenario")
+	if err != nil {
+		return err
+	}
+	if opts.profiles == "" {
+		return fmt.Errorf("--profiles is required")
+	}
+	if err = opts.apply(); err != nil {
+		return err
+	}
+
+	s, err := scenario.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	if opts.ticks > 0 {
+		s.TotalTick = opts.ticks
+	}
+	if opts.seed != 0 {
+		s.Seed = opts.seed
+	}
+	if opts.schedulerConfig != "" {
+		s.SchedulerConfig = opts.schedulerConfig
+	}
+	if opts.at <= 0 || opts.at >= s.TotalTick {
+		return fmt.Errorf("--at should be in (0, %d)", s.TotalTick)
+	}
+
+	branches := make([]*core.Branch, 0, 2)
+	for _, profile := range strings.Split(opts.profiles, ",") {
+		profile = strings.TrimSpace(profile)
+		if profile == "" {
+			continue
+		}
+		branches = append(branches, &core.Branch{
+			Name:          profile,
+			TotalTick:     s.TotalTick,
+			SchedulerName: profile,
+			Setup:         scenario.BranchSetup(s),
+		})
+	}
+
+	warmUp := *s
+	warmUp.TotalTick = opts.at
+	sim, err := scenario.Build(&warmUp)
+	if err != nil {
+		return err
+	}
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	logrus.Infof("Running scenario %s for %d ticks before forking", s.Name, opts.at)
+	sim.Run()
+
+	results, err := sim.Fork(branches...)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+		fmt.Printf("Branch %s: CPU usage %.4f, Mem usage %.4f\n", result.Name, result.Cluster.CpuUsageAverage,
+			result.Cluster.MemUsageAverage)
+		if err = metrics.WritePodSummary(os.Stdout, result.Pods); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reportCommand(args []string) error {
+	opts := &options{}
+	fs := newFlagSet("report", opts)
+	dir, err := requireArg(fs, parseArgs(fs, args), "result-dir")
+	if err != nil {
+		return err
+	}
+	return report(dir, os.Stdout)
 }
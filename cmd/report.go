@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// runSummary 一次运行的汇总数据，使用各个节点每个Tick的使用率计算平均值
+type runSummary struct {
+	name    string
+	ticks   map[int]bool
+	nodes   map[string]bool
+	samples int
+	cpuSum  float64
+	memSum  float64
+	loadSum float64
+
+	// 集群统计数据，没有集群统计数据文件时clusterTicks为0
+	clusterTicks  int
+	cpuGapSum     float64
+	memGapSum     float64
+	succeededPods int
+}
+
+func (s *runSummary) add(m *metrics.NodeMetrics) {
+	s.ticks[m.Tick] = true
+	s.nodes[m.Node] = true
+	s.samples++
+	s.cpuSum += m.CpuUsageLastTick
+	s.memSum += m.MemUsageLastTick
+	s.loadSum += m.LoadLastTick
+}
+
+func (s *runSummary) addCluster(m *metrics.ClusterMetrics) {
+	s.clusterTicks++
+	s.cpuGapSum += m.CpuAllocationGapLastTick
+	s.memGapSum += m.MemAllocationGapLastTick
+	s.succeededPods = m.SucceededPods
+}
+
+// reportFormats report能够读取的统计数据格式，按优先级排列
+var reportFormats = []string{metrics.SinkCSV, metrics.SinkGzipCSV, metrics.SinkJSONLines}
+
+// report 查找dir目录下所有运行的统计数据，并输出汇总表格
+func report(dir string, w io.Writer) error {
+	summaries := make([]*runSummary, 0, 4)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		for _, format := range reportFormats {
+			file := filepath.Join(path, metrics.SinkFileName(format))
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if name == "." {
+				name = filepath.Base(filepath.Clean(dir))
+			}
+			summary, err := summarize(name, file, format)
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("error reading %s", file))
+			}
+			clusterFile := filepath.Join(path, metrics.ClusterSinkFileName(format))
+			if _, err := os.Stat(clusterFile); err == nil {
+				if err = summarizeCluster(summary, clusterFile, format); err != nil {
+					return errors.Wrap(err, fmt.Sprintf("error reading %s", clusterFile))
+				}
+			}
+			summaries = append(summaries, summary)
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		return fmt.Errorf("no metrics found in %s", dir)
+	}
+
+	_, _ = fmt.Fprintln(w, "Run                 \tTicks\tNodes\tCPU  \tMem  \tLoad \tCPUGap\tMemGap\tSucceeded")
+	for _, s := range summaries {
+		count := float64(s.samples)
+		if count == 0 {
+			count = 1
+		}
+		_, _ = fmt.Fprintf(w, "%-20s\t%d\t%d\t%.3f\t%.3f\t%.3f", s.name, len(s.ticks), len(s.nodes),
+			s.cpuSum/count, s.memSum/count, s.loadSum/count)
+		if s.clusterTicks == 0 {
+			_, _ = fmt.Fprintln(w, "\t-     \t-     \t-")
+		} else {
+			clusterTicks := float64(s.clusterTicks)
+			_, _ = fmt.Fprintf(w, "\t%.3f\t%.3f\t%d\n", s.cpuGapSum/clusterTicks, s.memGapSum/clusterTicks, s.succeededPods)
+		}
+	}
+	return nil
+}
+
+func summarize(name, path, format string) (*runSummary, error) {
+	summary := &runSummary{name: name, ticks: make(map[int]bool), nodes: make(map[string]bool)}
+	err := readMetrics(path, format,
+		func(line []byte) error {
+			m := &metrics.NodeMetrics{}
+			if err := json.Unmarshal(line, m); err != nil {
+				return err
+			}
+			if m.PeriodMetrics != nil {
+				summary.add(m)
+			}
+			return nil
+		},
+		[]string{"tick", "node", "cpuUsageLastTick", "memUsageLastTick", "loadLastTick"},
+		func(record map[string]string) error {
+			tick, err := strconv.Atoi(record["tick"])
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, "cpuUsageLastTick", "memUsageLastTick", "loadLastTick")
+			if err != nil {
+				return err
+			}
+			summary.add(&metrics.NodeMetrics{
+				Tick: tick,
+				Node: record["node"],
+				PeriodMetrics: &metrics.PeriodMetrics{
+					CpuUsageLastTick: values[0],
+					MemUsageLastTick: values[1],
+					LoadLastTick:     values[2],
+				},
+			})
+			return nil
+		})
+	return summary, err
+}
+
+func summarizeCluster(summary *runSummary, path, format string) error {
+	return readMetrics(path, format,
+		func(line []byte) error {
+			m := &metrics.ClusterMetrics{}
+			if err := json.Unmarshal(line, m); err != nil {
+				return err
+			}
+			if m.ClusterTickMetrics != nil && m.ClusterPeriodMetrics != nil {
+				summary.addCluster(m)
+			}
+			return nil
+		},
+		[]string{"succeededPods", "cpuAllocationGapLastTick", "memAllocationGapLastTick"},
+		func(record map[string]string) error {
+			succeeded, err := strconv.Atoi(record["succeededPods"])
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, "cpuAllocationGapLastTick", "memAllocationGapLastTick")
+			if err != nil {
+				return err
+			}
+			summary.addCluster(&metrics.ClusterMetrics{
+				ClusterTickMetrics: &metrics.ClusterTickMetrics{SucceededPods: succeeded},
+				ClusterPeriodMetrics: &metrics.ClusterPeriodMetrics{
+					CpuAllocationGapLastTick: values[0],
+					MemAllocationGapLastTick: values[1],
+				},
+			})
+			return nil
+		})
+}
+
+// readMetrics 按格式读取统计数据文件。JSON Lines的每一行传给handleLine，CSV的每一行转换为列名到值的映射后传给
+// handleRecord，CSV必须包含columns中的所有列
+func readMetrics(path, format string, handleLine func(line []byte) error, columns []string,
+	handleRecord func(record map[string]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format {
+	case metrics.SinkJSONLines:
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if err = handleLine(scanner.Bytes()); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	case metrics.SinkGzipCSV:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return readCSV(gz, columns, handleRecord)
+	default:
+		return readCSV(file, columns, handleRecord)
+	}
+}
+
+func readCSV(r io.Reader, columns []string, handleRecord func(record map[string]string) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range columns {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("no column %s", name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		values := make(map[string]string, len(header))
+		for name, i := range index {
+			values[name] = record[i]
+		}
+		if err = handleRecord(values); err != nil {
+			return err
+		}
+	}
+}
+
+func parseFloats(record map[string]string, names ...string) ([]float64, error) {
+	values := make([]float64, len(names))
+	for i, name := range names {
+		value, err := strconv.ParseFloat(record[name], 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -11,6 +11,7 @@ require (
 	k8s.io/apimachinery v0.0.0
 	k8s.io/client-go v0.0.0
 	k8s.io/kubernetes v1.18.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -3,14 +3,20 @@ package controllers
 
 import (
 	"container/heap"
+	"encoding/json"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
 	"github.com/sirupsen/logrus"
 )
 
-// ControllerDeployer deploys controller at given tick. This controller will regard first Tick() call as tick 0. And it
-// will deploy a controller at tick T if called DeployAt() with arguments (controller, T).
+// ControllerDeployer deploys controller at given tick. This controller will regard the tick of first Tick() call as tick 0,
+// and reads the current tick from the simulated clock, so ticks skipped in event driven mode are counted. It will deploy
+// a controller at tick T if called DeployAt() with arguments (controller, T).
+//
+// When restoring a snapshot, the deployer registers again the controllers it had deployed, so the same controllers must be
+// passed to DeployAt() before restoring.
 type ControllerDeployer interface {
-	core.Controller
+	core.WakeUpController
+	core.StatefulController
 
 	// DeployAt deploy a controller to the cluster at given tick. If current tick is larger than tick count, this method
 	// simply ignore the request.
@@ -60,19 +66,33 @@ func NewControllerDeployer(sim core.SchedulerSimulator) ControllerDeployer {
 }
 
 type controllerDeployer struct {
-	sim   core.SchedulerSimulator
-	tick  int
-	queue *priorityQueue
+	sim  core.SchedulerSimulator
+	tick int
+	// start 第一次调用Tick时模拟时钟的Tick
+	start   int
+	started bool
+	queue   *priorityQueue
 }
 
+// DeployerName 部署器的名称，用于在快照中保存其状态
+const DeployerName = "controller-deployer"
+
 func (c *controllerDeployer) Name() string {
-	// 暂时没有名字
-	return ""
+	return DeployerName
 }
 
 func (c *controllerDeployer) Tick() {
-	c.tick++
+	now := c.sim.GetClock().CurrentTick()
+	if !c.started {
+		c.started = true
+		c.start = now
+	}
+	c.tick = now - c.start + 1
+	c.deploy()
+}
 
+// deploy 注册所有到期的控制器
+func (c *controllerDeployer) deploy() {
 	for c.queue.Len() > 0 && (*c.queue)[0].tick <= c.tick {
 		item := heap.Pop(c.queue)
 		timer := item.(*controllerTimer)
@@ -86,6 +106,34 @@ func (c *controllerDeployer) Tick() {
 	}
 }
 
+type deployerState struct {
+	Tick    int  `json:"tick"`
+	Start   int  `json:"start"`
+	Started bool `json:"started"`
+}
+
+func (c *controllerDeployer) SaveState() ([]byte, error) {
+	return json.Marshal(&deployerState{Tick: c.tick, Start: c.start, Started: c.started})
+}
+
+// RestoreState 恢复状态，并重新注册保存快照之前已经部署的控制器
+func (c *controllerDeployer) RestoreState(state []byte) error {
+	saved := &deployerState{}
+	if err := json.Unmarshal(state, saved); err != nil {
+		return err
+	}
+	c.tick, c.start, c.started = saved.Tick, saved.Start, saved.Started
+	c.deploy()
+	return nil
+}
+
+func (c *controllerDeployer) NextWakeUp(tick int) int {
+	if c.queue.Len() == 0 {
+		return -1
+	}
+	return c.start + (*c.queue)[0].tick - 1
+}
+
 type DeployTime string
 
 var (
@@ -2,14 +2,31 @@ package controllers
 
 import (
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"math/rand"
 	"testing"
 	"time"
 )
 
 type deployerTestSimulator struct {
-	ch chan string
+	ch    chan string
+	clock *deployerTestClock
+}
+
+type deployerTestClock struct {
+	*clock.FakeClock
+	tick int
+}
+
+func (c *deployerTestClock) CurrentTick() int {
+	return c.tick
+}
+
+func (c *deployerTestClock) TickDuration() time.Duration {
+	return time.Second
 }
 
 var _ core.SchedulerSimulator = &deployerTestSimulator{}
@@ -18,6 +35,97 @@ func (f *deployerTestSimulator) GetPod(name string) (*core.Pod, error) {
 	panic("implement me")
 }
 
+func (f *deployerTestSimulator) SetMetricsSinks(sinks ...metrics.MetricsSink) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetClusterMetricsSinks(sinks ...metrics.ClusterMetricsSink) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetPodMetricsSinks(sinks ...metrics.PodMetricsSink) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetDecisionSinks(sinks ...metrics.DecisionSink) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetDeterministic(seed int64) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetSchedulingBudget(budget int) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetSchedulingLatency(latency core.SchedulingLatency) {
+}
+
+func (f *deployerTestSimulator) SetTickDuration(duration time.Duration) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetEventDriven(enabled bool) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) SetParallelNodeUpdate(enabled bool) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Snapshot() (*core.Snapshot, error) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Restore(snapshot *core.Snapshot) error {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Fork(branches ...*core.Branch) ([]*core.BranchResult, error) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Step(n int) int {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) RunUntil(predicate func(sim core.SchedulerSimulator) bool) bool {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Pause() {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Stop() {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) Finished() bool {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) AddStopCondition(conditions ...core.StopCondition) {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) GetClock() core.Clock {
+	return f.clock
+}
+
+func (f *deployerTestSimulator) GetRand() *rand.Rand {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) GetPodRecords() []*metrics.PodRecord {
+	panic("implement me")
+}
+
+func (f *deployerTestSimulator) DiagnoseUnschedulable() *metrics.UnschedulableReport {
+	panic("implement me")
+}
+
 func (f *deployerTestSimulator) GetKubernetesClient() kubernetes.Interface {
 	panic("implement me")
 }
@@ -58,7 +166,7 @@ func (f *fakeController) Tick() {
 }
 
 func TestDeployer(t *testing.T) {
-	sim := &deployerTestSimulator{ch: make(chan string, 1)}
+	sim := &deployerTestSimulator{ch: make(chan string, 1), clock: &deployerTestClock{FakeClock: clock.NewFakeClock(time.Now())}}
 
 	deployer := NewControllerDeployer(sim)
 
@@ -68,6 +176,7 @@ func TestDeployer(t *testing.T) {
 
 	for i := 0; i < 10; i++ {
 		deployer.Tick()
+		sim.clock.tick++
 	}
 
 	select {
@@ -79,9 +188,13 @@ func TestDeployer(t *testing.T) {
 		t.Error("error")
 	}
 
-	for i := 0; i < 90; i++ {
-		deployer.Tick()
+	if next := deployer.NextWakeUp(9); next != 99 {
+		t.Errorf("should wake up at 99, not %d", next)
 	}
+
+	// 跳过的Tick也被计算在内
+	sim.clock.tick = 99
+	deployer.Tick()
 	select {
 	case s := <-sim.ch:
 		if s != "after" {
@@ -91,3 +204,38 @@ func TestDeployer(t *testing.T) {
 		t.Error("error")
 	}
 }
+
+func TestDeployerRestore(t *testing.T) {
+	sim := &deployerTestSimulator{ch: make(chan string, 1), clock: &deployerTestClock{FakeClock: clock.NewFakeClock(time.Now())}}
+	deployer := NewControllerDeployer(sim)
+	deployer.DeployAt(&fakeController{}, 10, BeforeUpdate)
+	deployer.DeployAt(&fakeController{}, 100, AfterUpdate)
+	for i := 0; i < 10; i++ {
+		deployer.Tick()
+		sim.clock.tick++
+	}
+	<-sim.ch
+	state, err := deployer.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复时重新部署已经部署的控制器
+	restored := NewControllerDeployer(sim)
+	restored.DeployAt(&fakeController{}, 10, BeforeUpdate)
+	restored.DeployAt(&fakeController{}, 100, AfterUpdate)
+	if err = restored.RestoreState(state); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-sim.ch:
+		if s != "before" {
+			t.Errorf("should deploy the before controller, not %s", s)
+		}
+	default:
+		t.Error("deployed controller should be registered again")
+	}
+	if next := restored.NextWakeUp(9); next != 99 {
+		t.Errorf("should wake up at 99, not %d", next)
+	}
+}
@@ -2,12 +2,13 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
-	"time"
+	"sort"
 )
 
 func NewReplicationController(sim core.SchedulerSimulator, controllerName string, replicaNum int, podFactory func() *v1.Pod) ReplicationController {
@@ -16,6 +17,7 @@ func NewReplicationController(sim core.SchedulerSimulator, controllerName string
 		name:       controllerName,
 		sim:        sim,
 		replicas:   replicas,
+		stopping:   make(map[string]bool),
 		podFactory: podFactory,
 		replicaNum: replicaNum,
 		state:      initializing,
@@ -27,7 +29,7 @@ func NewReplicationController(sim core.SchedulerSimulator, controllerName string
 const LabelReplicationController = "github.com/packagewjx/replicationcontroller"
 
 type ReplicationController interface {
-	core.Controller
+	core.StatefulController
 	SetReplicaNum(num int)
 	Terminate()
 }
@@ -66,42 +68,14 @@ func (r *replicationController) Name() string {
 func (r *replicationController) Tick() {
 	switch r.state {
 	case initializing:
-		// 注册监听器
-		r.sim.GetInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				pod := obj.(*v1.Pod)
-				logrus.Debugf("ReplicationController %s: Pod %s added successfully", r.name, pod.Name)
-				if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
-					r.replicas[pod.Name] = pod
-				}
-			},
-			UpdateFunc: func(_, newObj interface{}) {
-				pod := newObj.(*v1.Pod)
-				logrus.Debugf("ReplicationController %s: Pod %s updated", r.name, pod.Name)
-				if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
-					r.replicas[pod.Name] = pod
-				}
-			},
-			DeleteFunc: func(obj interface{}) {
-				pod := obj.(*v1.Pod)
-				logrus.Debugf("ReplicationController %s: Pod %s deleted", r.name, pod.Name)
-				if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
-					delete(r.replicas, pod.Name)
-					if r.stopping[pod.Name] {
-						delete(r.stopping, pod.Name)
-					}
-				}
-			},
-		})
-		// Ensure handler has successfully created
-		<-time.After(100 * time.Millisecond)
+		r.watch()
 		r.state = running
 	case terminated:
 		// r.sim.DeleteBeforeController(r)
 		return
 	case terminating:
 		logrus.Infof("ReplicationController %s: now entering terminating state.", r.name)
-		for podName, _ := range r.replicas {
+		for _, podName := range r.sortedReplicaNames() {
 			logrus.Infof("ReplicationController %s: Terminating pod %s", r.name, podName)
 			err := r.sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).Delete(context.TODO(), podName, metav1.DeleteOptions{})
 			if err != nil {
@@ -112,7 +86,8 @@ func (r *replicationController) Tick() {
 	case waitForPodTerminate:
 		logrus.Infof("ReplicationController %s: waiting for pod termination.", r.name)
 		terminatedPod := make([]*v1.Pod, 0, 10)
-		for _, pod := range r.replicas {
+		for _, podName := range r.sortedReplicaNames() {
+			pod := r.replicas[podName]
 			if pod.Status.Phase == v1.PodFailed || pod.Status.Phase == v1.PodSucceeded {
 				logrus.Infof("ReplicationController %s: Pod %s terminated", r.name, pod.Name)
 				terminatedPod = append(terminatedPod, pod)
@@ -135,7 +110,8 @@ func (r *replicationController) Tick() {
 	case running:
 		terminated := make([]*v1.Pod, 0, 10)
 		// 首先去除已经Terminated的
-		for _, pod := range r.replicas {
+		for _, podName := range r.sortedReplicaNames() {
+			pod := r.replicas[podName]
 			if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
 				logrus.Infof("ReplicationController %s: Pod %s terminated", r.name, pod.Name)
 				terminated = append(terminated, pod)
@@ -148,14 +124,17 @@ func (r *replicationController) Tick() {
 		if len(r.replicas) > r.replicaNum {
 			if len(r.stopping) == 0 {
 				logrus.Infof("ReplicationController %s: Pod replica is larger than expected replica number, terminating pods.", r.name)
-				// 若stopping没有Pod时才停止，否则等待。随机选择几个停止。
-				for _, pod := range r.replicas {
-					logrus.Infof("ReplicationController %s: Terminating pod %s", r.name, pod.Name)
-					err := r.sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+				// 若stopping没有Pod时才停止，否则等待。使用模拟器的随机数生成器随机选择多出的Pod停止。
+				names := r.sortedReplicaNames()
+				perm := r.sim.GetRand().Perm(len(names))
+				for _, i := range perm[:len(names)-r.replicaNum] {
+					podName := names[i]
+					logrus.Infof("ReplicationController %s: Terminating pod %s", r.name, podName)
+					err := r.sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).Delete(context.TODO(), podName, metav1.DeleteOptions{})
 					if err != nil {
-						logrus.Errorf("ReplicationController %s: error deleting pod %s: %v", r.name, pod.Name, err)
+						logrus.Errorf("ReplicationController %s: error deleting pod %s: %v", r.name, podName, err)
 					}
-					r.stopping[pod.Name] = true
+					r.stopping[podName] = true
 				}
 			}
 		} else if len(r.replicas) < r.replicaNum {
@@ -183,6 +162,91 @@ func (r *replicationController) Tick() {
 	}
 }
 
+// watch 注册监听器，并接管集群中已经存在的本控制器的Pod，如从快照恢复的Pod
+func (r *replicationController) watch() {
+	r.sim.GetInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod := obj.(*v1.Pod)
+			logrus.Debugf("ReplicationController %s: Pod %s added successfully", r.name, pod.Name)
+			if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
+				r.replicas[pod.Name] = pod
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			pod := newObj.(*v1.Pod)
+			logrus.Debugf("ReplicationController %s: Pod %s updated", r.name, pod.Name)
+			if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
+				r.replicas[pod.Name] = pod
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			pod := obj.(*v1.Pod)
+			logrus.Debugf("ReplicationController %s: Pod %s deleted", r.name, pod.Name)
+			if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
+				delete(r.replicas, pod.Name)
+				if r.stopping[pod.Name] {
+					delete(r.stopping, pod.Name)
+				}
+			}
+		},
+	})
+
+	list, err := r.sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("ReplicationController %s: error listing pods: %v", r.name, err)
+		return
+	}
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if pod.Labels != nil && pod.Labels[LabelReplicationController] == r.name {
+			r.replicas[pod.Name] = pod
+		}
+	}
+}
+
+type replicationState struct {
+	State      controllerState `json:"state"`
+	ReplicaNum int             `json:"replicaNum"`
+	Stopping   []string        `json:"stopping,omitempty"`
+}
+
+func (r *replicationController) SaveState() ([]byte, error) {
+	stopping := make([]string, 0, len(r.stopping))
+	for name := range r.stopping {
+		stopping = append(stopping, name)
+	}
+	sort.Strings(stopping)
+	return json.Marshal(&replicationState{State: r.state, ReplicaNum: r.replicaNum, Stopping: stopping})
+}
+
+// RestoreState 恢复状态。若保存时已经完成初始化，则重新注册监听器并接管恢复的Pod
+func (r *replicationController) RestoreState(state []byte) error {
+	saved := &replicationState{}
+	if err := json.Unmarshal(state, saved); err != nil {
+		return err
+	}
+	r.replicaNum = saved.ReplicaNum
+	r.stopping = make(map[string]bool)
+	for _, name := range saved.Stopping {
+		r.stopping[name] = true
+	}
+	if saved.State != initializing && r.state == initializing {
+		r.watch()
+	}
+	r.state = saved.State
+	return nil
+}
+
+// sortedReplicaNames 按照名称排序的副本，保证每次运行时的处理顺序相同
+func (r *replicationController) sortedReplicaNames() []string {
+	names := make([]string, 0, len(r.replicas))
+	for name := range r.replicas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (r *replicationController) SetReplicaNum(num int) {
 	r.replicaNum = num
 }
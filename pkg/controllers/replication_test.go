@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/informers"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/util/fake"
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
@@ -12,16 +14,18 @@ import (
 	k8sinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"math/rand"
 	"testing"
 	"time"
 )
 
 func TestReplication(t *testing.T) {
 	logrus.SetLevel(logrus.TraceLevel)
-	client := fake.NewFakeKubernetesInterface()
+	queue := util.NewSynchronizedMessageQueue()
+	client := fake.NewFakeKubernetesInterface(queue)
 	sim := &replicationTestSimulator{
 		client:  client,
-		factory: informers.NewSharedInformerFactory(client),
+		factory: informers.NewSharedInformerFactory(client, queue),
 	}
 	stopCh := make(chan struct{})
 	defer func() {
@@ -107,6 +111,97 @@ func (f *replicationTestSimulator) GetPod(name string) (*core.Pod, error) {
 	panic("implement me")
 }
 
+func (f *replicationTestSimulator) SetMetricsSinks(sinks ...metrics.MetricsSink) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetClusterMetricsSinks(sinks ...metrics.ClusterMetricsSink) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetPodMetricsSinks(sinks ...metrics.PodMetricsSink) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetDecisionSinks(sinks ...metrics.DecisionSink) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetDeterministic(seed int64) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetSchedulingBudget(budget int) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetSchedulingLatency(latency core.SchedulingLatency) {
+}
+
+func (f *replicationTestSimulator) SetTickDuration(duration time.Duration) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetEventDriven(enabled bool) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) SetParallelNodeUpdate(enabled bool) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Snapshot() (*core.Snapshot, error) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Restore(snapshot *core.Snapshot) error {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Fork(branches ...*core.Branch) ([]*core.BranchResult, error) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Step(n int) int {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) RunUntil(predicate func(sim core.SchedulerSimulator) bool) bool {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Pause() {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Stop() {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) Finished() bool {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) AddStopCondition(conditions ...core.StopCondition) {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) GetClock() core.Clock {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) GetRand() *rand.Rand {
+	return rand.New(rand.NewSource(1))
+}
+
+func (f *replicationTestSimulator) GetPodRecords() []*metrics.PodRecord {
+	panic("implement me")
+}
+
+func (f *replicationTestSimulator) DiagnoseUnschedulable() *metrics.UnschedulableReport {
+	panic("implement me")
+}
+
 func (f *replicationTestSimulator) GetKubernetesClient() kubernetes.Interface {
 	return f.client
 }
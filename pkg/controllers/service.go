@@ -1,14 +1,17 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 	"math"
+	"sort"
+	"sync"
 )
 
 const LabelService = "service"
@@ -36,7 +39,7 @@ func NewServiceController(sim core.SchedulerSimulator, name string, podNum int,
 	podTemplate.Labels[LabelService] = name
 
 	rc := NewReplicationController(sim, fmt.Sprintf("replication-controller-%s", name), podNum, func() *v1.Pod {
-		uid := uuid.NewUUID()
+		uid := core.NewUID(sim.GetRand())
 		pod := podTemplate.DeepCopy()
 
 		pod.Name = fmt.Sprintf("service_pod_%s_%s", name, uid)
@@ -77,6 +80,8 @@ type serviceController struct {
 	queue []*pods.ServiceContext
 	// met 用于统计
 	met *serviceMetrics
+	// metLock 请求完成的回调在节点的Tick中调用，并行更新节点时需要上锁
+	metLock sync.Mutex
 }
 
 func (c *serviceController) Name() string {
@@ -113,6 +118,7 @@ func (c *serviceController) Tick() {
 				}
 			},
 		})
+		c.adoptPods()
 		c.initialized = true
 		return
 	}
@@ -127,14 +133,16 @@ func (c *serviceController) Tick() {
 
 	// 仅当有Pod时分发
 	if len(c.pods) > 0 {
+		// 按照名称顺序分发，使确定性模式下每次运行的分发结果相同
+		sortedPods := c.sortedPods()
 		sumRequests := 0
-		for _, pod := range c.pods {
+		for _, pod := range sortedPods {
 			sumRequests += pod.Algorithm.(pods.ServicePod).GetRequestQueueLen()
 		}
 		avgRequest := (sumRequests + len(c.queue)) / len(c.pods)
 		// 记录c.queue的位置，应该不会超过len(c.queue)
 		idx := 0
-		for _, pod := range c.pods {
+		for _, pod := range sortedPods {
 			alg := pod.Algorithm.(pods.ServicePod)
 			for i := alg.GetRequestQueueLen(); i < avgRequest; i++ {
 				err := alg.DeliverRequest(c.queue[idx])
@@ -153,7 +161,41 @@ func (c *serviceController) Tick() {
 	c.tick++
 }
 
+// adoptPods 加入集群中已经绑定的本服务的Pod，如从快照恢复的Pod
+func (c *serviceController) adoptPods() {
+	list, err := c.sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Service %s: Error listing pods: %v", c.name, err)
+		return
+	}
+	for i := range list.Items {
+		item := &list.Items[i]
+		if item.Labels == nil || item.Labels[LabelService] != c.name || !isPodBindSuccess(item) {
+			continue
+		}
+		pod, err := c.sim.GetPod(item.Name)
+		if err != nil {
+			logrus.Errorf("Service %s: Error getting pod %s: %v", c.name, item.Name, err)
+			continue
+		}
+		c.pods[pod.Name] = pod
+	}
+}
+
+func (c *serviceController) sortedPods() []*core.Pod {
+	sortedPods := make([]*core.Pod, 0, len(c.pods))
+	for _, pod := range c.pods {
+		sortedPods = append(sortedPods, pod)
+	}
+	sort.Slice(sortedPods, func(i, j int) bool {
+		return sortedPods[i].Name < sortedPods[j].Name
+	})
+	return sortedPods
+}
+
 func (c *serviceController) onDone(requestId int) {
+	c.metLock.Lock()
+	defer c.metLock.Unlock()
 	tick, ok := c.requestTick[requestId]
 	if ok {
 		c.met.add(uint8(c.tick - tick))
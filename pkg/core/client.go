@@ -13,7 +13,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	deprecatedv1 "k8s.io/client-go/deprecated/typed/core/v1"
-	"k8s.io/client-go/deprecated/typed/scheduling/v1"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
 	admissionregistrationv1 "k8s.io/client-go/kubernetes/typed/admissionregistration/v1"
@@ -65,7 +64,7 @@ func NewClient(sim *schedSim) (kubernetes.Interface, error) {
 	topics := []string{util.TopicNode, util.TopicPod, util.TopicPriorityClass}
 
 	for _, topic := range topics {
-		err := util.GetMessageQueue().NewTopic(topic)
+		err := sim.queue.NewTopic(topic)
 		if err != nil {
 			return nil, errors.Wrap(err, "error creating topic node")
 		}
@@ -292,7 +291,7 @@ func (client *simClient) SchedulingV1beta1() schedulingv1beta1.SchedulingV1beta1
 }
 
 func (client *simClient) SchedulingV1() schedulingv1.SchedulingV1Interface {
-	panic("Using this interface is not allowed.")
+	return &schedulingV1Client{sim: client.sim}
 }
 
 func (client *simClient) SettingsV1alpha1() settingsv1alpha1.SettingsV1alpha1Interface {
@@ -397,10 +396,11 @@ func (client *coreV1NodeClient) Create(_ context.Context, node *apicorev1.Node,
 
 	// 创建CoreScheduler
 	schedulerName := node.Annotations[NodeAnnotationCoreScheduler]
-	scheduler, exist := GetCoreScheduler(schedulerName)
+	factory, exist := GetCoreSchedulerFactory(schedulerName)
 	if !exist {
 		return nil, fmt.Errorf("No CoreScheduler %s", schedulerName)
 	}
+	scheduler := factory(client.sim.clock)
 
 	numCpu, ok := node.Status.Capacity.Cpu().AsInt64()
 	if !ok || numCpu == 0 {
@@ -427,7 +427,7 @@ func (client *coreV1NodeClient) Create(_ context.Context, node *apicorev1.Node,
 		Type:   watch.Added,
 		Object: node,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicNode, ev)
+	err = client.sim.queue.Publish(util.TopicNode, ev)
 	if err != nil {
 		logrus.Errorf("Error publishing add event: %v", err)
 	}
@@ -458,7 +458,7 @@ func (client *coreV1NodeClient) Update(_ context.Context, node *apicorev1.Node,
 		Type:   watch.Modified,
 		Object: node,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicNode, ev)
+	err = client.sim.queue.Publish(util.TopicNode, ev)
 	if err != nil {
 		logrus.Errorf("error publishing update event %v", err)
 	}
@@ -489,7 +489,7 @@ func (client *coreV1NodeClient) UpdateStatus(_ context.Context, node *apicorev1.
 		Type:   watch.Modified,
 		Object: node,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicNode, ev)
+	err = client.sim.queue.Publish(util.TopicNode, ev)
 	if err != nil {
 		logrus.Errorf("error publishing update event: %v", err)
 	}
@@ -516,7 +516,7 @@ func (client *coreV1NodeClient) Delete(_ context.Context, name string, _ apimach
 		Type:   watch.Deleted,
 		Object: &item.(*Node).Node,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicNode, ev)
+	err = client.sim.queue.Publish(util.TopicNode, ev)
 	if err != nil {
 		logrus.Errorf("Error publishing delete event: %v", err)
 	}
@@ -560,7 +560,7 @@ func (client *coreV1NodeClient) List(_ context.Context, _ apimachineryv1.ListOpt
 }
 
 func (client *coreV1NodeClient) Watch(_ context.Context, _ apimachineryv1.ListOptions) (watch.Interface, error) {
-	return util.GetMessageQueue().Subscribe(util.TopicNode)
+	return client.sim.queue.Subscribe(util.TopicNode)
 }
 
 func (client *coreV1NodeClient) Patch(_ context.Context, _ string, _ types.PatchType, _ []byte, _ apimachineryv1.PatchOptions, _ ...string) (result *apicorev1.Node, err error) {
@@ -576,6 +576,11 @@ type coreV1PodClient struct {
 }
 
 func (c *coreV1PodClient) Create(_ context.Context, pod *apicorev1.Pod, _ apimachineryv1.CreateOptions) (*apicorev1.Pod, error) {
+	return c.create(pod, pod.Annotations[PodAnnotationInitialState])
+}
+
+// create 创建Pod，并使用stateString构造PodAlgorithm。恢复快照时stateString为Pod保存的运行状态
+func (c *coreV1PodClient) create(pod *apicorev1.Pod, stateString string) (*apicorev1.Pod, error) {
 	// 检查是否有重复的Pod，拒绝名称相同的Pod加入
 	podKey, _ := PodKeyFunc(pod)
 	if _, exist, _ := c.sim.Pods.GetByKey(podKey); exist {
@@ -602,7 +607,18 @@ func (c *coreV1PodClient) Create(_ context.Context, pod *apicorev1.Pod, _ apimac
 		return nil, fmt.Errorf("no pod algorithm %s", algName)
 	}
 
-	stateString, _ := pod.Annotations[PodAnnotationInitialState]
+	// 与Kubernetes的Priority准入控制器相同，根据PriorityClassName设置Pod的优先级
+	if pod.Spec.PriorityClassName != "" && pod.Spec.Priority == nil {
+		item, exist, _ := c.sim.PriorityClasses.GetByKey(pod.Spec.PriorityClassName)
+		if !exist {
+			return nil, fmt.Errorf("no PriorityClass %s", pod.Spec.PriorityClassName)
+		}
+		value := item.(*apischedulingv1.PriorityClass).Value
+		pod.Spec.Priority = &value
+	}
+	if c.sim.schedulerName != "" {
+		pod.Spec.SchedulerName = c.sim.schedulerName
+	}
 
 	clone := pod.DeepCopy()
 	simPod := &Pod{
@@ -616,6 +632,9 @@ func (c *coreV1PodClient) Create(_ context.Context, pod *apicorev1.Pod, _ apimac
 		return nil, errors.Wrap(err, "Error creating pod algorithm")
 	}
 	simPod.Algorithm = algorithm
+	if setter, ok := algorithm.(ClockSetter); ok {
+		setter.SetClock(c.sim.clock)
+	}
 
 	err = c.sim.Pods.Add(simPod)
 	if err != nil {
@@ -627,7 +646,7 @@ func (c *coreV1PodClient) Create(_ context.Context, pod *apicorev1.Pod, _ apimac
 		Type:   watch.Added,
 		Object: pod,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	err = c.sim.queue.Publish(util.TopicPod, ev)
 	if err != nil {
 		logrus.Errorf("Error publishing add event: %v", err)
 	}
@@ -658,7 +677,7 @@ func (c *coreV1PodClient) Update(_ context.Context, pod *apicorev1.Pod, _ apimac
 		Type:   watch.Modified,
 		Object: pod,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	err = c.sim.queue.Publish(util.TopicPod, ev)
 	if err != nil {
 		logrus.Errorf("Error publishing update event: %v", err)
 	}
@@ -687,7 +706,7 @@ func (c *coreV1PodClient) UpdateStatus(_ context.Context, pod *apicorev1.Pod, _
 		Type:   watch.Modified,
 		Object: pod,
 	}
-	err = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	err = c.sim.queue.Publish(util.TopicPod, ev)
 	if err != nil {
 		logrus.Errorf("Error publishing update event: %v", err)
 	}
@@ -717,7 +736,7 @@ func (c *coreV1PodClient) Delete(_ context.Context, name string, opts apimachine
 			Type:   watch.Deleted,
 			Object: &item.(*Pod).Pod,
 		}
-		err = util.GetMessageQueue().Publish(util.TopicPod, ev)
+		err = c.sim.queue.Publish(util.TopicPod, ev)
 		if err != nil {
 			logrus.Errorf("Error publishing delete event: %v", err)
 		}
@@ -780,7 +799,7 @@ func (c *coreV1PodClient) List(_ context.Context, _ apimachineryv1.ListOptions)
 }
 
 func (c *coreV1PodClient) Watch(_ context.Context, _ apimachineryv1.ListOptions) (watch.Interface, error) {
-	return util.GetMessageQueue().Subscribe(util.TopicPod)
+	return c.sim.queue.Subscribe(util.TopicPod)
 }
 
 func (c *coreV1PodClient) Patch(_ context.Context, _ string, _ types.PatchType, _ []byte, _ apimachineryv1.PatchOptions, _ ...string) (result *apicorev1.Pod, err error) {
@@ -797,6 +816,9 @@ func (c *coreV1PodClient) UpdateEphemeralContainers(_ context.Context, _ string,
 
 func (c *coreV1PodClient) Bind(_ context.Context, binding *apicorev1.Binding, _ apimachineryv1.CreateOptions) error {
 	fmt.Printf("In Client Bind GoRoutine %d\n", util.GetGoRoutineId())
+	if err := c.sim.cycle.checkBinding(binding.Name); err != nil {
+		return err
+	}
 
 	item, exists, err := c.sim.Nodes.GetByKey(binding.Target.Name)
 	if !exists {
@@ -838,23 +860,42 @@ type schedulingV1Client struct {
 	sim *schedSim
 }
 
-func (s *schedulingV1Client) Create(class *apischedulingv1.PriorityClass) (*apischedulingv1.PriorityClass, error) {
-	err := s.sim.PriorityClasses.Add(class)
+func (s *schedulingV1Client) Create(_ context.Context, class *apischedulingv1.PriorityClass, _ apimachineryv1.CreateOptions) (*apischedulingv1.PriorityClass, error) {
+	if _, exist, _ := s.sim.PriorityClasses.Get(class); exist {
+		return nil, fmt.Errorf("duplicate PriorityClass %s", class.Name)
+	}
+	err := s.sim.PriorityClasses.Add(class.DeepCopy())
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("error stroing PriorityClass %s", class.Name))
 	}
+	ev := &watch.Event{
+		Type:   watch.Added,
+		Object: class,
+	}
+	err = s.sim.queue.Publish(util.TopicPriorityClass, ev)
+	if err != nil {
+		logrus.Errorf("Error publishing add event: %v", err)
+	}
 	return class, nil
 }
 
-func (s *schedulingV1Client) Update(class *apischedulingv1.PriorityClass) (*apischedulingv1.PriorityClass, error) {
-	err := s.sim.PriorityClasses.Update(class)
+func (s *schedulingV1Client) Update(_ context.Context, class *apischedulingv1.PriorityClass, _ apimachineryv1.UpdateOptions) (*apischedulingv1.PriorityClass, error) {
+	err := s.sim.PriorityClasses.Update(class.DeepCopy())
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("error updating PriorityClass %s", class.Name))
 	}
+	ev := &watch.Event{
+		Type:   watch.Modified,
+		Object: class,
+	}
+	err = s.sim.queue.Publish(util.TopicPriorityClass, ev)
+	if err != nil {
+		logrus.Errorf("Error publishing update event: %v", err)
+	}
 	return class, nil
 }
 
-func (s *schedulingV1Client) Delete(name string, options *apimachineryv1.DeleteOptions) error {
+func (s *schedulingV1Client) Delete(_ context.Context, name string, _ apimachineryv1.DeleteOptions) error {
 	item, exists, err := s.sim.PriorityClasses.GetByKey(name)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error getting PriorityClass %s", name))
@@ -868,14 +909,22 @@ func (s *schedulingV1Client) Delete(name string, options *apimachineryv1.DeleteO
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error deleting PriorityClass %s", name))
 	}
+	ev := &watch.Event{
+		Type:   watch.Deleted,
+		Object: class,
+	}
+	err = s.sim.queue.Publish(util.TopicPriorityClass, ev)
+	if err != nil {
+		logrus.Errorf("Error publishing delete event: %v", err)
+	}
 	return nil
 }
 
-func (s *schedulingV1Client) DeleteCollection(options *apimachineryv1.DeleteOptions, listOptions apimachineryv1.ListOptions) error {
+func (s *schedulingV1Client) DeleteCollection(_ context.Context, _ apimachineryv1.DeleteOptions, _ apimachineryv1.ListOptions) error {
 	panic("Using this interface is not allowed.")
 }
 
-func (s *schedulingV1Client) Get(name string, options apimachineryv1.GetOptions) (*apischedulingv1.PriorityClass, error) {
+func (s *schedulingV1Client) Get(_ context.Context, name string, _ apimachineryv1.GetOptions) (*apischedulingv1.PriorityClass, error) {
 	key, exists, err := s.sim.PriorityClasses.GetByKey(name)
 	if !exists {
 		return nil, fmt.Errorf("No PriorityClass %s", name)
@@ -886,26 +935,26 @@ func (s *schedulingV1Client) Get(name string, options apimachineryv1.GetOptions)
 	return key.(*apischedulingv1.PriorityClass), nil
 }
 
-func (s *schedulingV1Client) List(opts apimachineryv1.ListOptions) (*apischedulingv1.PriorityClassList, error) {
+func (s *schedulingV1Client) List(_ context.Context, _ apimachineryv1.ListOptions) (*apischedulingv1.PriorityClassList, error) {
 	list := s.sim.PriorityClasses.List()
 	classList := &apischedulingv1.PriorityClassList{}
 	items := make([]apischedulingv1.PriorityClass, 0, len(list))
 	for _, item := range list {
-		items = append(items, item.(apischedulingv1.PriorityClass))
+		items = append(items, *item.(*apischedulingv1.PriorityClass))
 	}
 	classList.Items = items
 	return classList, nil
 }
 
-func (s *schedulingV1Client) Watch(opts apimachineryv1.ListOptions) (watch.Interface, error) {
-	watcher, err := util.GetMessageQueue().Subscribe(util.TopicPriorityClass)
+func (s *schedulingV1Client) Watch(_ context.Context, _ apimachineryv1.ListOptions) (watch.Interface, error) {
+	watcher, err := s.sim.queue.Subscribe(util.TopicPriorityClass)
 	if err != nil {
 		return nil, errors.Wrap(err, "error subscribing PriorityClass Topic")
 	}
 	return watcher, nil
 }
 
-func (s *schedulingV1Client) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *apischedulingv1.PriorityClass, err error) {
+func (s *schedulingV1Client) Patch(_ context.Context, _ string, _ types.PatchType, _ []byte, _ apimachineryv1.PatchOptions, _ ...string) (result *apischedulingv1.PriorityClass, err error) {
 	panic("implement me")
 }
 
@@ -913,6 +962,6 @@ func (s *schedulingV1Client) RESTClient() rest.Interface {
 	panic("implement me")
 }
 
-func (s *schedulingV1Client) PriorityClasses() v1.PriorityClassInterface {
+func (s *schedulingV1Client) PriorityClasses() schedulingv1.PriorityClassInterface {
 	return s
 }
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sync"
+	"time"
+)
+
+// DefaultTickDuration 默认一个Tick对应的模拟时间
+const DefaultTickDuration = time.Second
+
+// SimulationEpoch 第0个Tick开始时的模拟时间。使用固定的时间以便每次运行的模拟时间相同
+var SimulationEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock 模拟时钟。Now返回当前Tick开始时的模拟时间，每个Tick前进TickDuration。
+// 实现了k8s.io/apimachinery/pkg/util/clock.Clock，After、NewTimer与NewTicker在模拟时间到达时触发，因此可以提供给需要
+// 时钟的调度器插件等使用。注意不能在模拟器线程（如控制器的Tick）中等待它们，否则模拟时间不会前进，模拟器将停滞。
+type Clock interface {
+	clock.Clock
+
+	// CurrentTick 当前Tick的序号
+	CurrentTick() int
+
+	// TickDuration 一个Tick对应的模拟时间
+	TickDuration() time.Duration
+}
+
+// ClockSetter PodAlgorithm需要模拟时间时可以实现本接口，模拟器在创建Pod时传入模拟时钟。CoreScheduler通过
+// CoreSchedulerFactory得到模拟时钟。
+type ClockSetter interface {
+	SetClock(clock Clock)
+}
+
+type simClock struct {
+	*clock.FakeClock
+	lock     sync.RWMutex
+	tick     int
+	duration time.Duration
+}
+
+var _ Clock = &simClock{}
+
+func newSimClock(duration time.Duration) *simClock {
+	return &simClock{
+		FakeClock: clock.NewFakeClock(SimulationEpoch),
+		duration:  duration,
+	}
+}
+
+// setTick 将模拟时间前进到第tick个Tick开始的时间，到期的定时器将被触发
+func (c *simClock) setTick(tick int) {
+	c.lock.Lock()
+	c.tick = tick
+	now := SimulationEpoch.Add(time.Duration(tick) * c.duration)
+	c.lock.Unlock()
+	c.FakeClock.SetTime(now)
+}
+
+func (c *simClock) setTickDuration(duration time.Duration) {
+	c.lock.Lock()
+	c.duration = duration
+	c.lock.Unlock()
+}
+
+func (c *simClock) CurrentTick() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.tick
+}
+
+func (c *simClock) TickDuration() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.duration
+}
+
+// Sleep 等待模拟时间经过d。FakeClock的Sleep会直接推进时间，因此需要覆盖
+func (c *simClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
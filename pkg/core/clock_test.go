@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimClock(t *testing.T) {
+	c := newSimClock(DefaultTickDuration)
+	c.setTickDuration(2 * time.Second)
+	c.setTick(3)
+	if c.CurrentTick() != 3 || !c.Now().Equal(SimulationEpoch.Add(6*time.Second)) {
+		t.Errorf("wrong time %v at tick %d", c.Now(), c.CurrentTick())
+	}
+
+	ch := c.After(3 * time.Second)
+	c.setTick(4)
+	select {
+	case <-ch:
+		t.Error("should not fire before simulated time passes")
+	default:
+	}
+	c.setTick(5)
+	select {
+	case now := <-ch:
+		if !now.Equal(SimulationEpoch.Add(10 * time.Second)) {
+			t.Errorf("wrong fire time %v", now)
+		}
+	default:
+		t.Error("should fire after simulated time passes")
+	}
+}
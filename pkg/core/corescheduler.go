@@ -12,17 +12,31 @@ const (
 	FairScheduler = "fairScheduler"
 )
 
-var schedulerMap = map[string]CoreScheduler{
-	FairScheduler: &fairScheduler{},
+// CoreSchedulerFactory 构造CoreScheduler，clock为节点所在模拟器的模拟时钟。模拟器创建每个节点时调用一次，因此需要模拟
+// 时钟等状态的CoreScheduler应当返回新的实例，而不是在多个模拟器之间共享
+type CoreSchedulerFactory func(clock Clock) CoreScheduler
+
+var schedulerMap = map[string]CoreSchedulerFactory{
+	FairScheduler: func(Clock) CoreScheduler {
+		return &fairScheduler{}
+	},
 }
 
-func GetCoreScheduler(name string) (scheduler CoreScheduler, exist bool) {
-	scheduler, exist = schedulerMap[name]
+func GetCoreSchedulerFactory(name string) (factory CoreSchedulerFactory, exist bool) {
+	factory, exist = schedulerMap[name]
 	return
 }
 
+// RegisterNewCoreScheduler 注册无状态的CoreScheduler，所有模拟器的所有节点共享同一个实例
 func RegisterNewCoreScheduler(name string, scheduler CoreScheduler) {
-	schedulerMap[name] = scheduler
+	RegisterCoreSchedulerFactory(name, func(Clock) CoreScheduler {
+		return scheduler
+	})
+}
+
+// RegisterCoreSchedulerFactory 注册CoreScheduler的工厂，每个节点使用工厂构造的实例
+func RegisterCoreSchedulerFactory(name string, factory CoreSchedulerFactory) {
+	schedulerMap[name] = factory
 }
 
 // fairScheduler 是完全公平的调度器，不会理会Priority的限制，完全公平的将一个CPU分配给该所有将在该CPU上运行的Pod使用。
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"math"
 	"testing"
 )
@@ -24,7 +26,8 @@ func (_ *mockAlgorithm) Tick(_ []float64, _ int64) (Load float64, MemUsage int64
 }
 
 func TestFairScheduler(t *testing.T) {
-	sched, _ := GetCoreScheduler(FairScheduler)
+	factory, _ := GetCoreSchedulerFactory(FairScheduler)
+	sched := factory(newSimClock(DefaultTickDuration))
 	readyPods := make([]*Pod, 10)
 	for i := 0; i < len(readyPods); i++ {
 		fakePod := newFakePod(fmt.Sprintf("pod-%d", i))
@@ -80,3 +83,32 @@ func TestFairScheduler(t *testing.T) {
 	}
 
 }
+
+type clockScheduler struct {
+	fairScheduler
+	clock Clock
+}
+
+func TestCoreSchedulerFactory(t *testing.T) {
+	const name = "clockScheduler"
+	RegisterCoreSchedulerFactory(name, func(clock Clock) CoreScheduler {
+		return &clockScheduler{clock: clock}
+	})
+	defer delete(schedulerMap, name)
+
+	// 每个模拟器的节点使用各自的实例，得到所在模拟器的时钟
+	sims := []*schedSim{NewSchedulerSimulator(10).(*schedSim), NewSchedulerSimulator(10).(*schedSim)}
+	for _, sim := range sims {
+		defer sim.Stop()
+		node := BuildNode("node-1", "8", "16G", "100", name)
+		if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, sim := range sims {
+		node := sim.sortedNodes()[0]
+		if scheduler := node.Scheduler.(*clockScheduler); scheduler.clock != sim.clock {
+			t.Errorf("core scheduler of simulator %d should use its own clock", i)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/kubernetes/pkg/scheduler"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	frameworkplugins "k8s.io/kubernetes/pkg/scheduler/framework/plugins"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	"sort"
+)
+
+// scorePluginExtension ListPlugins返回的Score插件的扩展点名称
+const scorePluginExtension = "ScorePlugin"
+
+// decisionTracer 在每次调度尝试之后，使用调度器本次调度的节点快照重新运行PreFilter、Filter、PreScore与Score插件，记录
+// 每个节点被哪个插件过滤以及各个插件的打分。调度器只会在Pod交给它时更新快照，而模拟器等待调度结果之后才交给下一个Pod，
+// 因此重新运行时的快照与调度时相同。
+//
+// 与调度器不同，重新运行时检查所有节点，而不会在找到percentageOfNodesToScore比例的可用节点之后停止，也不考虑被提名的
+// 高优先级Pod，因此集群较大或者发生抢占时记录的可用节点可能多于调度器实际检查的节点。
+type decisionTracer struct {
+	sched  *scheduler.Scheduler
+	config *schedulerapi.KubeSchedulerConfiguration
+	sink   metrics.DecisionSink
+	// scorePlugins 各个Profile的Score插件实例。Framework不提供单个插件的原始分数，因此由追踪器自己构造插件并调用
+	scorePlugins map[string][]*weightedScorePlugin
+}
+
+type weightedScorePlugin struct {
+	framework.ScorePlugin
+	weight int64
+}
+
+func newDecisionTracer(sched *scheduler.Scheduler, config *schedulerapi.KubeSchedulerConfiguration,
+	sink metrics.DecisionSink) *decisionTracer {
+	return &decisionTracer{
+		sched:        sched,
+		config:       config,
+		sink:         sink,
+		scorePlugins: make(map[string][]*weightedScorePlugin),
+	}
+}
+
+// trace 记录在第tick个Tick中调度pod的决策过程。node为选中的节点，err为调度失败的原因
+func (t *decisionTracer) trace(tick int, pod *v1.Pod, node string, err error) {
+	record := &metrics.DecisionRecord{
+		Tick:          tick,
+		Pod:           pod.Name,
+		SchedulerName: pod.Spec.SchedulerName,
+		Node:          node,
+		Nodes:         []*metrics.NodeDecision{},
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if traceErr := t.replay(record, pod); traceErr != nil {
+		logrus.Warnf("Decision of pod %s is incomplete: %v", pod.Name, traceErr)
+	}
+	if writeErr := t.sink.WriteDecision(record); writeErr != nil {
+		logrus.Errorf("error writing decision of pod %s: %v", pod.Name, writeErr)
+	}
+}
+
+// replay 重新运行调度器的插件，将各个节点的结果填入record
+func (t *decisionTracer) replay(record *metrics.DecisionRecord, pod *v1.Pod) error {
+	prof, ok := t.sched.Profiles[pod.Spec.SchedulerName]
+	if !ok {
+		return fmt.Errorf("profile %s not found", pod.Spec.SchedulerName)
+	}
+	fwk := prof.Framework
+	nodeInfos, err := fwk.SnapshotSharedLister().NodeInfos().List()
+	if err != nil {
+		return errors.Wrap(err, "error listing nodes")
+	}
+	sort.Slice(nodeInfos, func(i, j int) bool {
+		return nodeInfos[i].Node().Name < nodeInfos[j].Node().Name
+	})
+
+	ctx := context.Background()
+	state := framework.NewCycleState()
+	if status := fwk.RunPreFilterPlugins(ctx, state, pod); !status.IsSuccess() {
+		record.PreFilter = status.Message()
+		for _, info := range nodeInfos {
+			record.Nodes = append(record.Nodes, &metrics.NodeDecision{Node: info.Node().Name})
+		}
+		return nil
+	}
+
+	feasible := make([]*v1.Node, 0, len(nodeInfos))
+	decisions := make(map[string]*metrics.NodeDecision, len(nodeInfos))
+	for _, info := range nodeInfos {
+		decision := &metrics.NodeDecision{Node: info.Node().Name}
+		statuses := fwk.RunFilterPlugins(ctx, state, pod, info)
+		plugins := make([]string, 0, len(statuses))
+		for plugin := range statuses {
+			plugins = append(plugins, plugin)
+		}
+		sort.Strings(plugins)
+		for _, plugin := range plugins {
+			if status := statuses[plugin]; !status.IsSuccess() {
+				decision.FilteredBy = plugin
+				decision.Reason = status.Message()
+				break
+			}
+		}
+		if decision.FilteredBy == "" {
+			feasible = append(feasible, info.Node())
+		}
+		decisions[decision.Node] = decision
+		record.Nodes = append(record.Nodes, decision)
+	}
+	// 与调度器相同，只有一个可用节点时不打分
+	if len(feasible) < 2 {
+		return nil
+	}
+
+	if status := fwk.RunPreScorePlugins(ctx, state, pod, feasible); !status.IsSuccess() {
+		record.PreScore = status.Message()
+		return nil
+	}
+	plugins, err := t.scorePluginsOf(pod.Spec.SchedulerName, fwk)
+	if err != nil {
+		return err
+	}
+	for _, plugin := range plugins {
+		scores := make(framework.NodeScoreList, len(feasible))
+		for i, node := range feasible {
+			score, status := plugin.Score(ctx, state, pod, node.Name)
+			if !status.IsSuccess() {
+				return fmt.Errorf("plugin %s failed scoring node %s: %s", plugin.Name(), node.Name, status.Message())
+			}
+			scores[i] = framework.NodeScore{Name: node.Name, Score: score}
+		}
+		raw := make(framework.NodeScoreList, len(scores))
+		copy(raw, scores)
+		if extension := plugin.ScoreExtensions(); extension != nil {
+			if status := extension.NormalizeScore(ctx, state, pod, scores); !status.IsSuccess() {
+				return fmt.Errorf("plugin %s failed normalizing scores: %s", plugin.Name(), status.Message())
+			}
+		}
+		for i, score := range scores {
+			decision := decisions[score.Name]
+			decision.Scores = append(decision.Scores, &metrics.PluginScore{
+				Plugin:     plugin.Name(),
+				Raw:        raw[i].Score,
+				Normalized: score.Score,
+				Weight:     plugin.weight,
+			})
+			decision.TotalScore += score.Score * plugin.weight
+		}
+	}
+	return nil
+}
+
+// scorePluginsOf 构造Profile启用的所有Score插件，插件的参数与调度器配置中的相同
+func (t *decisionTracer) scorePluginsOf(profileName string, fwk framework.Framework) ([]*weightedScorePlugin, error) {
+	if plugins, ok := t.scorePlugins[profileName]; ok {
+		return plugins, nil
+	}
+
+	registry := frameworkplugins.NewInTreeRegistry()
+	if err := registry.Merge(schedulerPluginRegistry); err != nil {
+		return nil, err
+	}
+	args := make(map[string]*runtime.Unknown)
+	if t.config != nil {
+		for _, profile := range t.config.Profiles {
+			if profile.SchedulerName != profileName {
+				continue
+			}
+			for i := range profile.PluginConfig {
+				args[profile.PluginConfig[i].Name] = &profile.PluginConfig[i].Args
+			}
+		}
+	}
+
+	plugins := make([]*weightedScorePlugin, 0)
+	for _, p := range fwk.ListPlugins()[scorePluginExtension] {
+		factory, ok := registry[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("score plugin %s not registered", p.Name)
+		}
+		plugin, err := factory(args[p.Name], fwk)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error building score plugin %s", p.Name))
+		}
+		scorePlugin, ok := plugin.(framework.ScorePlugin)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s is not a score plugin", p.Name)
+		}
+		plugins = append(plugins, &weightedScorePlugin{ScorePlugin: scorePlugin, weight: int64(p.Weight)})
+	}
+	t.scorePlugins[profileName] = plugins
+	return plugins, nil
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+type memoryDecisionSink struct {
+	records []*metrics.DecisionRecord
+	closed  bool
+}
+
+func (s *memoryDecisionSink) WriteDecision(record *metrics.DecisionRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryDecisionSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestDecisionTrace(t *testing.T) {
+	sim := NewSchedulerSimulator(10)
+	sink := &memoryDecisionSink{}
+	sim.SetDecisionSinks(sink)
+
+	for _, name := range []string{"node-0", "node-1", "node-2"} {
+		node := BuildNode(name, "8", "16G", "100", FairScheduler)
+		// node-0不可调度，应该被NodeUnschedulable插件过滤
+		node.Spec.Unschedulable = name == "node-0"
+		if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(), newFakePod("traced"),
+		metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts := sim.(*schedSim).cycle.schedule(3); attempts != 1 {
+		t.Fatalf("should schedule 1 pod, not %d", attempts)
+	}
+	sim.Stop()
+
+	if !sink.closed {
+		t.Errorf("sink should be closed when simulator stops")
+	}
+	if len(sink.records) != 1 {
+		t.Fatalf("should have 1 decision, not %d", len(sink.records))
+	}
+	record := sink.records[0]
+	if record.Tick != 3 || record.Pod != "traced" || record.Node == "" || record.Error != "" {
+		t.Errorf("wrong decision %+v", record)
+	}
+	if len(record.Nodes) != 3 {
+		t.Fatalf("should have 3 candidate nodes, not %d", len(record.Nodes))
+	}
+	if record.Nodes[0].FilteredBy != "NodeUnschedulable" || len(record.Nodes[0].Scores) != 0 {
+		t.Errorf("node-0 should be filtered by NodeUnschedulable, got %+v", record.Nodes[0])
+	}
+	var best int64 = -1
+	for _, node := range record.Nodes[1:] {
+		if node.FilteredBy != "" || len(node.Scores) == 0 {
+			t.Errorf("%s should pass filters and have scores, got %+v", node.Node, node)
+		}
+		var total int64
+		for _, score := range node.Scores {
+			total += score.Normalized * score.Weight
+		}
+		if total != node.TotalScore {
+			t.Errorf("total score of %s should be %d, not %d", node.Node, total, node.TotalScore)
+		}
+		if node.TotalScore > best {
+			best = node.TotalScore
+		}
+	}
+	for _, node := range record.Nodes {
+		if node.Node == record.Node && node.TotalScore != best {
+			t.Errorf("chosen node %s should have the highest score %d, not %d", node.Node, best, node.TotalScore)
+		}
+	}
+}
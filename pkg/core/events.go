@@ -0,0 +1,97 @@
+package core
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// eventType 事件驱动模式中唤醒模拟器的事件类型
+type eventType string
+
+const (
+	eventPodArrival       = eventType("PodArrival")
+	eventPodCompletion    = eventType("PodCompletion")
+	eventNodeChange       = eventType("NodeChange")
+	eventNodeActive       = eventType("NodeActive")
+	eventControllerWakeUp = eventType("ControllerWakeUp")
+)
+
+type simEvent struct {
+	tick int
+	what eventType
+}
+
+type eventHeap []*simEvent
+
+func (h eventHeap) Len() int {
+	return len(h)
+}
+
+func (h eventHeap) Less(i, j int) bool {
+	return h[i].tick < h[j].tick
+}
+
+func (h eventHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *eventHeap) Push(x interface{}) {
+	*h = append(*h, x.(*simEvent))
+}
+
+func (h *eventHeap) Pop() interface{} {
+	item := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return item
+}
+
+// eventQueue 按照Tick排序的事件优先队列。事件在Pod事件的回调中加入，可能在调度器线程中调用，因此需要上锁
+type eventQueue struct {
+	lock   sync.Mutex
+	events eventHeap
+	// pending 已经在队列中的事件，同一个Tick中相同类型的事件只保留一个
+	pending map[simEvent]bool
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		events:  make(eventHeap, 0, 16),
+		pending: make(map[simEvent]bool),
+	}
+}
+
+func (q *eventQueue) push(tick int, what eventType) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	ev := simEvent{tick: tick, what: what}
+	if q.pending[ev] {
+		return
+	}
+	q.pending[ev] = true
+	heap.Push(&q.events, &ev)
+}
+
+// next 丢弃不晚于tick的事件，返回之后最早的事件的Tick以及该Tick的所有事件类型。没有事件时返回-1
+func (q *eventQueue) next(tick int) (int, []eventType) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.events) > 0 && q.events[0].tick <= tick {
+		ev := heap.Pop(&q.events).(*simEvent)
+		delete(q.pending, *ev)
+	}
+	if len(q.events) == 0 {
+		return -1, nil
+	}
+	next := q.events[0].tick
+	what := make([]eventType, 0, 1)
+	for ev := range q.pending {
+		if ev.tick == next {
+			what = append(what, ev.what)
+		}
+	}
+	sort.Slice(what, func(i, j int) bool {
+		return what[i] < what[j]
+	})
+	return next, what
+}
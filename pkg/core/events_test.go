@@ -0,0 +1,28 @@
+package core
+
+import "testing"
+
+func TestEventQueue(t *testing.T) {
+	q := newEventQueue()
+	q.push(5, eventControllerWakeUp)
+	q.push(2, eventPodArrival)
+	q.push(2, eventPodArrival)
+	q.push(2, eventNodeChange)
+	q.push(9, eventNodeActive)
+
+	next, what := q.next(0)
+	if next != 2 || len(what) != 2 || what[0] != eventNodeChange || what[1] != eventPodArrival {
+		t.Errorf("wrong next event %d %v", next, what)
+	}
+	if next, _ = q.next(2); next != 5 {
+		t.Errorf("next event should be at 5, not %d", next)
+	}
+	// 重复的事件被丢弃之后可以再次加入
+	q.push(2, eventPodArrival)
+	if next, _ = q.next(6); next != 9 {
+		t.Errorf("next event should be at 9, not %d", next)
+	}
+	if next, what = q.next(9); next != -1 || what != nil {
+		t.Errorf("should have no event, not %d %v", next, what)
+	}
+}
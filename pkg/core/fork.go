@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"sync"
+)
+
+// Branch 从模拟器当前状态分出的一个假设分支，用于比较从同一时刻开始使用不同的调度器Profile或控制器的结果
+type Branch struct {
+	// Name 分支的名称
+	Name string
+	// TotalTick 分支运行到的Tick，为0时与原模拟器相同
+	TotalTick int
+	// SchedulerName 不为空时，分支中尚未绑定的Pod与之后新建的Pod都使用该调度器Profile
+	SchedulerName string
+	// SchedulerConfig 分支的调度器配置，为nil时与原模拟器相同
+	SchedulerConfig *schedulerapi.KubeSchedulerConfiguration
+	// Setup 在恢复快照之前调用，用于注册分支的控制器以及修改模拟器的参数。需要恢复状态的控制器应该与原模拟器中的同名，
+	// 为nil时分支中没有控制器
+	Setup func(sim SchedulerSimulator) error
+}
+
+// BranchResult 一个分支的运行结果
+type BranchResult struct {
+	Name string
+	// Simulator 分支的模拟器，可以在运行结束之后查看其状态
+	Simulator SchedulerSimulator
+	// Cluster 分支运行期间集群使用率与分配差距的统计，不包括分支之前的Tick
+	Cluster *metrics.ClusterPeriodMetrics
+	// Pods 分支之前尚未绑定的Pod以及分支中新建的Pod的调度记录汇总
+	Pods *metrics.PodSummary
+	// Err 构造或恢复分支时发生的错误，此时分支没有运行
+	Err error
+}
+
+func (sim *schedSim) Fork(branches ...*Branch) ([]*BranchResult, error) {
+	snapshot, err := sim.Snapshot()
+	if err != nil {
+		return nil, errors.Wrap(err, "error taking snapshot")
+	}
+
+	// 各个分支并发运行，结果按照branches的顺序排列。各个模拟器使用自己的随机数生成器，调度器的全局随机数由
+	// globalRandLock保护，因此确定性模式下的结果与顺序运行时相同
+	results := make([]*BranchResult, len(branches))
+	wg := sync.WaitGroup{}
+	for i, branch := range branches {
+		result := &BranchResult{Name: branch.Name}
+		results[i] = result
+		branchSim, aggregator, err := sim.newBranch(branch, snapshot)
+		if err != nil {
+			result.Err = errors.Wrap(err, fmt.Sprintf("error building branch %s", branch.Name))
+			continue
+		}
+		result.Simulator = branchSim
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logrus.Infof("Running branch %s from tick %d to %d", result.Name, snapshot.Tick, branchSim.TotalTick)
+			branchSim.Run()
+			result.Cluster = aggregator.Get()
+			result.Pods = metrics.SummarizePods(branchRecords(branchSim.GetPodRecords(), snapshot.Tick))
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// newBranch 构造分支的模拟器。分支继承原模拟器的运行参数，不输出统计数据，只使用返回的统计器统计集群的数据，其中包括
+// 事件驱动模式下跳过的Tick
+func (sim *schedSim) newBranch(branch *Branch, snapshot *Snapshot) (*schedSim, metrics.ClusterAggregator, error) {
+	totalTick := branch.TotalTick
+	if totalTick == 0 {
+		totalTick = sim.TotalTick
+	}
+	config := branch.SchedulerConfig
+	if config == nil {
+		config = sim.schedulerConfig
+	}
+	created, err := NewSchedulerSimulatorWithConfig(totalTick, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	branchSim := created.(*schedSim)
+	if sim.deterministic {
+		// 各个分支使用原模拟器的种子，使其结果只受调度器与控制器的影响
+		branchSim.SetDeterministic(sim.seed)
+	}
+	branchSim.SetEventDriven(sim.eventDriven)
+	branchSim.SetParallelNodeUpdate(sim.parallel)
+	branchSim.SetSchedulingBudget(sim.cycle.budget)
+	branchSim.SetSchedulingLatency(sim.cycle.latency)
+	branchSim.SetMetricsSinks()
+	branchSim.SetClusterMetricsSinks()
+	branchSim.schedulerName = branch.SchedulerName
+
+	if branch.Setup != nil {
+		if err := branch.Setup(branchSim); err != nil {
+			branchSim.Stop()
+			return nil, nil, err
+		}
+	}
+	aggregator := metrics.NewClusterAggregator()
+	branchSim.branchAggregator = aggregator
+
+	if err := branchSim.Restore(snapshot); err != nil {
+		branchSim.Stop()
+		return nil, nil, err
+	}
+	return branchSim, aggregator, nil
+}
+
+// branchRecords 返回在tick之前没有绑定的Pod的调度记录
+func branchRecords(records []*metrics.PodRecord, tick int) []*metrics.PodRecord {
+	result := make([]*metrics.PodRecord, 0, len(records))
+	for _, record := range records {
+		if record.BindTick < 0 || record.BindTick >= tick {
+			result = append(result, record)
+		}
+	}
+	return result
+}
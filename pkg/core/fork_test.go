@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestFork(t *testing.T) {
+	sim := NewSchedulerSimulator(5)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	count, ticks := 0, make([]int, 0)
+	sim.RegisterBeforeUpdateController(newCountingController(&count, &ticks, sim))
+	sim.Run()
+
+	counts := map[string]*int{"a": new(int), "b": new(int)}
+	branches := make([]*Branch, 0, 2)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		branches = append(branches, &Branch{
+			Name:      name,
+			TotalTick: 10,
+			Setup: func(branchSim SchedulerSimulator) error {
+				branchTicks := make([]int, 0)
+				branchSim.RegisterBeforeUpdateController(newCountingController(counts[name], &branchTicks, branchSim))
+				if name != "a" {
+					return nil
+				}
+				// 只有分支a在第7个Tick创建Pod
+				branchSim.RegisterBeforeUpdateController(&ControllerFunc{
+					NameString: "creator",
+					TickFunc: func() {
+						if branchSim.GetClock().CurrentTick() != 7 {
+							return
+						}
+						_, err := branchSim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(),
+							newFakePod("branch-pod"), metav1.CreateOptions{})
+						if err != nil {
+							t.Error(err)
+						}
+					},
+				})
+				return nil
+			},
+		})
+	}
+
+	results, err := sim.Fork(branches...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("should have 2 results, not %d", len(results))
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("branch %s failed: %v", result.Name, result.Err)
+		}
+		if *counts[result.Name] != 10 {
+			t.Errorf("controller of branch %s should be called 10 times in total, not %d", result.Name, *counts[result.Name])
+		}
+		if result.Cluster == nil {
+			t.Errorf("branch %s should have cluster metrics", result.Name)
+		}
+	}
+
+	// 分支之间以及与原模拟器之间互不影响
+	if results[0].Pods.Pods != 1 {
+		t.Errorf("branch a should have 1 pod, not %d", results[0].Pods.Pods)
+	}
+	if results[1].Pods.Pods != 0 {
+		t.Errorf("branch b should have no pod, not %d", results[1].Pods.Pods)
+	}
+	if _, err = sim.GetPod("branch-pod"); err == nil {
+		t.Error("pod of branch a should not be created in the original simulator")
+	}
+	if count != 5 {
+		t.Errorf("controller of the original simulator should be called 5 times, not %d", count)
+	}
+}
+
+func TestForkSeed(t *testing.T) {
+	sim := NewSchedulerSimulator(5)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	sim.SetDeterministic(7)
+	// 节点完全相同，调度器需要在得分相同的节点中随机选择
+	for i := 0; i < 8; i++ {
+		node := BuildNode(fmt.Sprintf("node-%d", i), "8", "16G", "100", FairScheduler)
+		if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sim.RunUntil(func(sim SchedulerSimulator) bool {
+		return sim.GetClock().CurrentTick() == 2
+	})
+	defer sim.Stop()
+
+	names := []string{"a", "b", "c", "d"}
+	branches := make([]*Branch, 0, len(names))
+	for _, name := range names {
+		branches = append(branches, &Branch{
+			Name: name,
+			Setup: func(branchSim SchedulerSimulator) error {
+				created := false
+				branchSim.RegisterBeforeUpdateController(&ControllerFunc{
+					NameString: "creator",
+					TickFunc: func() {
+						if created {
+							return
+						}
+						created = true
+						for i := 0; i < 20; i++ {
+							_, err := branchSim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(),
+								newFakePod(fmt.Sprintf("pod-%d", i)), metav1.CreateOptions{})
+							if err != nil {
+								t.Error(err)
+							}
+						}
+					},
+				})
+				return nil
+			},
+		})
+	}
+	results, err := sim.Fork(branches...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 各个分支使用原模拟器的种子，并发运行的分支的结果与顺序无关
+	placements := make([]string, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("branch %s failed: %v", result.Name, result.Err)
+		}
+		if result.Name != names[i] {
+			t.Errorf("result %d should be branch %s, not %s", i, names[i], result.Name)
+		}
+		if branch := result.Simulator.(*schedSim); !branch.deterministic || branch.seed != 7 {
+			t.Errorf("branch %s should use seed 7, not %d", result.Name, branch.seed)
+		}
+		placement := make(map[string]string)
+		for _, record := range result.Simulator.GetPodRecords() {
+			placement[record.Name] = record.Node
+		}
+		placements[i] = fmt.Sprint(placement)
+		if placements[i] != placements[0] {
+			t.Errorf("branch %s should place pods the same way as branch %s:\n%s\n%s", result.Name, names[0],
+				placements[0], placements[i])
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// SchedulingLatency 调度延迟模型，决定调度器一次调度尝试消耗的模拟时间。调度器每个Tick有1个Tick的时间可用，调度尝试
+// 在开始的Tick中生效，其消耗的时间推迟之后的调度尝试，超出本Tick的部分由之后的Tick偿还。因此调度延迟较大时，突发提交的
+// Pod将在待调度队列中等待。
+type SchedulingLatency interface {
+	// Latency 返回调度pod一次消耗的Tick数，elapsed为调度器本次调度实际运行的时间
+	Latency(pod *v1.Pod, elapsed time.Duration) float64
+}
+
+// SchedulingLatencyFunc 使用函数实现SchedulingLatency
+type SchedulingLatencyFunc func(pod *v1.Pod, elapsed time.Duration) float64
+
+func (f SchedulingLatencyFunc) Latency(pod *v1.Pod, elapsed time.Duration) float64 {
+	return f(pod, elapsed)
+}
+
+const (
+	LatencyConstant = "constant"
+	LatencyMeasured = "measured"
+)
+
+// NewConstantLatency 每次调度尝试均消耗ticks个Tick
+func NewConstantLatency(ticks float64) SchedulingLatency {
+	return SchedulingLatencyFunc(func(*v1.Pod, time.Duration) float64 {
+		return ticks
+	})
+}
+
+// NewMeasuredLatency 根据调度器各个插件实际运行的时间计算消耗的Tick数，每秒实际运行时间对应scale个Tick
+func NewMeasuredLatency(scale float64) SchedulingLatency {
+	return SchedulingLatencyFunc(func(_ *v1.Pod, elapsed time.Duration) float64 {
+		return elapsed.Seconds() * scale
+	})
+}
+
+// NewSchedulingLatency 根据名称构造调度延迟模型，value为constant的Tick数或measured的比例
+func NewSchedulingLatency(name string, value float64) (SchedulingLatency, error) {
+	if value < 0 {
+		return nil, fmt.Errorf("scheduling latency %s: value must not be negative", name)
+	}
+	switch name {
+	case LatencyConstant:
+		return NewConstantLatency(value), nil
+	case LatencyMeasured:
+		return NewMeasuredLatency(value), nil
+	default:
+		return nil, fmt.Errorf("unknown scheduling latency model %s", name)
+	}
+}
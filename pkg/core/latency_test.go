@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewSchedulingLatency(t *testing.T) {
+	latency, err := NewSchedulingLatency(LatencyConstant, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := latency.Latency(nil, time.Hour); l != 0.5 {
+		t.Errorf("wrong constant latency %f", l)
+	}
+	latency, err = NewSchedulingLatency(LatencyMeasured, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := latency.Latency(nil, 20*time.Millisecond); l != 2 {
+		t.Errorf("wrong measured latency %f", l)
+	}
+	if _, err = NewSchedulingLatency("random", 1); err == nil {
+		t.Error("should not support random")
+	}
+	if _, err = NewSchedulingLatency(LatencyConstant, -1); err == nil {
+		t.Error("should not accept negative value")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"sort"
 	"sync"
 )
 
@@ -30,11 +31,18 @@ type Node struct {
 	// 上一轮的CPU使用百分比，用于查看是否有资源竞争，模拟高资源竞争时CPU处理能力的下降
 	LastCpuUsage float64
 
+	// LastPodMetrics 上一轮各个运行中的Pod的资源分配与使用情况，只在模拟器设置了PodMetricsSink时计算，否则为nil
+	LastPodMetrics []*metrics.PodTickMetrics
+
 	podLock sync.Mutex
 
 	Client kubernetes.Interface
 
 	deletingPods map[string]*podDeletion
+
+	// requests update中暂存的需要通过Client发送的请求。Client的请求会同步调用所有的监听器，因此不能在并行更新节点时发送，
+	// 而是在commit中按照顺序发送
+	requests []func(client kubernetes.Interface)
 }
 
 type podDeletion struct {
@@ -102,10 +110,19 @@ func (n *Node) EvictPod(pod *Pod) error {
 }
 
 // 根据节点拥有的Pod，更新当前的节点状态，包括资源使用率，Pod状态等
-// TODO 引入物理内存超分配时的时间片惩罚
 func (n *Node) Tick(client kubernetes.Interface) *metrics.TickMetrics {
+	met := n.update(true)
+	n.commit(client)
+	return met
+}
+
+// update 计算本Tick中节点与Pod的状态，只修改本节点以及本节点上的Pod，需要发送的请求暂存到commit时发送。因此不同节点的
+// update可以并行执行。podMetrics为false时不计算各个Pod的统计数据
+// TODO 引入物理内存超分配时的时间片惩罚
+func (n *Node) update(podMetrics bool) *metrics.TickMetrics {
 	type PodResource struct {
 		slot     []float64
+		cpu      float64
 		mem      int64
 		load     float64
 		memUsage int64
@@ -116,39 +133,37 @@ func (n *Node) Tick(client kubernetes.Interface) *metrics.TickMetrics {
 	podIdxMap := make(map[*Pod]int)
 
 	// 查看Pod的状态
-	for _, pod := range n.Pods {
+	for _, pod := range n.sortedPods() {
 		if pod.Status.Phase == v1.PodRunning {
 			// 首先检查是否是超时删除的Pod
 			if podDeletion, ok := n.deletingPods[pod.Name]; ok {
 				podDeletion.tickLeft--
 				if podDeletion.tickLeft <= 0 {
 					// 执行立即删除
-					zero := int64(0)
-					err := n.Client.CoreV1().Pods(DefaultNamespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{
-						GracePeriodSeconds: &zero,
-					})
-					if err != nil {
-						logrus.Errorf("error deleting pod %s from node %s", pod.Name, n.Name)
-					}
+					n.deletePod(pod.Name)
 					continue
 				}
 			}
 
-			_, mem := pod.Algorithm.ResourceRequest()
+			cpu, mem := pod.Algorithm.ResourceRequest()
 			podIdxMap[pod] = len(podResource)
 			readyPods = append(readyPods, pod)
 			podResource = append(podResource, &PodResource{
 				slot: make([]float64, 0),
+				cpu:  cpu,
 				mem:  mem,
 			})
 		} else {
 			if _, ok := n.deletingPods[pod.Name]; !ok {
 				// 自发停止的Pod执行删除
 				logrus.Infof("Pod %s is now %s", pod.Name, pod.Status.Phase)
-				_, err := client.CoreV1().Pods(DefaultNamespace).UpdateStatus(context.TODO(), &pod.Pod, metav1.UpdateOptions{})
-				if err != nil {
-					logrus.Errorf("Node %s Update pod status for pod %s error: %v", n.Name, pod.Name, err)
-				}
+				stopped := pod
+				n.requests = append(n.requests, func(client kubernetes.Interface) {
+					_, err := client.CoreV1().Pods(DefaultNamespace).UpdateStatus(context.TODO(), &stopped.Pod, metav1.UpdateOptions{})
+					if err != nil {
+						logrus.Errorf("Node %s Update pod status for pod %s error: %v", n.Name, stopped.Name, err)
+					}
+				})
 				// 从本节点移除
 				logrus.Tracef("Removing Pod %s from Node %s", pod.Name, n.Name)
 				key, _ := PodKeyFunc(pod)
@@ -156,15 +171,9 @@ func (n *Node) Tick(client kubernetes.Interface) *metrics.TickMetrics {
 			} else {
 				// 控制停止的Pod停止了
 				logrus.Infof("Pod %s has successfully terminated", pod.Name)
-				zero := int64(0)
 				// 由于无法分清楚是谁发送的GracePeriodSeconds为0的请求，因此这里不执行实际删除，依赖Client调用DeletePod
 				// 函数进行实际的删除
-				err := client.CoreV1().Pods(DefaultNamespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{
-					GracePeriodSeconds: &zero,
-				})
-				if err != nil {
-					logrus.Errorf("error deleting pod %s from node %s", pod.Name, n.Name)
-				}
+				n.deletePod(pod.Name)
 			}
 		}
 	}
@@ -184,15 +193,38 @@ func (n *Node) Tick(client kubernetes.Interface) *metrics.TickMetrics {
 	logrus.Debugf("Node %s Updating Pod status", n.Name)
 	memUsed := int64(0)
 	load := float64(0)
+	var podMetricList []*metrics.PodTickMetrics
+	if podMetrics {
+		podMetricList = make([]*metrics.PodTickMetrics, 0, len(readyPods))
+	}
 	for i := 0; i < len(readyPods); i++ {
 		logrus.Tracef("Node %s Updating Pod %s status", n.Name, readyPods[i].Name)
-		cpuPressureReduction(podResource[i].slot, n.LastCpuUsage)
 		podResource[i].load, podResource[i].memUsage = readyPods[i].Algorithm.Tick(podResource[i].slot, podResource[i].mem)
 
 		// 计算统计
 		memUsed += podResource[i].memUsage
 		load += podResource[i].load
+		if !podMetrics {
+			continue
+		}
+		slotsGranted := sumSlots(podResource[i].slot)
+		reduction := cpuPressureReduction(podResource[i].slot, n.LastCpuUsage)
+		podMetric := &metrics.PodTickMetrics{
+			Pod:               readyPods[i].Name,
+			Node:              n.Name,
+			CpuRequested:      podResource[i].cpu,
+			SlotsGranted:      slotsGranted,
+			SlotsEffective:    slotsGranted * reduction,
+			PressureReduction: reduction,
+			MemRequested:      podResource[i].mem,
+			MemGranted:        podResource[i].mem,
+			MemUsed:           podResource[i].memUsage,
+			Load:              podResource[i].load,
+		}
+		podMetric.Slowdown = podMetric.ComputeSlowdown()
+		podMetricList = append(podMetricList, podMetric)
 	}
+	n.LastPodMetrics = podMetricList
 
 	// 更新节点的状态
 
@@ -212,19 +244,91 @@ func (n *Node) Tick(client kubernetes.Interface) *metrics.TickMetrics {
 	cpuUsage := cpuUsed / float64(coreCount)
 	n.LastCpuUsage = cpuUsage
 
-	n.Status.Allocatable.Cpu().Set(coreCount - int64(cpuUsed))
-	n.Status.Allocatable.Memory().Set(memSize - int64(memUsed))
-	_, err := client.CoreV1().Nodes().UpdateStatus(context.TODO(), &n.Node, metav1.UpdateOptions{})
-	if err != nil {
-		logrus.Errorf("Update Node %s Status error: %v", n.Name, err)
-	}
+	n.requests = append(n.requests, func(client kubernetes.Interface) {
+		_, err := client.CoreV1().Nodes().UpdateStatus(context.TODO(), &n.Node, metav1.UpdateOptions{})
+		if err != nil {
+			logrus.Errorf("Update Node %s Status error: %v", n.Name, err)
+		}
+	})
 
 	return &metrics.TickMetrics{
 		CpuUsage: cpuUsage,
 		MemUsage: float64(memUsed) / float64(memSize),
 		Load:     load,
+		CpuUsed:  cpuUsed,
+		MemUsed:  memUsed,
+	}
+
+}
+
+// commit 按照顺序发送update中暂存的请求，需要在模拟器线程中调用
+func (n *Node) commit(client kubernetes.Interface) {
+	requests := n.requests
+	n.requests = nil
+	for _, request := range requests {
+		request(client)
+	}
+}
+
+// deletePod 暂存立即删除Pod的请求
+func (n *Node) deletePod(name string) {
+	n.requests = append(n.requests, func(client kubernetes.Interface) {
+		zero := int64(0)
+		err := client.CoreV1().Pods(DefaultNamespace).Delete(context.TODO(), name, metav1.DeleteOptions{
+			GracePeriodSeconds: &zero,
+		})
+		if err != nil {
+			logrus.Errorf("error deleting pod %s from node %s", name, n.Name)
+		}
+	})
+}
+
+// Idle 节点上没有Pod，并且上一次Tick之后状态已经稳定。事件驱动模式下不需要更新空闲的节点
+func (n *Node) Idle() bool {
+	return len(n.Pods) == 0 && len(n.deletingPods) == 0 && n.LastCpuUsage == 0
+}
+
+// AllocatedResource 返回本节点上运行中的Pod的CpuLimit与MemLimit之和，即调度器分配出去的资源
+func (n *Node) AllocatedResource() (cpu float64, mem int64) {
+	for _, pod := range n.sortedPods() {
+		if pod.Status.Phase == v1.PodRunning {
+			cpu += pod.CpuLimit
+			mem += pod.MemLimit
+		}
+	}
+	return cpu, mem
+}
+
+// sortedPods 返回按照名称排序的Pod，保证每次运行时Pod的处理顺序相同
+func (n *Node) sortedPods() []*Pod {
+	n.podLock.Lock()
+	pods := make([]*Pod, 0, len(n.Pods))
+	for _, pod := range n.Pods {
+		pods = append(pods, pod)
 	}
+	n.podLock.Unlock()
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
 
+// NodeResource 返回计算碎片化指标所需的资源状态。与调度器相同，空闲资源为容量减去已分配的资源，而不是实际使用量
+func (n *Node) NodeResource() *metrics.NodeResource {
+	n.podLock.Lock()
+	pods := len(n.Pods)
+	n.podLock.Unlock()
+	cpuCapacity := float64(n.Status.Capacity.Cpu().MilliValue()) / 1000
+	memCapacity := n.Status.Capacity.Memory().Value()
+	cpuAllocated, memAllocated := n.AllocatedResource()
+	return &metrics.NodeResource{
+		CpuCapacity: cpuCapacity,
+		CpuFree:     cpuCapacity - cpuAllocated,
+		MemCapacity: memCapacity,
+		MemFree:     memCapacity - memAllocated,
+		PodCapacity: int(n.Status.Capacity.Pods().Value()),
+		Pods:        pods,
+	}
 }
 
 func (n *Node) DeletePod(name string, gracefulTick int) error {
@@ -248,8 +352,9 @@ func (n *Node) DeletePod(name string, gracefulTick int) error {
 
 }
 
-// 当高CPU压力时，减少实际slot数，反映CPU速率的下降。注意在最后统计时，使用没有减少的slot。
-func cpuPressureReduction(slot []float64, cpuUsage float64) {
+// 当高CPU压力时，减少实际slot数，反映CPU速率的下降。注意在最后统计时，使用没有减少的slot。返回时间片缩减后的比例，
+// 没有缩减时为1。
+func cpuPressureReduction(slot []float64, cpuUsage float64) float64 {
 	// 只在大于0.7时触发
 	if cpuUsage >= 0.7 {
 		// 线性变化，减少时间片
@@ -258,5 +363,15 @@ func cpuPressureReduction(slot []float64, cpuUsage float64) {
 		for i := 0; i < len(slot); i++ {
 			slot[i] *= reduction
 		}
+		return reduction
+	}
+	return 1
+}
+
+func sumSlots(slot []float64) float64 {
+	sum := float64(0)
+	for _, s := range slot {
+		sum += s
 	}
+	return sum
 }
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util/forkjoin"
+	"github.com/sirupsen/logrus"
+)
+
+// nodeTaskLeafSize 每个叶子任务顺序更新的节点数量。节点的update耗时较短，过小的任务将使线程调度的开销大于并行的收益
+const nodeTaskLeafSize = 32
+
+// nodeTask 使用分治线程池并行更新节点的任务。同一个Tick中各个节点互不影响，各个节点的update可以并行执行，统计数据按照
+// nodes的顺序合并，因此与顺序更新的结果相同
+type nodeTask struct {
+	nodes []*Node
+	// skipIdle 是否跳过空闲的节点，用于事件驱动模式
+	skipIdle bool
+	// podMetrics 是否计算各个Pod的统计数据，只在设置了PodMetricsSink时需要
+	podMetrics bool
+}
+
+var _ forkjoin.Task = &nodeTask{}
+
+func (task *nodeTask) Fork() (tasks []forkjoin.Task) {
+	mid := len(task.nodes) / 2
+	return []forkjoin.Task{
+		&nodeTask{nodes: task.nodes[:mid], skipIdle: task.skipIdle, podMetrics: task.podMetrics},
+		&nodeTask{nodes: task.nodes[mid:], skipIdle: task.skipIdle, podMetrics: task.podMetrics},
+	}
+}
+
+func (task *nodeTask) IsLeaf() bool {
+	return len(task.nodes) <= nodeTaskLeafSize
+}
+
+func (task *nodeTask) Leaf() interface{} {
+	result := make([]*metrics.TickMetrics, 0, len(task.nodes))
+	for _, node := range task.nodes {
+		if task.skipIdle && node.Idle() {
+			result = append(result, &metrics.TickMetrics{})
+			continue
+		}
+		logrus.Debugf("Updating Node %s", node.Name)
+		result = append(result, node.update(task.podMetrics))
+	}
+	return result
+}
+
+func (task *nodeTask) Join(results []interface{}) interface{} {
+	result := make([]*metrics.TickMetrics, 0, len(task.nodes))
+	for _, r := range results {
+		result = append(result, r.([]*metrics.TickMetrics)...)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util/forkjoin"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func newTaskTestNode(name string) *Node {
+	return &Node{
+		Node:         *BuildNode(name, "4", "4G", "10", FairScheduler),
+		Scheduler:    &fairScheduler{},
+		Pods:         make(map[string]*Pod),
+		CpuState:     make([][]*RunEntity, 4),
+		deletingPods: make(map[string]*podDeletion),
+	}
+}
+
+func TestNodeTask(t *testing.T) {
+	nodes := make([]*Node, 0, 200)
+	for i := 0; i < 200; i++ {
+		node := newTaskTestNode(fmt.Sprintf("node-%03d", i))
+		// 只有偶数节点上有Pod，其负载为1
+		if i%2 == 0 {
+			alg := &deletePodAlgorithm{}
+			pod, _ := BuildPodUsingAlgorithm(fmt.Sprintf("pod-%03d", i), 1, 1, alg, v1.DefaultSchedulerName)
+			alg.pod = pod
+			pod.Status.Phase = v1.PodRunning
+			node.Pods[pod.Name] = pod
+		}
+		nodes = append(nodes, node)
+	}
+
+	task := &nodeTask{nodes: nodes, skipIdle: true}
+	result := forkjoin.NewSimpleForkJoinPool().Execute(task).([]*metrics.TickMetrics)
+	if len(result) != len(nodes) {
+		t.Fatalf("should have %d metrics, not %d", len(nodes), len(result))
+	}
+	for i, met := range result {
+		expected := float64(1 - i%2)
+		if met.Load != expected {
+			t.Errorf("node %s should have load %f, not %f", nodes[i].Name, expected, met.Load)
+		}
+		// 空闲节点被跳过，不需要发送请求
+		if i%2 == 0 && len(nodes[i].requests) != 1 {
+			t.Errorf("node %s should have 1 request, not %d", nodes[i].Name, len(nodes[i].requests))
+		}
+		if i%2 == 1 && len(nodes[i].requests) != 0 {
+			t.Errorf("idle node %s should have no request", nodes[i].Name)
+		}
+	}
+}
+
+func TestNodeTaskPodMetrics(t *testing.T) {
+	for _, podMetrics := range []bool{false, true} {
+		nodes := make([]*Node, 0, 20)
+		for i := 0; i < 20; i++ {
+			node := newTaskTestNode(fmt.Sprintf("node-%03d", i))
+			alg := &deletePodAlgorithm{}
+			pod, _ := BuildPodUsingAlgorithm(fmt.Sprintf("pod-%03d", i), 1, 1, alg, v1.DefaultSchedulerName)
+			alg.pod = pod
+			pod.Status.Phase = v1.PodRunning
+			node.Pods[pod.Name] = pod
+			nodes = append(nodes, node)
+		}
+
+		task := &nodeTask{nodes: nodes, podMetrics: podMetrics}
+		forkjoin.NewSimpleForkJoinPool().Execute(task)
+		// 没有设置PodMetricsSink时不计算Pod的统计数据
+		for _, node := range nodes {
+			if podMetrics && len(node.LastPodMetrics) != 1 {
+				t.Errorf("node %s should have 1 pod metrics, not %d", node.Name, len(node.LastPodMetrics))
+			}
+			if !podMetrics && node.LastPodMetrics != nil {
+				t.Errorf("node %s should not compute pod metrics", node.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	"sync"
+)
+
+// podRecorder 根据Pod事件记录各个Pod的调度过程。事件通知在其他线程中回调，因此需要上锁
+type podRecorder struct {
+	lock    sync.Mutex
+	tick    int
+	records map[string]*metrics.PodRecord
+	// order 按照创建顺序保存的记录
+	order []*metrics.PodRecord
+}
+
+func newPodRecorder() *podRecorder {
+	return &podRecorder{
+		records: make(map[string]*metrics.PodRecord),
+		order:   make([]*metrics.PodRecord, 0, 16),
+	}
+}
+
+func (r *podRecorder) setTick(tick int) {
+	r.lock.Lock()
+	r.tick = tick
+	r.lock.Unlock()
+}
+
+func (r *podRecorder) onAdd(pod *v1.Pod) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.records[pod.Name]; ok {
+		return
+	}
+	record := metrics.NewPodRecord(pod.Name, pod.Annotations[PodAnnotationAlgorithm], r.tick)
+	record.Phase = string(pod.Status.Phase)
+	r.records[pod.Name] = record
+	r.order = append(r.order, record)
+}
+
+func (r *podRecorder) onUpdate(pod *v1.Pod) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	record, ok := r.records[pod.Name]
+	if !ok {
+		return
+	}
+	record.Phase = string(pod.Status.Phase)
+
+	if pod.Spec.NodeName != "" && record.BindTick < 0 {
+		record.BindTick = r.tick
+		record.Node = pod.Spec.NodeName
+	}
+	if (pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed) && record.CompletionTick < 0 {
+		record.CompletionTick = r.tick
+	}
+}
+
+// onAttempt 记录一次调度尝试，err为nil代表调度成功。连续相同的失败原因只记录一次
+func (r *podRecorder) onAttempt(name string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	record, ok := r.records[name]
+	if !ok {
+		return
+	}
+	record.Attempts++
+	if err == nil {
+		return
+	}
+	reasons := record.UnschedulableReasons
+	if len(reasons) == 0 || reasons[len(reasons)-1] != err.Error() {
+		record.UnschedulableReasons = append(reasons, err.Error())
+	}
+}
+
+// getRecords 返回所有记录的副本
+func (r *podRecorder) getRecords() []*metrics.PodRecord {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	records := make([]*metrics.PodRecord, 0, len(r.order))
+	for _, record := range r.order {
+		clone := *record
+		clone.UnschedulableReasons = append([]string{}, record.UnschedulableReasons...)
+		records = append(records, &clone)
+	}
+	return records
+}
+
+// restore 使用快照中的记录替换所有记录
+func (r *podRecorder) restore(records []*metrics.PodRecord) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.records = make(map[string]*metrics.PodRecord)
+	r.order = make([]*metrics.PodRecord, 0, len(records))
+	for _, record := range records {
+		clone := *record
+		clone.UnschedulableReasons = append([]string{}, record.UnschedulableReasons...)
+		r.records[clone.Name] = &clone
+		r.order = append(r.order, &clone)
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestPodRecorder(t *testing.T) {
+	recorder := newPodRecorder()
+	pod := &v1.Pod{}
+	pod.Name = "pod"
+	pod.Annotations = map[string]string{PodAnnotationAlgorithm: "BatchPod"}
+	pod.Status.Phase = v1.PodPending
+
+	recorder.setTick(2)
+	recorder.onAdd(pod)
+	unschedulable := fmt.Errorf("0/1 nodes are available: 1 Insufficient cpu.")
+	recorder.onAttempt(pod.Name, unschedulable)
+	recorder.setTick(3)
+	recorder.onAttempt(pod.Name, unschedulable)
+
+	recorder.setTick(5)
+	recorder.onAttempt(pod.Name, nil)
+	pod.Spec.NodeName = "node"
+	pod.Status.Phase = v1.PodRunning
+	recorder.onUpdate(pod)
+
+	recorder.setTick(9)
+	pod.Status.Phase = v1.PodSucceeded
+	recorder.onUpdate(pod)
+	recorder.setTick(10)
+	recorder.onUpdate(pod)
+
+	records := recorder.getRecords()
+	if len(records) != 1 {
+		t.Fatalf("should have 1 record, not %d", len(records))
+	}
+	record := records[0]
+	if record.CreationTick != 2 || record.BindTick != 5 || record.CompletionTick != 9 {
+		t.Errorf("wrong ticks %+v", record)
+	}
+	if record.Attempts != 3 || len(record.UnschedulableReasons) != 1 || record.Node != "node" {
+		t.Errorf("wrong scheduling attempts %+v", record)
+	}
+	if record.Algorithm != "BatchPod" || record.Phase != string(v1.PodSucceeded) {
+		t.Errorf("wrong record %+v", record)
+	}
+
+	// 返回的是副本
+	records[0].UnschedulableReasons[0] = ""
+	if recorder.getRecords()[0].UnschedulableReasons[0] == "" {
+		t.Errorf("should return copies")
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"k8s.io/kubernetes/pkg/scheduler"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"k8s.io/kubernetes/pkg/scheduler/apis/config/scheme"
+)
+
+// LoadSchedulerConfig 读取YAML或JSON格式的KubeSchedulerConfiguration文件，支持v1alpha1与v1alpha2版本。读取时设置
+// Kubernetes的默认值，没有Profile时使用只有default-scheduler的默认Profile。
+func LoadSchedulerConfig(path string) (*schedulerapi.KubeSchedulerConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error reading scheduler config %s", path))
+	}
+	return ParseSchedulerConfig(data)
+}
+
+// ParseSchedulerConfig 解析YAML或JSON格式的KubeSchedulerConfiguration
+func ParseSchedulerConfig(data []byte) (*schedulerapi.KubeSchedulerConfiguration, error) {
+	obj, gvk, err := scheme.Codecs.UniversalDecoder().Decode(data, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding scheduler config")
+	}
+	config, ok := obj.(*schedulerapi.KubeSchedulerConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("couldn't decode as KubeSchedulerConfiguration, got %s", gvk)
+	}
+	if err = validateSchedulerConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// validateSchedulerConfig 检查模拟器使用的配置项，Kubernetes的其他配置项（如leader选举）在模拟器中没有作用
+func validateSchedulerConfig(config *schedulerapi.KubeSchedulerConfiguration) error {
+	if config.PercentageOfNodesToScore < 0 || config.PercentageOfNodesToScore > 100 {
+		return fmt.Errorf("percentageOfNodesToScore should be in [0, 100], not %d", config.PercentageOfNodesToScore)
+	}
+	names := make(map[string]bool)
+	for i, profile := range config.Profiles {
+		if profile.SchedulerName == "" {
+			return fmt.Errorf("profile %d has no schedulerName", i)
+		}
+		if names[profile.SchedulerName] {
+			return fmt.Errorf("duplicate profile %s", profile.SchedulerName)
+		}
+		names[profile.SchedulerName] = true
+	}
+	return nil
+}
+
+// schedulerOptions 将KubeSchedulerConfiguration转换为scheduler.New的参数。没有设置的配置项使用调度器的默认值
+func schedulerOptions(config *schedulerapi.KubeSchedulerConfiguration) []scheduler.Option {
+	if config == nil {
+		return nil
+	}
+	opts := []scheduler.Option{
+		scheduler.WithPercentageOfNodesToScore(config.PercentageOfNodesToScore),
+		scheduler.WithPreemptionDisabled(config.DisablePreemption),
+	}
+	if len(config.Profiles) > 0 {
+		opts = append(opts, scheduler.WithProfiles(config.Profiles...))
+	}
+	if config.AlgorithmSource.Provider != nil || config.AlgorithmSource.Policy != nil {
+		opts = append(opts, scheduler.WithAlgorithmSource(config.AlgorithmSource))
+	}
+	if config.BindTimeoutSeconds > 0 {
+		opts = append(opts, scheduler.WithBindTimeoutSeconds(config.BindTimeoutSeconds))
+	}
+	if config.PodInitialBackoffSeconds > 0 {
+		opts = append(opts, scheduler.WithPodInitialBackoffSeconds(config.PodInitialBackoffSeconds))
+	}
+	if config.PodMaxBackoffSeconds > 0 {
+		opts = append(opts, scheduler.WithPodMaxBackoffSeconds(config.PodMaxBackoffSeconds))
+	}
+	if len(config.Extenders) > 0 {
+		opts = append(opts, scheduler.WithExtenders(config.Extenders...))
+	}
+	return opts
+}
@@ -0,0 +1,69 @@
+package core
+
+import "testing"
+
+const testSchedulerConfig = `
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+percentageOfNodesToScore: 50
+profiles:
+- schedulerName: default-scheduler
+- schedulerName: no-balance
+  plugins:
+    score:
+      disabled:
+      - name: NodeResourcesBalancedAllocation
+`
+
+func TestParseSchedulerConfig(t *testing.T) {
+	config, err := ParseSchedulerConfig([]byte(testSchedulerConfig))
+	if err != nil {
+		t.Fatalf("parse config failed: %v", err)
+	}
+	if config.PercentageOfNodesToScore != 50 {
+		t.Errorf("percentageOfNodesToScore should be 50, not %d", config.PercentageOfNodesToScore)
+	}
+	if len(config.Profiles) != 2 {
+		t.Fatalf("should have 2 profiles, not %d", len(config.Profiles))
+	}
+	if config.Profiles[0].SchedulerName != "default-scheduler" || config.Profiles[1].SchedulerName != "no-balance" {
+		t.Errorf("wrong profile names %s, %s", config.Profiles[0].SchedulerName, config.Profiles[1].SchedulerName)
+	}
+	plugins := config.Profiles[1].Plugins
+	if plugins == nil || plugins.Score == nil || len(plugins.Score.Disabled) != 1 ||
+		plugins.Score.Disabled[0].Name != "NodeResourcesBalancedAllocation" {
+		t.Errorf("score plugin should be disabled in profile no-balance")
+	}
+
+	sim, err := NewSchedulerSimulatorWithConfig(10, config)
+	if err != nil {
+		t.Fatalf("create simulator with config failed: %v", err)
+	}
+	sim.Stop()
+}
+
+func TestParseSchedulerConfigInvalid(t *testing.T) {
+	cases := map[string]string{
+		"duplicate profile": `
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: a
+- schedulerName: a
+`,
+		"percentageOfNodesToScore": `
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+percentageOfNodesToScore: 101
+`,
+		"not a scheduler config": `
+apiVersion: v1
+kind: Pod
+`,
+	}
+	for name, data := range cases {
+		if _, err := ParseSchedulerConfig([]byte(data)); err == nil {
+			t.Errorf("%s: should fail", name)
+		}
+	}
+}
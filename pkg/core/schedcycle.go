@@ -0,0 +1,375 @@
+package core
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/kubernetes/pkg/scheduler"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scheduleTimeout 等待一个Pod调度结果的最长时间，仅用于防止调度器异常时模拟停滞
+const scheduleTimeout = 10 * time.Second
+
+var (
+	errPodSkipped  = fmt.Errorf("pod skipped by scheduler")
+	errPodDeleted  = fmt.Errorf("pod deleted while scheduling")
+	errPodTimedOut = fmt.Errorf("scheduling timed out")
+	errPermitWait  = fmt.Errorf("permit plugins returning Wait are not supported by the simulator")
+)
+
+// schedulingCycle 由模拟器驱动的调度循环。调度器的scheduleOne仍然在调度器线程中执行，但是调度器只能通过NextPod得到
+// 模拟器交给它的Pod，模拟器等待这个Pod调度失败或者绑定完成之后才交给下一个Pod。因此每个Tick的调度在更新节点之前同步完成，
+// 每个Tick调度的Pod数量由budget决定，而与实际运行时间无关。
+//
+// 待调度队列由模拟器自己维护，调度器内部的调度队列被替换为simQueue，Pod不会再进入其中。
+type schedulingCycle struct {
+	sim   *schedSim
+	sched *scheduler.Scheduler
+	// budget 每个Tick最多尝试调度的次数，0代表不限制
+	budget int
+	// latency 调度延迟模型，为nil时调度不消耗模拟时间
+	latency SchedulingLatency
+	// clock 调度器空闲的模拟时间，单位为Tick
+	clock float64
+	// tracer 记录每次调度尝试的决策过程，为nil时不记录
+	tracer *decisionTracer
+	// algorithm 替换了调度器的调度算法，用于在确定性模式下设置调度器使用的随机数种子
+	algorithm *seededAlgorithm
+
+	// timeout 等待一个Pod调度结果的最长时间
+	timeout time.Duration
+
+	next   chan *framework.PodInfo
+	result chan error
+	stopCh <-chan struct{}
+
+	// lock 保护以下字段，它们会在调度器线程与绑定线程中访问
+	lock sync.Mutex
+	// inflight 正在调度的Pod，没有则为nil
+	inflight *v1.Pod
+	// running 调度器是否正在执行inflight的调度周期
+	running bool
+	// cycleErr 调度周期中的调度错误，在调度周期结束之后通知模拟器
+	cycleErr error
+	// seq 各个待调度Pod进入队列的序号。同优先级的Pod按照序号调度，调度失败的Pod重新排到队尾
+	seq     map[string]int64
+	nextSeq int64
+	// aborted 等待超时的Pod。调度器之后仍然可能完成这些Pod的调度，它们的绑定请求将被拒绝，使其留在待调度队列中
+	aborted map[string]bool
+}
+
+func newSchedulingCycle(sim *schedSim, sched *scheduler.Scheduler, stopCh <-chan struct{}) *schedulingCycle {
+	c := &schedulingCycle{
+		sim:     sim,
+		sched:   sched,
+		timeout: scheduleTimeout,
+		next:    make(chan *framework.PodInfo),
+		result:  make(chan error, 1),
+		stopCh:  stopCh,
+		seq:     make(map[string]int64),
+		aborted: make(map[string]bool),
+	}
+	sched.NextPod = c.nextPod
+	sched.Error = c.onError
+	sched.SchedulingQueue = &simQueue{schedulingQueue: sched.SchedulingQueue}
+	c.algorithm = &seededAlgorithm{ScheduleAlgorithm: sched.Algorithm}
+	sched.Algorithm = c.algorithm
+
+	sim.InformerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.lock.Lock()
+			c.enqueue(obj.(*v1.Pod).Name)
+			c.lock.Unlock()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			pod := newObj.(*v1.Pod)
+			if pod.Spec.NodeName == "" {
+				return
+			}
+			c.lock.Lock()
+			delete(c.seq, pod.Name)
+			c.finish(pod.Name, nil)
+			c.lock.Unlock()
+		},
+		DeleteFunc: func(obj interface{}) {
+			pod := obj.(*v1.Pod)
+			c.lock.Lock()
+			delete(c.seq, pod.Name)
+			c.finish(pod.Name, errPodDeleted)
+			c.lock.Unlock()
+		},
+	})
+	return c
+}
+
+// enqueue 将Pod排到待调度队列的队尾，需要持有锁
+func (c *schedulingCycle) enqueue(name string) {
+	c.seq[name] = c.nextSeq
+	c.nextSeq++
+}
+
+// finish 通知模拟器inflight的调度结果，err为nil代表已经绑定。需要持有锁
+func (c *schedulingCycle) finish(name string, err error) {
+	if c.inflight == nil || c.inflight.Name != name {
+		return
+	}
+	c.inflight = nil
+	c.cycleErr = nil
+	c.result <- err
+}
+
+// nextPod 替换调度器的NextPod，在调度器线程中调用。调用时上一个Pod的调度周期已经结束。running在调度器收到Pod时才设置，
+// 因此等待超时的Pod的调度周期结束时，不会误判模拟器已经交出的下一个Pod
+func (c *schedulingCycle) nextPod() *framework.PodInfo {
+	c.lock.Lock()
+	if c.running && c.inflight != nil {
+		if c.cycleErr != nil {
+			c.finish(c.inflight.Name, c.cycleErr)
+		} else if assumed, _ := c.sched.SchedulerCache.IsAssumedPod(c.inflight); !assumed && !c.isBound(c.inflight.Name) {
+			// 既没有调度失败，也没有开始绑定，说明调度器跳过了这个Pod
+			c.finish(c.inflight.Name, errPodSkipped)
+		} else {
+			// 否则正在绑定，等待绑定事件
+			c.rejectWaiting(c.inflight)
+		}
+	}
+	c.running = false
+	c.lock.Unlock()
+
+	select {
+	case podInfo := <-c.next:
+		c.lock.Lock()
+		c.running = true
+		c.lock.Unlock()
+		return podInfo
+	case <-c.stopCh:
+		return nil
+	}
+}
+
+// onError 替换调度器的Error。调度周期中的错误在调度周期结束之后再通知模拟器，以保证调度器更新Pod状态之后模拟器才继续，
+// 绑定周期中的错误则立即通知
+func (c *schedulingCycle) onError(podInfo *framework.PodInfo, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.inflight == nil || c.inflight.Name != podInfo.Pod.Name {
+		return
+	}
+	if c.running {
+		c.cycleErr = err
+	} else {
+		c.finish(podInfo.Pod.Name, err)
+	}
+}
+
+// rejectWaiting 拒绝等待Permit插件批准的Pod。调度器的Permit等待超时使用现实时间，而等待期间模拟时间不会前进，因此不支持
+// 返回Wait的Permit插件，这些Pod作为调度失败处理，由绑定线程通过onError通知模拟器。需要持有锁
+func (c *schedulingCycle) rejectWaiting(pod *v1.Pod) {
+	prof, ok := c.sched.Profiles[pod.Spec.SchedulerName]
+	if !ok {
+		return
+	}
+	if waiting := prof.Framework.GetWaitingPod(pod.UID); waiting != nil {
+		logrus.Warnf("Rejecting pod %s waiting on permit plugins %v", pod.Name, waiting.GetPendingPlugins())
+		waiting.Reject(errPermitWait.Error())
+	}
+}
+
+func (c *schedulingCycle) isBound(name string) bool {
+	item, exist, _ := c.sim.Pods.GetByKey(name)
+	return exist && item.(*Pod).Spec.NodeName != ""
+}
+
+// pendingPods 返回由调度器负责的所有待调度Pod，按照优先级从高到低、进入队列的顺序排列
+func (c *schedulingCycle) pendingPods() []*v1.Pod {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pods := make([]*v1.Pod, 0)
+	for _, item := range c.sim.Pods.List() {
+		pod := &item.(*Pod).Pod
+		if pod.Status.Phase != v1.PodPending || pod.Spec.NodeName != "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := c.sched.Profiles[pod.Spec.SchedulerName]; !ok {
+			continue
+		}
+		if _, ok := c.seq[pod.Name]; !ok {
+			c.enqueue(pod.Name)
+		}
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		pi, pj := podPriority(pods[i]), podPriority(pods[j])
+		if pi != pj {
+			return pi > pj
+		}
+		return c.seq[pods[i].Name] < c.seq[pods[j].Name]
+	})
+	return pods
+}
+
+func podPriority(pod *v1.Pod) int32 {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority
+	}
+	return 0
+}
+
+// schedule 在第tick个Tick中依次调度待调度的Pod，每个Pod最多尝试一次，直到所有Pod都尝试过、达到budget或者调度器在本Tick
+// 中没有剩余的时间。返回尝试调度的次数
+func (c *schedulingCycle) schedule(tick int) int {
+	if c.clock < float64(tick) {
+		c.clock = float64(tick)
+	}
+	attempts := 0
+	for _, pod := range c.pendingPods() {
+		if c.budget > 0 && attempts >= c.budget {
+			break
+		}
+		if c.clock >= float64(tick+1) {
+			break
+		}
+		// 之前的调度可能已经删除或者改变了这个Pod
+		item, exist, _ := c.sim.Pods.GetByKey(pod.Name)
+		if !exist || item.(*Pod).Spec.NodeName != "" {
+			continue
+		}
+		scheduling := item.(*Pod).Pod.DeepCopy()
+		if c.algorithm.seeded {
+			c.algorithm.seed = c.sim.random.Int63()
+		}
+		start := time.Now()
+		err := c.scheduleOne(scheduling.DeepCopy())
+		attempts++
+		if c.latency != nil {
+			c.clock += c.latency.Latency(scheduling, time.Since(start))
+		}
+		c.sim.podRecorder.onAttempt(pod.Name, err)
+		if c.tracer != nil {
+			node := ""
+			if item, exist, _ := c.sim.Pods.GetByKey(pod.Name); exist && err == nil {
+				node = item.(*Pod).Spec.NodeName
+			}
+			c.tracer.trace(tick, scheduling, node, err)
+		}
+		if err != nil {
+			logrus.Warnf("Pod %s scheduled failed: %v", pod.Name, err)
+			c.lock.Lock()
+			if _, ok := c.seq[pod.Name]; ok {
+				c.enqueue(pod.Name)
+			}
+			c.lock.Unlock()
+		}
+	}
+	return attempts
+}
+
+// scheduleOne 将Pod交给调度器，并等待调度结果
+func (c *schedulingCycle) scheduleOne(pod *v1.Pod) error {
+	c.lock.Lock()
+	c.inflight = pod
+	c.running = false
+	c.cycleErr = nil
+	delete(c.aborted, pod.Name)
+	c.lock.Unlock()
+
+	timeout := time.After(c.timeout)
+	select {
+	case c.next <- &framework.PodInfo{Pod: pod, Timestamp: time.Now(), InitialAttemptTimestamp: time.Now()}:
+	case <-timeout:
+		return c.abort()
+	}
+	select {
+	case err := <-c.result:
+		return err
+	case <-timeout:
+		return c.abort()
+	}
+}
+
+// abort 放弃等待inflight的调度结果。调度器仍在处理的Pod之后不能再绑定，否则模拟器已经继续运行，绑定发生的Tick取决于
+// 调度器实际运行的时间
+func (c *schedulingCycle) abort() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pod := c.inflight
+	c.inflight = nil
+	c.running = false
+	c.cycleErr = nil
+	select {
+	case err := <-c.result:
+		return err
+	default:
+		logrus.Errorf("Scheduling pod %s timed out after %v", pod.Name, c.timeout)
+		c.aborted[pod.Name] = true
+		return errPodTimedOut
+	}
+}
+
+// checkBinding 在绑定Pod之前调用，拒绝等待超时的Pod的绑定
+func (c *schedulingCycle) checkBinding(name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.aborted[name] {
+		return fmt.Errorf("scheduling of pod %s has timed out", name)
+	}
+	return nil
+}
+
+// schedulingQueue 调度器内部的调度队列的方法，用于包装调度器的调度队列
+type schedulingQueue interface {
+	Add(pod *v1.Pod) error
+	AddUnschedulableIfNotPresent(pod *framework.PodInfo, podSchedulingCycle int64) error
+	SchedulingCycle() int64
+	Pop() (*framework.PodInfo, error)
+	Update(oldPod, newPod *v1.Pod) error
+	Delete(pod *v1.Pod) error
+	MoveAllToActiveOrBackoffQueue(event string)
+	AssignedPodAdded(pod *v1.Pod)
+	AssignedPodUpdated(pod *v1.Pod)
+	NominatedPodsForNode(nodeName string) []*v1.Pod
+	PendingPods() []*v1.Pod
+	Close()
+	UpdateNominatedPodForNode(pod *v1.Pod, nodeName string)
+	DeleteNominatedPodIfExists(pod *v1.Pod)
+	NumUnschedulablePods() int
+	Run()
+}
+
+// simQueue 替换调度器内部的调度队列。调度器只通过NextPod得到模拟器交给它的Pod，不会从调度队列中取出Pod，因此Pod的事件
+// 不再将Pod加入队列，否则队列将随着调度失败的Pod不断增长。调度器过滤节点时需要考虑被提名到节点上的高优先级Pod，因此
+// 仍然通过原来的队列记录被提名的Pod。
+type simQueue struct {
+	schedulingQueue
+}
+
+func (q *simQueue) Add(pod *v1.Pod) error {
+	if pod.Status.NominatedNodeName != "" {
+		q.UpdateNominatedPodForNode(pod, pod.Status.NominatedNodeName)
+	}
+	return nil
+}
+
+func (q *simQueue) AddUnschedulableIfNotPresent(*framework.PodInfo, int64) error {
+	return nil
+}
+
+func (q *simQueue) Update(oldPod, newPod *v1.Pod) error {
+	if newPod.Status.NominatedNodeName != "" {
+		q.UpdateNominatedPodForNode(newPod, newPod.Status.NominatedNodeName)
+	} else if oldPod.Status.NominatedNodeName != "" {
+		// 提名被撤销。两者都没有提名时，调度器可能刚刚在内存中提名了这个Pod，需要保留
+		q.DeleteNominatedPodIfExists(newPod)
+	}
+	return nil
+}
+
+func (q *simQueue) Delete(pod *v1.Pod) error {
+	q.DeleteNominatedPodIfExists(pod)
+	return nil
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	schedulercore "k8s.io/kubernetes/pkg/scheduler/core"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	"k8s.io/kubernetes/pkg/scheduler/profile"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// nodeOrderPlugin 模拟器在每个Profile中启用的PreScore插件，自定义插件不能使用这个名称
+const nodeOrderPlugin = "SimulatorNodeOrder"
+
+// nodeOrder 将通过过滤的节点按照名称排序。调度器使用16个线程并发运行Filter插件，通过过滤的节点的顺序取决于各个线程完成的
+// 先后，而调度器在得分相同的节点中选择时依赖这个顺序。PreScore插件得到的节点列表与之后打分和选择节点使用的是同一个切片，
+// 因此在这里原地排序之后，选择结果只取决于随机数。
+type nodeOrder struct {
+}
+
+func (p *nodeOrder) Name() string {
+	return nodeOrderPlugin
+}
+
+func (p *nodeOrder) PreScore(_ context.Context, _ *framework.CycleState, _ *v1.Pod, nodes []*v1.Node) *framework.Status {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nil
+}
+
+// schedulerRegistry 返回构造调度器使用的out-of-tree插件，包括注册的自定义插件与nodeOrder
+func schedulerRegistry() framework.Registry {
+	registry := framework.Registry{
+		nodeOrderPlugin: func(_ *runtime.Unknown, _ framework.FrameworkHandle) (framework.Plugin, error) {
+			return &nodeOrder{}, nil
+		},
+	}
+	for name, factory := range schedulerPluginRegistry {
+		registry[name] = factory
+	}
+	return registry
+}
+
+// withNodeOrder 返回在每个Profile中启用nodeOrder的配置副本，不修改config。config为nil或者没有Profile时，使用只有默认
+// Profile的配置，Profile中启用的插件与默认的插件合并，因此其他插件与默认配置相同
+func withNodeOrder(config *schedulerapi.KubeSchedulerConfiguration) *schedulerapi.KubeSchedulerConfiguration {
+	if config == nil {
+		config = &schedulerapi.KubeSchedulerConfiguration{}
+	} else {
+		config = config.DeepCopy()
+	}
+	if len(config.Profiles) == 0 {
+		config.Profiles = []schedulerapi.KubeSchedulerProfile{{SchedulerName: v1.DefaultSchedulerName}}
+	}
+	for i := range config.Profiles {
+		prof := &config.Profiles[i]
+		if prof.Plugins == nil {
+			prof.Plugins = &schedulerapi.Plugins{}
+		}
+		if prof.Plugins.PreScore == nil {
+			prof.Plugins.PreScore = &schedulerapi.PluginSet{}
+		}
+		prof.Plugins.PreScore.Enabled = append(prof.Plugins.PreScore.Enabled, schedulerapi.Plugin{Name: nodeOrderPlugin})
+	}
+	return config
+}
+
+// globalRandLock 调度器在得分相同的节点中随机选择时使用math/rand的全局随机数生成器。所有模拟器的调度算法在持有该锁时运行，
+// 使同时运行的多个模拟器不会交替使用全局随机数
+var globalRandLock sync.Mutex
+
+// seededAlgorithm 包装调度器的调度算法。确定性模式下，每次调度之前使用模拟器的随机数生成器得到的种子重新设置全局随机数，
+// 使调度器的随机选择只取决于模拟器自己的种子
+type seededAlgorithm struct {
+	schedulercore.ScheduleAlgorithm
+	// seeded 是否在下一次调度之前设置全局随机数的种子
+	seeded bool
+	// seed 下一次调度使用的种子。模拟器线程在将Pod交给调度器之前设置，调度器线程在收到Pod之后读取
+	seed int64
+}
+
+func (a *seededAlgorithm) Schedule(ctx context.Context, prof *profile.Profile, state *framework.CycleState,
+	pod *v1.Pod) (schedulercore.ScheduleResult, error) {
+	globalRandLock.Lock()
+	defer globalRandLock.Unlock()
+	if a.seeded {
+		rand.Seed(a.seed)
+	}
+	return a.ScheduleAlgorithm.Schedule(ctx, prof, state, pod)
+}
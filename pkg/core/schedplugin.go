@@ -0,0 +1,20 @@
+package core
+
+import (
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+)
+
+// schedulerPluginRegistry 模拟器之外实现的调度器插件，构造调度器时作为out-of-tree插件注册到调度框架中
+var schedulerPluginRegistry = framework.Registry{}
+
+// RegisterSchedulerPlugin 注册调度器框架的插件（Filter、Score、Reserve、Permit等），需要在构造模拟器之前调用。注册的插件
+// 需要在调度器配置的Profile中启用才会生效，插件的参数通过Profile的pluginConfig传入。名称不能与Kubernetes自带的插件相同，
+// 否则构造调度器时失败
+func RegisterSchedulerPlugin(name string, factory framework.PluginFactory) {
+	schedulerPluginRegistry[name] = factory
+}
+
+func GetSchedulerPluginFactory(name string) (factory framework.PluginFactory, exist bool) {
+	factory, exist = schedulerPluginRegistry[name]
+	return
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	framework "k8s.io/kubernetes/pkg/scheduler/framework/v1alpha1"
+	schedulernodeinfo "k8s.io/kubernetes/pkg/scheduler/nodeinfo"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testFilterPlugin   = "TestNodeFilter"
+	testPermitPlugin   = "TestWaitingPermit"
+	testBlockingPlugin = "TestBlockingFilter"
+)
+
+// nodeFilter 拒绝名称为node-1的节点
+type nodeFilter struct {
+}
+
+func (f *nodeFilter) Name() string {
+	return testFilterPlugin
+}
+
+func (f *nodeFilter) Filter(_ context.Context, _ *framework.CycleState, _ *v1.Pod,
+	nodeInfo *schedulernodeinfo.NodeInfo) *framework.Status {
+	if nodeInfo.Node().Name == "node-1" {
+		return framework.NewStatus(framework.Unschedulable, "rejected by test filter")
+	}
+	return nil
+}
+
+func TestRegisterSchedulerPlugin(t *testing.T) {
+	RegisterSchedulerPlugin(testFilterPlugin, func(_ *runtime.Unknown, _ framework.FrameworkHandle) (framework.Plugin, error) {
+		return &nodeFilter{}, nil
+	})
+	if _, exist := GetSchedulerPluginFactory(testFilterPlugin); !exist {
+		t.Fatalf("plugin %s should be registered", testFilterPlugin)
+	}
+	config, err := ParseSchedulerConfig([]byte(`
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    filter:
+      enabled:
+      - name: TestNodeFilter
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewSchedulerSimulatorWithConfig(10, config)
+	if err != nil {
+		t.Fatalf("create simulator with plugin failed: %v", err)
+	}
+	defer sim.Stop()
+
+	for _, name := range []string{"node-1", "node-2"} {
+		node := BuildNode(name, "8", "16G", "100", FairScheduler)
+		if _, err = sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		_, err = sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(),
+			newFakePod(fmt.Sprintf("pod-%d", i)), metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if attempts := sim.(*schedSim).cycle.schedule(0); attempts != 3 {
+		t.Errorf("should schedule 3 pods, not %d", attempts)
+	}
+
+	for i := 0; i < 3; i++ {
+		pod, err := sim.GetPod(fmt.Sprintf("pod-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pod.Spec.NodeName != "node-2" {
+			t.Errorf("pod %s should be bound to node-2, not %q", pod.Name, pod.Spec.NodeName)
+		}
+	}
+}
+
+// waitingPermit 让所有Pod等待一分钟
+type waitingPermit struct {
+}
+
+func (p *waitingPermit) Name() string {
+	return testPermitPlugin
+}
+
+func (p *waitingPermit) Permit(_ context.Context, _ *framework.CycleState, _ *v1.Pod, _ string) (*framework.Status,
+	time.Duration) {
+	return framework.NewStatus(framework.Wait, ""), time.Minute
+}
+
+func TestPermitWaitRejected(t *testing.T) {
+	RegisterSchedulerPlugin(testPermitPlugin, func(_ *runtime.Unknown, _ framework.FrameworkHandle) (framework.Plugin, error) {
+		return &waitingPermit{}, nil
+	})
+	config, err := ParseSchedulerConfig([]byte(`
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    permit:
+      enabled:
+      - name: TestWaitingPermit
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewSchedulerSimulatorWithConfig(10, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Stop()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err = sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod, err := sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(), newFakePod("pod-1"),
+		metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pod应当立即被拒绝，而不是等待Permit插件的现实时间超时
+	start := time.Now()
+	err = sim.(*schedSim).cycle.scheduleOne(pod)
+	if err == nil || !strings.Contains(err.Error(), errPermitWait.Error()) {
+		t.Errorf("waiting pod should be rejected, not %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > scheduleTimeout/2 {
+		t.Errorf("rejecting waiting pod took %v", elapsed)
+	}
+	if simPod, _ := sim.GetPod("pod-1"); simPod.Spec.NodeName != "" {
+		t.Errorf("pod should not be bound to %s", simPod.Spec.NodeName)
+	}
+}
+
+// blockingFilter 在release关闭之前阻塞名称为slow的Pod的过滤
+type blockingFilter struct {
+	release chan struct{}
+}
+
+func (f *blockingFilter) Name() string {
+	return testBlockingPlugin
+}
+
+func (f *blockingFilter) Filter(_ context.Context, _ *framework.CycleState, pod *v1.Pod,
+	_ *schedulernodeinfo.NodeInfo) *framework.Status {
+	if pod.Name == "slow" {
+		<-f.release
+	}
+	return nil
+}
+
+func TestScheduleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	RegisterSchedulerPlugin(testBlockingPlugin, func(_ *runtime.Unknown, _ framework.FrameworkHandle) (framework.Plugin, error) {
+		return &blockingFilter{release: release}, nil
+	})
+	config, err := ParseSchedulerConfig([]byte(`
+apiVersion: kubescheduler.config.k8s.io/v1alpha2
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    filter:
+      enabled:
+      - name: TestBlockingFilter
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewSchedulerSimulatorWithConfig(10, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Stop()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err = sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pods := make(map[string]*v1.Pod)
+	for _, name := range []string{"slow", "fast"} {
+		if pods[name], err = sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(),
+			newFakePod(name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cycle := sim.(*schedSim).cycle
+	cycle.timeout = 100 * time.Millisecond
+	if err = cycle.scheduleOne(pods["slow"]); err != errPodTimedOut {
+		t.Fatalf("scheduling should time out, not %v", err)
+	}
+
+	// 调度器之后完成了超时的Pod的调度，但是绑定被拒绝，下一个Pod的调度不受影响
+	close(release)
+	cycle.timeout = scheduleTimeout
+	if err = cycle.scheduleOne(pods["fast"]); err != nil {
+		t.Fatalf("pod after the timed out one should be scheduled, but %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if pod, _ := sim.GetPod("fast"); pod.Spec.NodeName != "node-1" {
+		t.Errorf("pod fast should be bound to node-1, not %q", pod.Spec.NodeName)
+	}
+	if pod, _ := sim.GetPod("slow"); pod.Spec.NodeName != "" {
+		t.Errorf("timed out pod should not be bound to %s", pod.Spec.NodeName)
+	}
+	// 调度器内部的调度队列不再保存待调度的Pod
+	if pending := sim.(*schedSim).Scheduler.SchedulingQueue.PendingPods(); len(pending) != 0 {
+		t.Errorf("scheduling queue of the scheduler should be empty, not %d pods", len(pending))
+	}
+}
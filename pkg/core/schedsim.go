@@ -7,6 +7,7 @@ import (
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/mock"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/util"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util/forkjoin"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
@@ -15,7 +16,11 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/kubernetes/pkg/scheduler"
-	"sync"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"math/rand"
+	"os"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	prefixed "github.com/x-cray/logrus-prefixed-formatter"
@@ -33,9 +38,31 @@ type SchedulerSimulator interface {
 	// 函数。
 	GetInformerFactory() k8sinformers.SharedInformerFactory
 
-	// Run 开始模拟，并将集群统计数据输出到标准输出
+	// Run 开始模拟，并将各个节点与整个集群的统计数据输出到MetricsSink与ClusterMetricsSink，默认输出到标准输出。模拟
+	// 运行到TotalTick或者满足停止条件时结束，之后关闭MetricsSink并停止调度器。调用Pause之后Run在当前Tick结束时返回，
+	// 再次调用Run、Step或RunUntil从下一个Tick继续运行。
 	Run()
 
+	// Step 运行n个Tick后返回实际运行的Tick数，模拟结束或调用Pause时提前返回。事件驱动模式下跳过的Tick不计算在内。
+	Step(n int) int
+
+	// RunUntil 运行直到predicate返回true，predicate在每个Tick结束之后调用，此时可以查看模拟器的状态。返回false代表
+	// 模拟已经结束或者调用了Pause。
+	RunUntil(predicate func(sim SchedulerSimulator) bool) bool
+
+	// Pause 使正在运行的Run、Step或RunUntil在当前Tick结束之后返回，可以在控制器或其他线程中调用
+	Pause()
+
+	// Stop 提前结束模拟，关闭MetricsSink并停止调度器。只使用Step与RunUntil运行且没有运行到TotalTick时，需要调用Stop
+	// 以输出缓冲中的统计数据。
+	Stop()
+
+	// Finished 模拟是否已经结束
+	Finished() bool
+
+	// AddStopCondition 添加停止条件，每个Tick结束之后检查，任意一个条件满足时模拟提前结束
+	AddStopCondition(conditions ...StopCondition)
+
 	// RegisterBeforeUpdateController 注册新的控制器，控制器将会在Node的Tick之前得到调用，通常用于维护集群状态，调用服务
 	// 等功能。
 	RegisterBeforeUpdateController(controller Controller)
@@ -51,6 +78,79 @@ type SchedulerSimulator interface {
 
 	// GetPod 获取实际创建的Pod，以让控制器得以控制其行为，如分配负载等
 	GetPod(name string) (*Pod, error)
+
+	// SetMetricsSinks 设置接收各个节点统计数据的MetricsSink，替换默认输出到标准输出的表格。不传入参数时不输出统计数据。
+	// 模拟结束时将关闭所有的MetricsSink。
+	SetMetricsSinks(sinks ...metrics.MetricsSink)
+
+	// SetClusterMetricsSinks 设置接收集群统计数据的ClusterMetricsSink，替换默认输出到标准输出的表格。不传入参数时不输出
+	// 集群统计数据。模拟结束时将关闭所有的ClusterMetricsSink。
+	SetClusterMetricsSinks(sinks ...metrics.ClusterMetricsSink)
+
+	// SetPodMetricsSinks 设置接收各个Pod每个Tick资源分配与使用情况的PodMetricsSink。默认不计算也不输出Pod统计数据。模拟结束时
+	// 将关闭所有的PodMetricsSink。
+	SetPodMetricsSinks(sinks ...metrics.PodMetricsSink)
+
+	// SetDecisionSinks 设置接收每次调度尝试决策过程的DecisionSink，包括各个候选节点被哪个Filter插件拒绝，以及各个Score
+	// 插件的原始分数与归一化分数。默认不记录，记录时每次调度尝试都需要重新运行调度器的插件。模拟结束时将关闭所有的DecisionSink。
+	SetDecisionSinks(sinks ...metrics.DecisionSink)
+
+	// SetDeterministic 开启确定性模式。模拟器的随机数生成器使用seed初始化，调度器在得分相同的节点中随机选择时使用的种子
+	// 也由它生成，使得相同seed的两次运行输出相同的统计数据，即使有其他模拟器同时运行。需要在Run之前调用。
+	//
+	// 调度器在找到percentageOfNodesToScore比例的可用节点之后停止过滤，而并发过滤时先找到哪些节点是不确定的，因此集群有
+	// 100个以上的节点时，需要在调度器配置中将percentageOfNodesToScore设置为100。抢占时在条件相同的节点中的选择也是不确定的。
+	SetDeterministic(seed int64)
+
+	// SetSchedulingBudget 设置每个Tick调度器最多尝试调度的次数，用于模拟调度器的吞吐量。超出的Pod留在待调度队列中，在之后
+	// 的Tick调度。budget为0时不限制，每个Tick调度所有待调度的Pod。
+	SetSchedulingBudget(budget int)
+
+	// SetSchedulingLatency 设置调度延迟模型。每次调度尝试消耗模型给出的Tick数，调度器在一个Tick中的时间用完之后，剩余的
+	// Pod留在待调度队列中。latency为nil时调度不消耗时间。measured模型使用调度器实际运行的时间，每次运行的结果都不同，
+	// 因此不能在确定性模式下使用。
+	SetSchedulingLatency(latency SchedulingLatency)
+
+	// SetTickDuration 设置一个Tick对应的模拟时间，默认为DefaultTickDuration。需要在Run之前调用。
+	SetTickDuration(duration time.Duration)
+
+	// SetEventDriven 开启事件驱动模式。模拟器只执行有事件发生的Tick：Pod创建与结束、节点加入与删除、控制器唤醒（见
+	// WakeUpController），以及存在活跃节点时的下一个Tick，并且只更新有Pod的节点，跳过其余的Tick与空闲节点。统计数据只在
+	// 执行的Tick中输出，跳过的Tick按照空闲节点的数据计入窗口平均值。需要在Run之前调用。
+	SetEventDriven(enabled bool)
+
+	// SetParallelNodeUpdate 设置是否并行更新节点，默认开启。并行更新时各个节点的Tick在分治线程池中执行，之后按照节点名称
+	// 的顺序发送请求并合并统计数据，因此结果与顺序更新相同。开启时CoreScheduler与PodAlgorithm的Tick需要能够在不同节点
+	// 上并发调用。
+	SetParallelNodeUpdate(enabled bool)
+
+	// GetClock 获取模拟时钟。控制器可以通过它得到当前的模拟时间与Tick序号，需要模拟时间的PodAlgorithm可以
+	// 实现ClockSetter接口得到模拟时钟，CoreScheduler则通过CoreSchedulerFactory得到。
+	GetClock() Clock
+
+	// GetRand 获取模拟器的随机数生成器，控制器等需要随机数时应当使用它，以便确定性模式下可以重现。不是线程安全的，只应在
+	// 控制器的Tick中使用
+	GetRand() *rand.Rand
+
+	// GetPodRecords 获取各个Pod从创建、绑定到结束的调度记录，按照创建顺序排列
+	GetPodRecords() []*metrics.PodRecord
+
+	// DiagnoseUnschedulable 按照最后一次调度失败的原因将调度失败过的Pod分组，统计重试次数，并根据当前各个节点的空闲资源
+	// 计算使每组仍未绑定的Pod能够调度所需增加的最小节点容量。应该在Run结束之后调用
+	DiagnoseUnschedulable() *metrics.UnschedulableReport
+
+	// Snapshot 保存模拟器的完整状态，包括节点、Pod及其PodAlgorithm的状态、StatefulController的状态、统计数据与调度
+	// 队列。应该在Run结束之后或者AfterUpdate控制器中调用，恢复之后从下一个Tick开始运行。
+	Snapshot() (*Snapshot, error)
+
+	// Restore 将快照恢复到新构造的模拟器中。模拟器需要已经注册了与保存快照时相同的控制器，并且没有Pod。已经存在的同名
+	// 节点将恢复其状态，快照中没有的节点将被删除。恢复之后Run从快照的Tick开始运行到TotalTick。随机数生成器的状态不会
+	// 保存，确定性模式下从同一快照恢复的多次运行结果相同，但与没有中断的运行不同。
+	Restore(snapshot *Snapshot) error
+
+	// Fork 将模拟器当前的状态复制到各个分支的新模拟器中，并发运行所有分支，返回各个分支的统计结果。分支之间以及与本模拟
+	// 器之间互不影响，调用的时机与Snapshot相同。确定性模式下各个分支使用本模拟器的种子，结果可以复现。
+	Fork(branches ...*Branch) ([]*BranchResult, error)
 }
 
 type schedSim struct {
@@ -62,9 +162,30 @@ type schedSim struct {
 	Scheduler             *scheduler.Scheduler
 	InformerFactory       k8sinformers.SharedInformerFactory
 	cancelFunc            context.CancelFunc
+	// queue 本模拟器的资源变化事件的消息队列，使同一进程中的多个模拟器互不影响
+	queue util.MessageQueue
+	// schedulerName 不为空时，所有新建的Pod使用该调度器Profile，用于分支模拟器
+	schedulerName string
+	// schedulerConfig 构造调度器使用的配置，为nil时使用默认配置
+	schedulerConfig *schedulerapi.KubeSchedulerConfiguration
+
+	// cycle 每个Tick驱动调度器调度待调度的Pod
+	cycle *schedulingCycle
 
 	// 当前的时钟周期数
 	tick int
+	// resumeTick Run开始运行的Tick，每个Tick的节点更新完成之后更新为下一个Tick，用于保存快照
+	resumeTick int
+	// clock 模拟时钟，每个Tick开始时前进
+	clock *simClock
+	// eventDriven 是否处于事件驱动模式
+	eventDriven bool
+	// events 事件驱动模式下唤醒模拟器的事件
+	events *eventQueue
+	// parallel 是否并行更新节点
+	parallel bool
+	// pool 并行更新节点使用的分治线程池
+	pool forkjoin.Pool
 	// 总运行时钟周期数
 	TotalTick int
 
@@ -73,6 +194,41 @@ type schedSim struct {
 
 	// AfterUpdate 在更新Pod状态之后调用的控制器函数，通常用于监控统计等
 	afterUpdate []Controller
+
+	// metricsSink 接收每个Tick各个节点的统计数据
+	metricsSink metrics.MetricsSink
+
+	// clusterSink 接收每个Tick整个集群的统计数据
+	clusterSink metrics.ClusterMetricsSink
+
+	// podSink 接收每个Tick各个Pod的统计数据，为nil时不输出
+	podSink metrics.PodMetricsSink
+
+	// nodeAggregators 各个节点的统计器，键为节点名称
+	nodeAggregators map[string]metrics.Aggregator
+
+	// clusterAggregator 集群的统计器
+	clusterAggregator metrics.ClusterAggregator
+
+	// branchAggregator 分支的集群统计器，只统计分支开始之后的Tick，不是分支时为nil
+	branchAggregator metrics.ClusterAggregator
+
+	// podRecorder 记录各个Pod的调度过程
+	podRecorder *podRecorder
+
+	// stopConditions 模拟提前结束的条件
+	stopConditions []StopCondition
+	// paused 不为0时Run在当前Tick结束后返回
+	paused int32
+	// finished 模拟是否已经结束
+	finished bool
+
+	// random 模拟器的随机数生成器
+	random *rand.Rand
+	// deterministic 是否处于确定性模式
+	deterministic bool
+	// seed 确定性模式的种子
+	seed int64
 }
 
 var _ SchedulerSimulator = &schedSim{}
@@ -132,121 +288,502 @@ func init() {
 	})
 }
 
-// NewSchedulerSimulator 创建一个新的集群。totalTick为模拟集群的总运行周期。
+// NewSchedulerSimulator 创建一个新的集群。totalTick为模拟集群的总运行周期。调度器使用默认的配置。
 func NewSchedulerSimulator(totalTick int) SchedulerSimulator {
+	sim, err := NewSchedulerSimulatorWithConfig(totalTick, nil)
+	if err != nil {
+		panic(err)
+	}
+	return sim
+}
+
+// NewSchedulerSimulatorWithConfig 创建使用指定调度器配置的集群，config可以由LoadSchedulerConfig读取。配置中的各个Profile
+// 根据Pod的spec.schedulerName选择，Profile中启用与禁用的插件与默认的插件合并。config为nil时使用默认的配置。
+func NewSchedulerSimulatorWithConfig(totalTick int, config *schedulerapi.KubeSchedulerConfiguration) (SchedulerSimulator, error) {
+	if config != nil {
+		if err := validateSchedulerConfig(config); err != nil {
+			return nil, err
+		}
+	}
 	rootCtx, cancel := context.WithCancel(context.Background())
 	sim := &schedSim{
 		Client:                nil,
 		Nodes:                 cache.NewStore(NodeKeyFunc),
 		DeploymentControllers: nil,
-		PriorityClasses:       nil,
+		PriorityClasses:       cache.NewStore(PriorityClassKeyFucn),
 		Pods:                  cache.NewStore(PodKeyFunc),
 		Scheduler:             nil,
 		TotalTick:             totalTick,
 		cancelFunc:            cancel,
+		queue:                 util.NewSynchronizedMessageQueue(),
+		metricsSink:           metrics.NewTableSink(os.Stdout),
+		clusterSink:           metrics.NewClusterTableSink(os.Stdout),
+		nodeAggregators:       make(map[string]metrics.Aggregator),
+		clusterAggregator:     metrics.NewClusterAggregator(),
+		podRecorder:           newPodRecorder(),
+		random:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		clock:                 newSimClock(DefaultTickDuration),
+		events:                newEventQueue(),
+		parallel:              true,
+		pool:                  forkjoin.NewSimpleForkJoinPool(),
 	}
 
 	client, err := NewClient(sim)
 	if err != nil {
-		panic(fmt.Sprintf("error create client: %s", err))
+		cancel()
+		return nil, errors.Wrap(err, "error creating client")
 	}
 	sim.Client = client
-	sim.InformerFactory = informers.NewSharedInformerFactory(client)
+	sim.InformerFactory = informers.NewSharedInformerFactory(client, sim.queue)
 	// explicitly trigger the creation of these informers, and then start the factory to let the informer subscribe
 	sim.InformerFactory.Core().V1().Nodes().Informer()
 	sim.InformerFactory.Core().V1().Pods().Informer()
 	sim.InformerFactory.Start(rootCtx.Done())
-	<-time.After(10 * time.Millisecond) // ensure informer topic subscription.
 
-	sched, err := buildScheduler(rootCtx, sim.InformerFactory, client)
+	sched, err := buildScheduler(rootCtx, sim.InformerFactory, client, config)
 	if err != nil {
-		panic(err)
+		cancel()
+		return nil, errors.Wrap(err, "error building scheduler")
 	}
+	sim.schedulerConfig = config
 	sim.Scheduler = sched
+	// 在调度循环之前注册Pod调度记录的监听器，保证调度循环得到绑定结果时调度记录已经更新
+	sim.InformerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			sim.podRecorder.onAdd(obj.(*v1.Pod))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			sim.podRecorder.onUpdate(newObj.(*v1.Pod))
+		},
+	})
+	sim.watchEvents()
+	// 调度器线程只会调度模拟器在Run中交给它的Pod
+	sim.cycle = newSchedulingCycle(sim, sched, rootCtx.Done())
 	go sim.Scheduler.Run(rootCtx)
 
-	return sim
+	return sim, nil
 }
 
-func buildScheduler(ctx context.Context, factory k8sinformers.SharedInformerFactory, client kubernetes.Interface) (*scheduler.Scheduler, error) {
+func buildScheduler(ctx context.Context, factory k8sinformers.SharedInformerFactory, client kubernetes.Interface,
+	config *schedulerapi.KubeSchedulerConfiguration) (*scheduler.Scheduler, error) {
 	podInformer := factory.Core().V1().Pods()
-	return scheduler.New(client, factory, podInformer, mock.SimRecorderFactory, ctx.Done())
+	opts := append(schedulerOptions(withNodeOrder(config)), scheduler.WithFrameworkOutOfTreeRegistry(schedulerRegistry()))
+	return scheduler.New(client, factory, podInformer, mock.SimRecorderFactory, ctx.Done(), opts...)
 }
 
-func (sim *schedSim) Run() {
-	defer sim.cancelFunc()
+func (sim *schedSim) SetMetricsSinks(sinks ...metrics.MetricsSink) {
+	sim.metricsSink = metrics.NewMultiSink(sinks...)
+}
+
+func (sim *schedSim) SetPodMetricsSinks(sinks ...metrics.PodMetricsSink) {
+	if len(sinks) == 0 {
+		sim.podSink = nil
+		return
+	}
+	sim.podSink = metrics.NewMultiPodSink(sinks...)
+}
+
+func (sim *schedSim) SetDecisionSinks(sinks ...metrics.DecisionSink) {
+	if len(sinks) == 0 {
+		sim.cycle.tracer = nil
+		return
+	}
+	sim.cycle.tracer = newDecisionTracer(sim.Scheduler, sim.schedulerConfig, metrics.NewMultiDecisionSink(sinks...))
+}
+
+func (sim *schedSim) SetDeterministic(seed int64) {
+	sim.deterministic = true
+	sim.seed = seed
+	sim.random = rand.New(rand.NewSource(seed))
+	sim.cycle.algorithm.seeded = true
+}
+
+func (sim *schedSim) SetSchedulingBudget(budget int) {
+	sim.cycle.budget = budget
+}
+
+func (sim *schedSim) SetSchedulingLatency(latency SchedulingLatency) {
+	sim.cycle.latency = latency
+}
+
+func (sim *schedSim) SetTickDuration(duration time.Duration) {
+	sim.clock.setTickDuration(duration)
+}
 
-	nodeMetrics := make(map[*Node]metrics.Aggregator)
+func (sim *schedSim) SetEventDriven(enabled bool) {
+	sim.eventDriven = enabled
+}
+
+func (sim *schedSim) SetParallelNodeUpdate(enabled bool) {
+	sim.parallel = enabled
+}
 
-	// 添加事件监听器以监听绑定事件
-	wg := sync.WaitGroup{}
-	newPods := map[string]bool{}
+// watchEvents 事件驱动模式下，将Pod与节点的变化加入事件队列，在下一个Tick唤醒模拟器
+func (sim *schedSim) watchEvents() {
+	push := func(what eventType) {
+		if sim.eventDriven {
+			sim.events.push(sim.clock.CurrentTick()+1, what)
+		}
+	}
 	sim.InformerFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			newPods[obj.(*v1.Pod).Name] = true
-			wg.Add(1)
+			push(eventPodArrival)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			pod := newObj.(*v1.Pod)
-			if _, ok := newPods[pod.Name]; ok {
-				delete(newPods, pod.Name)
-				defer wg.Done()
-				if pod.Spec.NodeName == "" {
-					for _, condition := range pod.Status.Conditions {
-						if condition.Reason == v1.PodReasonUnschedulable {
-							logrus.Warnf("Pod %s scheduled failed: %s", pod.Name, condition.Message)
-							break
-						}
-					}
-				}
+			oldPod, newPod := oldObj.(*v1.Pod), newObj.(*v1.Pod)
+			finished := newPod.Status.Phase == v1.PodSucceeded || newPod.Status.Phase == v1.PodFailed
+			if finished && oldPod.Status.Phase != newPod.Status.Phase {
+				push(eventPodCompletion)
 			}
 		},
-		DeleteFunc: nil,
+		DeleteFunc: func(obj interface{}) {
+			push(eventPodCompletion)
+		},
+	})
+	sim.InformerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			push(eventNodeChange)
+		},
+		DeleteFunc: func(obj interface{}) {
+			push(eventNodeChange)
+		},
 	})
+}
+
+// nextTick 返回下一个需要执行的Tick。事件驱动模式下跳过没有事件发生、没有活跃节点并且没有控制器需要唤醒的Tick
+func (sim *schedSim) nextTick(tick int) int {
+	if !sim.eventDriven {
+		return tick + 1
+	}
+	controllers := append(append([]Controller{}, sim.beforeUpdate...), sim.afterUpdate...)
+	for _, controller := range controllers {
+		next := tick + 1
+		if waker, ok := controller.(WakeUpController); ok {
+			next = waker.NextWakeUp(tick)
+		}
+		if next > tick {
+			sim.events.push(next, eventControllerWakeUp)
+		}
+	}
+	for _, node := range sim.sortedNodes() {
+		if !node.Idle() {
+			sim.events.push(tick+1, eventNodeActive)
+			break
+		}
+	}
 
-	for tick := 0; tick < sim.TotalTick; tick++ {
-		logrus.Infof("Tick %d", tick)
-		logrus.Debug("Running BeforeUpdate Controllers")
+	next, what := sim.events.next(tick)
+	if next < 0 {
+		return sim.TotalTick
+	}
+	if next > tick+1 {
+		logrus.Debugf("Skipping to tick %d on %v", next, what)
+	}
+	return next
+}
+
+func (sim *schedSim) GetClock() Clock {
+	return sim.clock
+}
+
+func (sim *schedSim) GetRand() *rand.Rand {
+	return sim.random
+}
+
+// sortedNodes 返回按照名称排序的节点，保证每次运行时节点的更新与统计顺序相同
+func (sim *schedSim) sortedNodes() []*Node {
+	list := sim.Nodes.List()
+	nodes := make([]*Node, 0, len(list))
+	for _, item := range list {
+		nodes = append(nodes, item.(*Node))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
 
-		for _, controller := range sim.beforeUpdate {
-			controller.Tick()
+// updateNodes 更新各个节点，返回按照nodes顺序排列的统计数据。节点的状态先在分治线程池中并行计算，之后在模拟器线程中
+// 按照nodes的顺序发送各个节点的请求，使监听器的调用顺序与顺序更新时相同
+func (sim *schedSim) updateNodes(nodes []*Node) []*metrics.TickMetrics {
+	task := &nodeTask{nodes: nodes, skipIdle: sim.eventDriven, podMetrics: sim.podSink != nil}
+	var result []*metrics.TickMetrics
+	if sim.parallel {
+		result = sim.pool.Execute(task).([]*metrics.TickMetrics)
+	} else {
+		result = task.Leaf().([]*metrics.TickMetrics)
+	}
+	for _, node := range nodes {
+		node.commit(sim.Client)
+	}
+	return result
+}
+
+func (sim *schedSim) GetPodRecords() []*metrics.PodRecord {
+	return sim.podRecorder.getRecords()
+}
+
+func (sim *schedSim) DiagnoseUnschedulable() *metrics.UnschedulableReport {
+	records := sim.GetPodRecords()
+	requests := make(map[string]*metrics.PodRequest)
+	for _, record := range records {
+		if record.BindTick >= 0 || len(record.UnschedulableReasons) == 0 {
+			continue
+		}
+		if pod, err := sim.GetPod(record.Name); err == nil {
+			requests[record.Name] = &metrics.PodRequest{Cpu: pod.CpuLimit, Mem: pod.MemLimit}
+		}
+	}
+	nodes := sim.sortedNodes()
+	resources := make([]*metrics.NodeResource, 0, len(nodes))
+	for _, node := range nodes {
+		resources = append(resources, node.NodeResource())
+	}
+	return metrics.DiagnoseUnschedulable(records, requests, resources)
+}
+
+func (sim *schedSim) SetClusterMetricsSinks(sinks ...metrics.ClusterMetricsSink) {
+	sim.clusterSink = metrics.NewMultiClusterSink(sinks...)
+}
+
+// clusterTickMetrics 统计集群的容量与Pod数量，使用量由各个节点本Tick的统计数据累加
+func (sim *schedSim) clusterTickMetrics(nodes []*Node, nodeTickMetrics []*metrics.TickMetrics) *metrics.ClusterTickMetrics {
+	met := &metrics.ClusterTickMetrics{Nodes: len(nodes)}
+	for i, node := range nodes {
+		coreCount, _ := node.Status.Capacity.Cpu().AsInt64()
+		memSize, _ := node.Status.Capacity.Memory().AsInt64()
+		met.CpuCapacity += float64(coreCount)
+		met.MemCapacity += memSize
+		met.CpuUsed += nodeTickMetrics[i].CpuUsed
+		met.MemUsed += nodeTickMetrics[i].MemUsed
+		cpu, mem := node.AllocatedResource()
+		met.CpuAllocated += cpu
+		met.MemAllocated += mem
+	}
+
+	for _, item := range sim.Pods.List() {
+		switch item.(*Pod).Status.Phase {
+		case v1.PodPending:
+			met.PendingPods++
+		case v1.PodRunning:
+			met.RunningPods++
+		case v1.PodSucceeded:
+			met.SucceededPods++
+		case v1.PodFailed:
+			met.FailedPods++
 		}
-		// 等待调度器线程bind
-		//wg.Wait()
-		util.WaitTimeout(&wg, time.Second)
-
-		logrus.Debug("Updating Node status")
-		nodes := sim.Nodes.List()
-		currentMetrics := make([]*metrics.PeriodMetrics, 0, len(nodes))
-		for _, item := range nodes {
-			node := item.(*Node)
-			logrus.Debugf("Updating Node %s", node.Name)
-			met := node.Tick(sim.Client)
-			aggregator, ok := nodeMetrics[node]
-			if !ok {
-				aggregator = metrics.NewAggregator()
-				nodeMetrics[node] = aggregator
+	}
+	return met
+}
+
+func (sim *schedSim) Run() {
+	sim.RunUntil(nil)
+}
+
+func (sim *schedSim) Step(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	count := 0
+	sim.RunUntil(func(SchedulerSimulator) bool {
+		count++
+		return count >= n
+	})
+	return count
+}
+
+func (sim *schedSim) RunUntil(predicate func(sim SchedulerSimulator) bool) bool {
+	atomic.StoreInt32(&sim.paused, 0)
+	for !sim.finished {
+		if sim.resumeTick >= sim.TotalTick {
+			sim.finish()
+			break
+		}
+		tick := sim.resumeTick
+		clusterMetrics := sim.runTick(tick)
+		sim.resumeTick = sim.nextTick(tick)
+		if sim.resumeTick < sim.TotalTick && sim.shouldStop(clusterMetrics) {
+			sim.finish()
+		} else {
+			end := sim.resumeTick
+			if end > sim.TotalTick {
+				end = sim.TotalTick
 			}
-			periodMetrics := aggregator.Aggregate(met)
-			currentMetrics = append(currentMetrics, periodMetrics)
+			sim.aggregateSkipped(end - tick - 1)
+			if sim.resumeTick >= sim.TotalTick {
+				sim.finish()
+			}
+		}
+		if predicate != nil && predicate(sim) {
+			return true
 		}
+		if atomic.LoadInt32(&sim.paused) != 0 {
+			return false
+		}
+	}
+	return false
+}
 
-		// 显示各个节点的状态
-		// 打印表头
-		fmt.Println("Node                \tCPU  \tCPUALL\tCPU60\tCPU300\tCPU1500\tMem  \tMemALL\tMem60\tMem300\tMem1500\tLoad \tLoadALL\tLoad60\tLoad300\tLoad1500")
-		for i, metric := range currentMetrics {
-			fmt.Printf("%-20s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", nodes[i].(*Node).Name,
-				metric.CpuUsageLastTick, metric.CpuUsageAverage, metric.CpuUsageAverageIn60Ticks, metric.CpuUsageAverageIn300Ticks, metric.CpuUsageAverageIn1500Ticks,
-				metric.MemUsageLastTick, metric.MemUsageAverage, metric.MemUsageAverageIn60Ticks, metric.MemUsageAverageIn300Ticks, metric.MemUsageAverageIn1500Ticks,
-				metric.LoadLastTick, metric.LoadAverage, metric.LoadAverageIn60Ticks, metric.LoadAverageIn300Ticks, metric.LoadAverageIn1500Ticks)
+// aggregateSkipped 将事件驱动模式下跳过的ticks个Tick纳入各个节点与集群的统计器，使各个时间窗口按照模拟的Tick计算。
+// 只有所有节点都空闲时才会跳过Tick，因此跳过的Tick中各个节点的统计数据与空闲节点相同
+func (sim *schedSim) aggregateSkipped(ticks int) {
+	if ticks <= 0 {
+		return
+	}
+	nodes := sim.sortedNodes()
+	idle := make([]*metrics.TickMetrics, len(nodes))
+	for i, node := range nodes {
+		idle[i] = &metrics.TickMetrics{}
+		if aggregator, ok := sim.nodeAggregators[node.Name]; ok {
+			aggregator.AggregateTicks(idle[i], ticks)
 		}
+	}
+	clusterTickMetrics := sim.clusterTickMetrics(nodes, idle)
+	sim.clusterAggregator.AggregateTicks(clusterTickMetrics, ticks)
+	if sim.branchAggregator != nil {
+		sim.branchAggregator.AggregateTicks(clusterTickMetrics, ticks)
+	}
+}
+
+func (sim *schedSim) Pause() {
+	atomic.StoreInt32(&sim.paused, 1)
+}
 
-		logrus.Debug("Running AfterUpdate Controllers")
-		// 运行后更新控制器
-		for _, controller := range sim.afterUpdate {
-			controller.Tick()
+func (sim *schedSim) Stop() {
+	if !sim.finished {
+		sim.finish()
+	}
+}
+
+func (sim *schedSim) Finished() bool {
+	return sim.finished
+}
+
+func (sim *schedSim) AddStopCondition(conditions ...StopCondition) {
+	sim.stopConditions = append(sim.stopConditions, conditions...)
+}
+
+// shouldStop 检查各个停止条件，所有条件都会被调用，以便有状态的条件记录每个Tick的数据
+func (sim *schedSim) shouldStop(m *metrics.ClusterMetrics) bool {
+	stop := false
+	for _, condition := range sim.stopConditions {
+		if condition(sim, m) {
+			stop = true
+		}
+	}
+	if stop {
+		logrus.Infof("Stop condition satisfied at tick %d", m.Tick)
+	}
+	return stop
+}
+
+// finish 结束模拟，关闭MetricsSink并停止调度器与线程池
+func (sim *schedSim) finish() {
+	sim.finished = true
+	if err := sim.metricsSink.Close(); err != nil {
+		logrus.Errorf("error closing metrics sink: %v", err)
+	}
+	if err := sim.clusterSink.Close(); err != nil {
+		logrus.Errorf("error closing cluster metrics sink: %v", err)
+	}
+	if sim.podSink != nil {
+		if err := sim.podSink.Close(); err != nil {
+			logrus.Errorf("error closing pod metrics sink: %v", err)
+		}
+	}
+	if sim.cycle.tracer != nil {
+		if err := sim.cycle.tracer.sink.Close(); err != nil {
+			logrus.Errorf("error closing decision sink: %v", err)
+		}
+	}
+	sim.pool.Shutdown()
+	sim.cancelFunc()
+}
+
+// runTick 运行第tick个Tick，返回该Tick的集群统计数据
+func (sim *schedSim) runTick(tick int) *metrics.ClusterMetrics {
+	logrus.Infof("Tick %d", tick)
+	sim.tick = tick
+	sim.clock.setTick(tick)
+	sim.podRecorder.setTick(tick)
+	logrus.Debug("Running BeforeUpdate Controllers")
+
+	for _, controller := range sim.beforeUpdate {
+		controller.Tick()
+	}
+	logrus.Debug("Scheduling pending Pods")
+	attempts := sim.cycle.schedule(tick)
+	logrus.Debugf("Tick %d: %d scheduling attempts", tick, attempts)
+
+	logrus.Debug("Updating Node status")
+	nodes := sim.sortedNodes()
+	nodeTickMetrics := sim.updateNodes(nodes)
+	currentMetrics := make([]*metrics.NodeMetrics, 0, len(nodes))
+	nodeResources := make([]*metrics.NodeResource, 0, len(nodes))
+	for i, node := range nodes {
+		met := nodeTickMetrics[i]
+		nodeResources = append(nodeResources, node.NodeResource())
+		aggregator, ok := sim.nodeAggregators[node.Name]
+		if !ok {
+			aggregator = metrics.NewAggregator()
+			sim.nodeAggregators[node.Name] = aggregator
+		}
+		currentMetrics = append(currentMetrics, &metrics.NodeMetrics{
+			Tick:          tick,
+			Node:          node.Name,
+			PeriodMetrics: aggregator.Aggregate(met),
+		})
+	}
+
+	// Pod在节点的Tick中结束，但是其状态在下一个Tick才会通知，因此在这里记录结束的Tick
+	for _, item := range sim.Pods.List() {
+		pod := item.(*Pod)
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			sim.podRecorder.onUpdate(&pod.Pod)
 		}
 	}
+
+	// 根据各个节点Tick之后尚未分配的资源计算碎片化指标
+	fragmentation, nodeFragmentation := metrics.Fragmentation(nodeResources)
+	for i, met := range currentMetrics {
+		met.NodeFragmentation = nodeFragmentation[i]
+	}
+
+	// 输出各个节点的状态
+	if err := sim.metricsSink.Write(tick, currentMetrics); err != nil {
+		logrus.Errorf("error writing metrics of tick %d: %v", tick, err)
+	}
+	clusterTickMetrics := sim.clusterTickMetrics(nodes, nodeTickMetrics)
+	clusterMetrics := &metrics.ClusterMetrics{
+		Tick:                 tick,
+		ClusterTickMetrics:   clusterTickMetrics,
+		ClusterPeriodMetrics: sim.clusterAggregator.Aggregate(clusterTickMetrics),
+		FragmentationMetrics: fragmentation,
+	}
+	if sim.branchAggregator != nil {
+		sim.branchAggregator.Aggregate(clusterTickMetrics)
+	}
+	err := sim.clusterSink.WriteCluster(clusterMetrics)
+	if err != nil {
+		logrus.Errorf("error writing cluster metrics of tick %d: %v", tick, err)
+	}
+	if sim.podSink != nil {
+		podMetrics := make([]*metrics.PodTickMetrics, 0, len(nodes))
+		for _, node := range nodes {
+			podMetrics = append(podMetrics, node.LastPodMetrics...)
+		}
+		if err = sim.podSink.WritePods(tick, podMetrics); err != nil {
+			logrus.Errorf("error writing pod metrics of tick %d: %v", tick, err)
+		}
+	}
+
+	sim.resumeTick = tick + 1
+	logrus.Debug("Running AfterUpdate Controllers")
+	// 运行后更新控制器
+	for _, controller := range sim.afterUpdate {
+		controller.Tick()
+	}
+	return clusterMetrics
 }
 
 type controllerTiming int
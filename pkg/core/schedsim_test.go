@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -87,6 +88,9 @@ func TestScheduleOne(t *testing.T) {
 	if err != nil {
 		t.Fatalf("create pod failed: %v", err)
 	}
+	if attempts := sim.(*schedSim).cycle.schedule(0); attempts != 1 {
+		t.Errorf("should schedule 1 pod, not %d", attempts)
+	}
 
 	select {
 	case <-bindCh:
@@ -95,6 +99,136 @@ func TestScheduleOne(t *testing.T) {
 	}
 }
 
+func TestSchedulingBudget(t *testing.T) {
+	sim := NewSchedulerSimulator(1000).(*schedSim)
+	defer sim.cancelFunc()
+	sim.SetSchedulingBudget(2)
+
+	node := BuildNode("node-1", "8", "16G", "2", FairScheduler)
+	if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		pod := newFakePod(fmt.Sprintf("pod-%d", i))
+		if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bound := func() []string {
+		names := make([]string, 0)
+		for _, item := range sim.Pods.List() {
+			if pod := item.(*Pod); pod.Spec.NodeName != "" {
+				names = append(names, pod.Name)
+			}
+		}
+		return names
+	}
+	if attempts := sim.cycle.schedule(0); attempts != 2 {
+		t.Errorf("should attempt 2 pods, not %d", attempts)
+	}
+	if names := bound(); len(names) != 2 {
+		t.Fatalf("should bind 2 pods, not %v", names)
+	}
+	// 节点最多运行两个Pod，第三个Pod调度失败后仍然在待调度队列中
+	if attempts := sim.cycle.schedule(1); attempts != 1 {
+		t.Errorf("should attempt 1 pod, not %d", attempts)
+	}
+	if names := bound(); len(names) != 2 {
+		t.Errorf("pod-2 should not be bound: %v", names)
+	}
+	if pending := sim.cycle.pendingPods(); len(pending) != 1 || pending[0].Name != "pod-2" {
+		t.Errorf("wrong pending pods %v", pending)
+	}
+}
+
+func TestSchedulingLatency(t *testing.T) {
+	sim := NewSchedulerSimulator(1000).(*schedSim)
+	defer sim.cancelFunc()
+	sim.SetSchedulingLatency(NewConstantLatency(0.4))
+
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		pod := newFakePod(fmt.Sprintf("pod-%d", i))
+		if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 每个Tick最多开始3次调度，第3次调度超出的0.2个Tick推迟下一个Tick的调度
+	for tick, expected := range []int{3, 2, 0} {
+		if attempts := sim.cycle.schedule(tick); attempts != expected {
+			t.Errorf("tick %d: should attempt %d pods, not %d", tick, expected, attempts)
+		}
+	}
+
+	sim.SetSchedulingLatency(NewConstantLatency(2.5))
+	pod := newFakePod("slow")
+	if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod = newFakePod("slower")
+	if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 第3个Tick开始的调度一直持续到5.5，因此第4个Tick不能调度
+	for i, expected := range []int{1, 0, 1} {
+		tick := 3 + i
+		if attempts := sim.cycle.schedule(tick); attempts != expected {
+			t.Errorf("tick %d: should attempt %d pods, not %d", tick, expected, attempts)
+		}
+	}
+}
+
+func TestEventDriven(t *testing.T) {
+	sim := NewSchedulerSimulator(1000).(*schedSim)
+	sim.SetEventDriven(true)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	wakeUps := []int{100, 500}
+	sim.RegisterBeforeUpdateController(&ControllerFunc{
+		TickFunc: func() {},
+		NextWakeUpFunc: func(tick int) int {
+			for _, wakeUp := range wakeUps {
+				if wakeUp > tick {
+					return wakeUp
+				}
+			}
+			return -1
+		},
+	})
+	ticks := make([]int, 0)
+	sim.RegisterAfterUpdateController(&ControllerFunc{
+		TickFunc: func() {
+			ticks = append(ticks, sim.GetClock().CurrentTick())
+		},
+		NextWakeUpFunc: func(int) int {
+			return -1
+		},
+	})
+	sim.Run()
+
+	// 第1个Tick由节点的加入唤醒
+	expected := []int{0, 1, 100, 500}
+	if fmt.Sprint(ticks) != fmt.Sprint(expected) {
+		t.Errorf("should run ticks %v, not %v", expected, ticks)
+	}
+	// 跳过的Tick也纳入统计，各个时间窗口按照模拟的Tick计算
+	if count := sim.nodeAggregators["node-1"].SaveState().Windows[0].Count; count != 1000 {
+		t.Errorf("node aggregator should count 1000 ticks, not %d", count)
+	}
+	if count := sim.clusterAggregator.SaveState().Windows[0].Count; count != 1000 {
+		t.Errorf("cluster aggregator should count 1000 ticks, not %d", count)
+	}
+}
+
 func TestNodeClient(t *testing.T) {
 	sim := NewSchedulerSimulator(1000)
 	defer sim.(*schedSim).cancelFunc()
@@ -545,3 +679,200 @@ func (d *deletePodAlgorithm) ResourceRequest() (cpu float64, mem int64) {
 func (d *deletePodAlgorithm) Terminate() {
 	d.terminate = true
 }
+
+func TestIndependentSimulators(t *testing.T) {
+	sims := []SchedulerSimulator{NewSchedulerSimulator(20), NewSchedulerSimulator(20)}
+	added := make([]int, len(sims))
+	for i, sim := range sims {
+		i := i
+		sim.SetMetricsSinks()
+		sim.SetClusterMetricsSinks()
+		sim.GetInformerFactory().Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				added[i]++
+			},
+		})
+		// 两个模拟器中有同名的节点与Pod
+		node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+		if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sims[0].GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(), newFakePod("pod-1"),
+		metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if added[0] != 1 || added[1] != 0 {
+		t.Fatalf("pod should only be added to the first simulator, but added %v", added)
+	}
+
+	done := make(chan bool, len(sims))
+	for _, sim := range sims {
+		go func(sim SchedulerSimulator) {
+			sim.Run()
+			done <- true
+		}(sim)
+	}
+	for range sims {
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("simulators should finish")
+		}
+	}
+	if _, err := sims[1].GetPod("pod-1"); err == nil {
+		t.Error("pod of the first simulator should not be in the second one")
+	}
+	if records := sims[1].GetPodRecords(); len(records) != 0 {
+		t.Errorf("second simulator should have no pod record, not %d", len(records))
+	}
+}
+
+func TestDiagnoseUnschedulable(t *testing.T) {
+	sim := NewSchedulerSimulator(10)
+	defer sim.Stop()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pod := newFakePod("selective")
+	pod.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+	if _, err := sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod,
+		metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for tick := 0; tick < 2; tick++ {
+		sim.(*schedSim).cycle.schedule(tick)
+	}
+
+	report := sim.DiagnoseUnschedulable()
+	if report.Pods != 1 || report.Pending != 1 || len(report.Groups) != 1 {
+		t.Fatalf("wrong report %+v", report)
+	}
+	group := report.Groups[0]
+	if len(group.Reasons) != 1 || group.Reasons[0] != metrics.ReasonAffinity {
+		t.Errorf("pod should fail because of node selector, not %v: %s", group.Reasons, group.Message)
+	}
+	if group.Retries != 2 || group.AdditionalPods != 1 || group.AdditionalCpu != 1 {
+		t.Errorf("wrong group %+v", group)
+	}
+}
+
+func TestFragmentationWithBoundPods(t *testing.T) {
+	sim := NewSchedulerSimulator(10).(*schedSim)
+	defer sim.Stop()
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	node := BuildNode("node-1", "2", "16G", "100", FairScheduler)
+	if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	empty := sim.runTick(0).FragmentationMetrics
+	if empty.StrandedMem != 0 {
+		t.Fatalf("empty node should have no stranded memory, not %d", empty.StrandedMem)
+	}
+
+	// 两个Pod分配完节点的CPU，节点剩余的内存无法再使用
+	for _, name := range []string{"pod-1", "pod-2"} {
+		if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(), newFakePod(name),
+			metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := sim.runTick(1).FragmentationMetrics
+	memCapacity := resource.MustParse("16G")
+	if expected := memCapacity.Value() - 2; full.StrandedMem != expected {
+		t.Errorf("stranded memory should be %d, not %d", expected, full.StrandedMem)
+	}
+	if full.StrandedCpu != 0 {
+		t.Errorf("stranded cpu should be 0, not %f", full.StrandedCpu)
+	}
+}
+
+func TestDiagnoseUnschedulableFullCluster(t *testing.T) {
+	sim := NewSchedulerSimulator(10)
+	defer sim.Stop()
+	node := BuildNode("node-1", "2", "16G", "100", FairScheduler)
+	if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 前两个Pod请求了节点所有的CPU，第三个Pod因CPU不足无法调度
+	for _, name := range []string{"pod-1", "pod-2", "pod-3"} {
+		pod := newFakePod(name)
+		pod.Spec.Containers = []v1.Container{{
+			Name: name,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			},
+		}}
+		if _, err := sim.GetKubernetesClient().CoreV1().Pods(DefaultNamespace).Create(context.TODO(), pod,
+			metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sim.(*schedSim).cycle.schedule(0)
+
+	report := sim.DiagnoseUnschedulable()
+	if report.Pending != 1 || len(report.Groups) != 1 {
+		t.Fatalf("wrong report %+v", report)
+	}
+	group := report.Groups[0]
+	if len(group.Reasons) != 1 || group.Reasons[0] != metrics.ReasonInsufficientCpu {
+		t.Errorf("pod should fail because of insufficient cpu, not %v: %s", group.Reasons, group.Message)
+	}
+	// 节点的CPU已经全部分配，虽然实际使用量为0，也需要增加容量
+	if group.AdditionalCpu != 1 || group.AdditionalPods != 1 {
+		t.Errorf("wrong group %+v", group)
+	}
+}
+
+func TestDeterministicScheduling(t *testing.T) {
+	// 节点完全相同，调度器需要在得分相同的节点中随机选择
+	run := func(seed int64) map[string]string {
+		sim := NewSchedulerSimulator(10).(*schedSim)
+		defer sim.Stop()
+		sim.SetDeterministic(seed)
+		for i := 0; i < 8; i++ {
+			node := BuildNode(fmt.Sprintf("node-%d", i), "8", "16G", "100", FairScheduler)
+			if _, err := sim.Client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+				t.Error(err)
+				return nil
+			}
+		}
+		for i := 0; i < 20; i++ {
+			if _, err := sim.Client.CoreV1().Pods(DefaultNamespace).Create(context.TODO(),
+				newFakePod(fmt.Sprintf("pod-%d", i)), metav1.CreateOptions{}); err != nil {
+				t.Error(err)
+				return nil
+			}
+		}
+		sim.cycle.schedule(0)
+		placement := make(map[string]string)
+		for _, record := range sim.GetPodRecords() {
+			placement[record.Name] = record.Node
+		}
+		return placement
+	}
+
+	// 同时运行的模拟器之间互不影响
+	const runs = 4
+	placements := make([]map[string]string, runs)
+	done := make(chan bool, runs)
+	for i := 0; i < runs; i++ {
+		go func(i int) {
+			placements[i] = run(42)
+			done <- true
+		}(i)
+	}
+	for i := 0; i < runs; i++ {
+		<-done
+	}
+	for i := 1; i < runs; i++ {
+		if fmt.Sprint(placements[i]) != fmt.Sprint(placements[0]) {
+			t.Fatalf("runs with the same seed should place pods the same way:\n%v\n%v", placements[0], placements[i])
+		}
+	}
+	if len(placements[0]) != 20 {
+		t.Errorf("all pods should be recorded, not %d", len(placements[0]))
+	}
+}
@@ -0,0 +1,398 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion 快照格式的版本，格式不兼容时增加
+const SnapshotVersion = 1
+
+// Snapshot 模拟器在两个Tick之间的完整状态，可以保存到文件，并恢复到新的模拟器中继续运行
+type Snapshot struct {
+	Version int `json:"version"`
+	// Tick 恢复之后执行的第一个Tick
+	Tick         int           `json:"tick"`
+	TickDuration time.Duration `json:"tickDuration"`
+
+	PriorityClasses []*schedulingv1.PriorityClass `json:"priorityClasses,omitempty"`
+	Nodes           []*NodeSnapshot               `json:"nodes"`
+	Pods            []*PodSnapshot                `json:"pods"`
+	// Controllers 各个StatefulController的状态，键为控制器的名称
+	Controllers map[string]json.RawMessage `json:"controllers,omitempty"`
+
+	// NodeAggregators 各个节点统计器的状态，键为节点名称
+	NodeAggregators   map[string]*metrics.AggregatorState `json:"nodeAggregators"`
+	ClusterAggregator *metrics.ClusterAggregatorState     `json:"clusterAggregator"`
+	PodRecords        []*metrics.PodRecord                `json:"podRecords"`
+
+	// SchedulingQueue 待调度队列中的Pod，按照进入队列的顺序排列
+	SchedulingQueue []string `json:"schedulingQueue"`
+	// SchedulerClock 调度器空闲的模拟时间，单位为Tick
+	SchedulerClock float64 `json:"schedulerClock"`
+}
+
+// NodeSnapshot 节点的状态
+type NodeSnapshot struct {
+	Node           v1.Node                   `json:"node"`
+	LastCpuUsage   float64                   `json:"lastCpuUsage"`
+	LastPodMetrics []*metrics.PodTickMetrics `json:"lastPodMetrics,omitempty"`
+	// Pods 节点上的Pod，包括已经结束但节点尚未处理的Pod
+	Pods []string `json:"pods"`
+	// CpuState 上一个Tick各个CPU上运行的Pod
+	CpuState [][]*RunEntitySnapshot `json:"cpuState"`
+	// DeletingPods 正在删除的Pod，值为删除前剩余的Tick数
+	DeletingPods map[string]int `json:"deletingPods,omitempty"`
+}
+
+// RunEntitySnapshot RunEntity的状态，使用Pod名称代替Pod
+type RunEntitySnapshot struct {
+	Pod  string  `json:"pod"`
+	Slot float64 `json:"slot"`
+}
+
+// PodSnapshot Pod的状态
+type PodSnapshot struct {
+	Pod      v1.Pod  `json:"pod"`
+	CpuLimit float64 `json:"cpuLimit"`
+	MemLimit int64   `json:"memLimit"`
+	// State PodAlgorithm的状态，恢复时传入PodAlgorithmFactory
+	State string `json:"state"`
+}
+
+// SaveSnapshot 将快照以JSON格式保存到文件
+func SaveSnapshot(snapshot *Snapshot, path string) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "error encoding snapshot")
+	}
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error writing snapshot %s", path))
+	}
+	return nil
+}
+
+// LoadSnapshot 读取SaveSnapshot保存的快照
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error reading snapshot %s", path))
+	}
+	snapshot := &Snapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error parsing snapshot %s", path))
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+func (sim *schedSim) Snapshot() (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Version:         SnapshotVersion,
+		Tick:            sim.resumeTick,
+		TickDuration:    sim.clock.TickDuration(),
+		PriorityClasses: make([]*schedulingv1.PriorityClass, 0),
+		Nodes:           make([]*NodeSnapshot, 0),
+		Pods:            make([]*PodSnapshot, 0),
+		Controllers:     make(map[string]json.RawMessage),
+		NodeAggregators: make(map[string]*metrics.AggregatorState),
+		PodRecords:      sim.podRecorder.getRecords(),
+		SchedulerClock:  sim.cycle.clock,
+	}
+
+	for _, item := range sim.PriorityClasses.List() {
+		snapshot.PriorityClasses = append(snapshot.PriorityClasses, item.(*schedulingv1.PriorityClass).DeepCopy())
+	}
+	sort.Slice(snapshot.PriorityClasses, func(i, j int) bool {
+		return snapshot.PriorityClasses[i].Name < snapshot.PriorityClasses[j].Name
+	})
+
+	for _, node := range sim.sortedNodes() {
+		snapshot.Nodes = append(snapshot.Nodes, node.snapshot())
+	}
+
+	pods := make([]*Pod, 0)
+	for _, item := range sim.Pods.List() {
+		pods = append(pods, item.(*Pod))
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	for _, pod := range pods {
+		state := pod.Annotations[PodAnnotationInitialState]
+		if stateful, ok := pod.Algorithm.(StatefulPodAlgorithm); ok {
+			var err error
+			if state, err = stateful.SaveState(); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("error saving state of pod %s", pod.Name))
+			}
+		}
+		snapshot.Pods = append(snapshot.Pods, &PodSnapshot{
+			Pod:      *pod.Pod.DeepCopy(),
+			CpuLimit: pod.CpuLimit,
+			MemLimit: pod.MemLimit,
+			State:    state,
+		})
+	}
+
+	for _, controller := range append(append([]Controller{}, sim.beforeUpdate...), sim.afterUpdate...) {
+		stateful, ok := controller.(StatefulController)
+		if !ok {
+			continue
+		}
+		state, err := stateful.SaveState()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error saving state of controller %s", controller.Name()))
+		}
+		if state == nil {
+			continue
+		}
+		if _, ok := snapshot.Controllers[controller.Name()]; ok {
+			return nil, fmt.Errorf("duplicate stateful controller %s", controller.Name())
+		}
+		snapshot.Controllers[controller.Name()] = state
+	}
+
+	for name, aggregator := range sim.nodeAggregators {
+		snapshot.NodeAggregators[name] = aggregator.SaveState()
+	}
+	snapshot.ClusterAggregator = sim.clusterAggregator.SaveState()
+
+	sim.cycle.lock.Lock()
+	queue := make([]string, 0, len(sim.cycle.seq))
+	for name := range sim.cycle.seq {
+		queue = append(queue, name)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return sim.cycle.seq[queue[i]] < sim.cycle.seq[queue[j]]
+	})
+	sim.cycle.lock.Unlock()
+	snapshot.SchedulingQueue = queue
+
+	return snapshot, nil
+}
+
+func (sim *schedSim) Restore(snapshot *Snapshot) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	if len(sim.Pods.List()) > 0 {
+		return fmt.Errorf("can not restore snapshot into a simulator with pods")
+	}
+
+	// 恢复过程中产生的事件属于快照的Tick之前
+	sim.clock.setTickDuration(snapshot.TickDuration)
+	if snapshot.Tick > 0 {
+		sim.tick = snapshot.Tick - 1
+		sim.clock.setTick(sim.tick)
+		sim.podRecorder.setTick(sim.tick)
+	}
+	sim.resumeTick = snapshot.Tick
+
+	for _, cls := range snapshot.PriorityClasses {
+		if _, exist, _ := sim.PriorityClasses.GetByKey(cls.Name); exist {
+			continue
+		}
+		if _, err := sim.Client.SchedulingV1().PriorityClasses().Create(context.TODO(), cls.DeepCopy(), metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring PriorityClass %s", cls.Name))
+		}
+	}
+
+	if err := sim.restoreNodes(snapshot.Nodes); err != nil {
+		return err
+	}
+	if err := sim.restorePods(snapshot); err != nil {
+		return err
+	}
+	if err := sim.restoreControllers(snapshot.Controllers); err != nil {
+		return err
+	}
+
+	sim.nodeAggregators = make(map[string]metrics.Aggregator)
+	for name, state := range snapshot.NodeAggregators {
+		aggregator := metrics.NewAggregator()
+		if err := aggregator.RestoreState(state); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring metrics of node %s", name))
+		}
+		sim.nodeAggregators[name] = aggregator
+	}
+	if snapshot.ClusterAggregator != nil {
+		if err := sim.clusterAggregator.RestoreState(snapshot.ClusterAggregator); err != nil {
+			return errors.Wrap(err, "error restoring cluster metrics")
+		}
+	}
+	sim.podRecorder.restore(snapshot.PodRecords)
+
+	sim.cycle.lock.Lock()
+	sim.cycle.seq = make(map[string]int64)
+	sim.cycle.nextSeq = 0
+	for _, name := range snapshot.SchedulingQueue {
+		sim.cycle.enqueue(name)
+	}
+	sim.cycle.clock = snapshot.SchedulerClock
+	sim.cycle.lock.Unlock()
+	return nil
+}
+
+// restoreNodes 创建快照中的节点，已经存在的同名节点直接恢复其状态，删除快照中没有的节点
+func (sim *schedSim) restoreNodes(nodes []*NodeSnapshot) error {
+	client := sim.Client.CoreV1().Nodes()
+	names := make(map[string]bool)
+	for _, saved := range nodes {
+		names[saved.Node.Name] = true
+		if _, exist, _ := sim.Nodes.GetByKey(saved.Node.Name); !exist {
+			if _, err := client.Create(context.TODO(), saved.Node.DeepCopy(), metav1.CreateOptions{}); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("error restoring node %s", saved.Node.Name))
+			}
+		}
+	}
+	for _, node := range sim.sortedNodes() {
+		if !names[node.Name] {
+			if err := client.Delete(context.TODO(), node.Name, metav1.DeleteOptions{}); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("error deleting node %s", node.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// restorePods 创建快照中的Pod，并恢复节点的状态。已经绑定的Pod直接加入到节点中，不再经过调度器
+func (sim *schedSim) restorePods(snapshot *Snapshot) error {
+	client := &coreV1PodClient{sim: sim}
+	pods := make(map[string]*Pod)
+	for _, saved := range snapshot.Pods {
+		if _, err := client.create(saved.Pod.DeepCopy(), saved.State); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring pod %s", saved.Pod.Name))
+		}
+		item, _, _ := sim.Pods.GetByKey(saved.Pod.Name)
+		pod := item.(*Pod)
+		pod.CpuLimit = saved.CpuLimit
+		pod.MemLimit = saved.MemLimit
+		pods[pod.Name] = pod
+	}
+
+	for _, saved := range snapshot.Nodes {
+		item, _, _ := sim.Nodes.GetByKey(saved.Node.Name)
+		node := item.(*Node)
+		if err := node.restore(saved, pods); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring node %s", node.Name))
+		}
+		if _, err := sim.Client.CoreV1().Nodes().UpdateStatus(context.TODO(), &node.Node, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring status of node %s", node.Name))
+		}
+	}
+	return nil
+}
+
+// restoreControllers 按照名称恢复各个StatefulController的状态。控制器在恢复时可能注册新的控制器，如已经部署的控制器，
+// 因此依次遍历直到没有新的控制器
+func (sim *schedSim) restoreControllers(states map[string]json.RawMessage) error {
+	restored := make(map[string]bool)
+	for i, j := 0, 0; i < len(sim.beforeUpdate) || j < len(sim.afterUpdate); {
+		var controller Controller
+		if i < len(sim.beforeUpdate) {
+			controller = sim.beforeUpdate[i]
+			i++
+		} else {
+			controller = sim.afterUpdate[j]
+			j++
+		}
+		stateful, ok := controller.(StatefulController)
+		if !ok {
+			continue
+		}
+		state, ok := states[controller.Name()]
+		if !ok {
+			continue
+		}
+		if err := stateful.RestoreState(state); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error restoring controller %s", controller.Name()))
+		}
+		restored[controller.Name()] = true
+	}
+	for name := range states {
+		if !restored[name] {
+			logrus.Warnf("No controller %s to restore its state", name)
+		}
+	}
+	return nil
+}
+
+func (n *Node) snapshot() *NodeSnapshot {
+	saved := &NodeSnapshot{
+		Node:           *n.Node.DeepCopy(),
+		LastCpuUsage:   n.LastCpuUsage,
+		LastPodMetrics: n.LastPodMetrics,
+		CpuState:       make([][]*RunEntitySnapshot, len(n.CpuState)),
+		DeletingPods:   make(map[string]int),
+	}
+	for _, pod := range n.sortedPods() {
+		saved.Pods = append(saved.Pods, pod.Name)
+	}
+	for i, queue := range n.CpuState {
+		saved.CpuState[i] = make([]*RunEntitySnapshot, 0, len(queue))
+		for _, entity := range queue {
+			saved.CpuState[i] = append(saved.CpuState[i], &RunEntitySnapshot{Pod: entity.Pod.Name, Slot: entity.Slot})
+		}
+	}
+	for name, deletion := range n.deletingPods {
+		saved.DeletingPods[name] = deletion.tickLeft
+	}
+	return saved
+}
+
+// restore 恢复节点的状态，pods为所有恢复的Pod
+func (n *Node) restore(saved *NodeSnapshot, pods map[string]*Pod) error {
+	if len(saved.CpuState) != len(n.CpuState) {
+		return fmt.Errorf("node should have %d cpus, not %d", len(n.CpuState), len(saved.CpuState))
+	}
+	n.Status = *saved.Node.Status.DeepCopy()
+	n.LastCpuUsage = saved.LastCpuUsage
+	n.LastPodMetrics = saved.LastPodMetrics
+
+	n.podLock.Lock()
+	n.Pods = make(map[string]*Pod)
+	for _, name := range saved.Pods {
+		pod, ok := pods[name]
+		if !ok {
+			n.podLock.Unlock()
+			return fmt.Errorf("no pod %s", name)
+		}
+		n.Pods[name] = pod
+	}
+	n.podLock.Unlock()
+
+	for i, queue := range saved.CpuState {
+		n.CpuState[i] = make([]*RunEntity, 0, len(queue))
+		for _, entity := range queue {
+			pod, ok := pods[entity.Pod]
+			if !ok {
+				return fmt.Errorf("no pod %s in cpu state", entity.Pod)
+			}
+			n.CpuState[i] = append(n.CpuState[i], &RunEntity{Pod: pod, Slot: entity.Slot})
+		}
+	}
+
+	n.deletingPods = make(map[string]*podDeletion)
+	for name, tickLeft := range saved.DeletingPods {
+		pod, ok := n.Pods[name]
+		if !ok {
+			return fmt.Errorf("no deleting pod %s", name)
+		}
+		n.deletingPods[name] = &podDeletion{tickLeft: tickLeft}
+		// 没有保存状态的算法从头开始运行，需要重新通知停止
+		pod.Algorithm.Terminate()
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newCountingController 记录被调用次数的控制器，调用次数保存在快照中
+func newCountingController(count *int, ticks *[]int, sim SchedulerSimulator) *ControllerFunc {
+	return &ControllerFunc{
+		NameString: "counter",
+		TickFunc: func() {
+			*count++
+			*ticks = append(*ticks, sim.GetClock().CurrentTick())
+		},
+		SaveStateFunc: func() ([]byte, error) {
+			return json.Marshal(*count)
+		},
+		RestoreStateFunc: func(state []byte) error {
+			return json.Unmarshal(state, count)
+		},
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	sim := NewSchedulerSimulator(10)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	node := BuildNode("node-1", "8", "16G", "100", FairScheduler)
+	if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	count, ticks := 0, make([]int, 0)
+	sim.RegisterBeforeUpdateController(newCountingController(&count, &ticks, sim))
+	sim.Run()
+
+	snapshot, err := sim.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Tick != 10 {
+		t.Errorf("snapshot should be taken at tick 10, not %d", snapshot.Tick)
+	}
+	if err = SaveSnapshot(snapshot, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewSchedulerSimulator(15)
+	restored.SetMetricsSinks()
+	restored.SetClusterMetricsSinks()
+	restoredCount, restoredTicks := 0, make([]int, 0)
+	restored.RegisterBeforeUpdateController(newCountingController(&restoredCount, &restoredTicks, restored))
+	if err = restored.Restore(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = restored.GetKubernetesClient().CoreV1().Nodes().Get(context.TODO(), "node-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("node should be restored: %v", err)
+	}
+	restored.Run()
+
+	if restoredCount != 15 {
+		t.Errorf("controller should be called 15 times in total, not %d", restoredCount)
+	}
+	if len(restoredTicks) != 5 || restoredTicks[0] != 10 {
+		t.Errorf("restored simulator should run from tick 10 to 14, not %v", restoredTicks)
+	}
+}
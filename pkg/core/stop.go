@@ -0,0 +1,44 @@
+package core
+
+import "github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+
+// StopCondition 模拟的停止条件，在每个Tick结束之后调用，m为该Tick的集群统计数据。返回true时模拟提前结束
+type StopCondition func(sim SchedulerSimulator, m *metrics.ClusterMetrics) bool
+
+// StopWhenAllPodsFinished 集群中有Pod且所有Pod都已经结束时停止。之后才会提交Pod的控制器（如延迟部署的工作负载）不能
+// 使用本条件
+func StopWhenAllPodsFinished() StopCondition {
+	return func(_ SchedulerSimulator, m *metrics.ClusterMetrics) bool {
+		return m.PendingPods == 0 && m.RunningPods == 0 && m.SucceededPods+m.FailedPods > 0
+	}
+}
+
+// StopWhenIdleFor 集群连续ticks个Tick没有等待调度与运行中的Pod时停止。事件驱动模式下跳过的Tick也计算在内
+func StopWhenIdleFor(ticks int) StopCondition {
+	idleSince := -1
+	return func(_ SchedulerSimulator, m *metrics.ClusterMetrics) bool {
+		if m.PendingPods > 0 || m.RunningPods > 0 {
+			idleSince = -1
+			return false
+		}
+		if idleSince < 0 {
+			idleSince = m.Tick
+		}
+		return m.Tick-idleSince+1 >= ticks
+	}
+}
+
+// StopWhenWaitExceeds 有Pod等待调度超过ticks个Tick时停止，用于在调度延迟的SLO被打破时提前结束
+func StopWhenWaitExceeds(ticks int) StopCondition {
+	return func(sim SchedulerSimulator, m *metrics.ClusterMetrics) bool {
+		if m.PendingPods == 0 {
+			return false
+		}
+		for _, record := range sim.GetPodRecords() {
+			if record.BindTick < 0 && record.CompletionTick < 0 && m.Tick-record.CreationTick > ticks {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"testing"
+)
+
+func TestStopWhenIdleFor(t *testing.T) {
+	condition := StopWhenIdleFor(3)
+	pending := []int{1, 0, 0, 1, 0, 0, 0}
+	expected := []bool{false, false, false, false, false, false, true}
+	for tick, p := range pending {
+		m := &metrics.ClusterMetrics{Tick: tick, ClusterTickMetrics: &metrics.ClusterTickMetrics{PendingPods: p}}
+		if stop := condition(nil, m); stop != expected[tick] {
+			t.Errorf("tick %d: should return %v", tick, expected[tick])
+		}
+	}
+
+	finished := StopWhenAllPodsFinished()
+	if finished(nil, &metrics.ClusterMetrics{ClusterTickMetrics: &metrics.ClusterTickMetrics{}}) {
+		t.Error("should not stop when there is no pod")
+	}
+	if !finished(nil, &metrics.ClusterMetrics{ClusterTickMetrics: &metrics.ClusterTickMetrics{SucceededPods: 1}}) {
+		t.Error("should stop when all pods finished")
+	}
+}
+
+func TestStep(t *testing.T) {
+	sim := NewSchedulerSimulator(10)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	count, ticks := 0, make([]int, 0)
+	sim.RegisterBeforeUpdateController(newCountingController(&count, &ticks, sim))
+	sim.RegisterAfterUpdateController(&ControllerFunc{
+		TickFunc: func() {
+			if sim.GetClock().CurrentTick() == 7 {
+				sim.Pause()
+			}
+		},
+	})
+
+	if n := sim.Step(3); n != 3 || count != 3 {
+		t.Fatalf("should run 3 ticks, not %d", n)
+	}
+	if !sim.RunUntil(func(sim SchedulerSimulator) bool { return sim.GetClock().CurrentTick() == 5 }) || count != 6 {
+		t.Fatalf("should run until tick 5, but ran %d ticks", count)
+	}
+	// 控制器在第7个Tick暂停
+	sim.Run()
+	if sim.Finished() || count != 8 {
+		t.Fatalf("should pause at tick 7, but ran %d ticks", count)
+	}
+	sim.Run()
+	if !sim.Finished() || count != 10 {
+		t.Fatalf("should finish after 10 ticks, but ran %d ticks", count)
+	}
+	if n := sim.Step(1); n != 0 {
+		t.Errorf("finished simulator should not run, but ran %d ticks", n)
+	}
+}
+
+func TestStopCondition(t *testing.T) {
+	sim := NewSchedulerSimulator(100)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	count, ticks := 0, make([]int, 0)
+	sim.RegisterBeforeUpdateController(newCountingController(&count, &ticks, sim))
+	sim.AddStopCondition(StopWhenIdleFor(5))
+	sim.Run()
+	if !sim.Finished() || count != 5 {
+		t.Errorf("empty cluster should stop after 5 ticks, not %d", count)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"math/rand"
 )
 
 type Pod struct {
@@ -72,6 +74,16 @@ func BuildV1Pod(name string, cpuLimit float64, memLimit int, algorithm string, d
 	}, nil
 }
 
+// NewUID 使用r生成随机的UUID。使用模拟器的GetRand作为r时，确定性模式下每次运行生成的UID相同
+func NewUID(r *rand.Rand) types.UID {
+	b := make([]byte, 16)
+	_, _ = r.Read(b)
+	// 版本4，RFC 4122变体
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return types.UID(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]))
+}
+
 func BuildPodUsingAlgorithm(name string, cpuLimit float64, memLimit int64, alg PodAlgorithm, schedulerName string) (*Pod, error) {
 	return &Pod{
 		Pod: v1.Pod{
@@ -109,6 +121,16 @@ type PodAlgorithm interface {
 	Terminate()
 }
 
+// StatefulPodAlgorithm 需要保存运行状态的PodAlgorithm实现本接口。保存快照时调用SaveState，恢复时将其返回值作为argJson
+// 传入PodAlgorithmFactory，因此返回值的格式应与初始化状态相同。没有实现本接口的算法恢复时使用PodAnnotationInitialState
+// 注解中的初始化状态，即从头开始运行。
+type StatefulPodAlgorithm interface {
+	PodAlgorithm
+
+	// SaveState 返回当前的运行状态
+	SaveState() (string, error)
+}
+
 type PodAlgorithmFactory func(argJson string, pod *Pod) (PodAlgorithm, error)
 
 var podAlgorithmMap = map[string]PodAlgorithmFactory{
@@ -146,12 +168,41 @@ type Controller interface {
 	Name() string
 }
 
+// WakeUpController 事件驱动模式下，控制器可以实现本接口，使模拟器跳过不需要执行的Tick。没有实现本接口的控制器需要每个
+// Tick都被调用，此时模拟器不会跳过任何Tick。跳过Tick时控制器不能通过调用次数计算当前的Tick，应当使用模拟时钟。
+type WakeUpController interface {
+	Controller
+
+	// NextWakeUp 在每次Tick之后调用，tick为当前的Tick序号，返回下一次需要调用Tick的Tick序号。返回值不大于tick时代表不再
+	// 需要主动唤醒。控制器仍然会在其他事件（Pod创建与结束、节点变化等）唤醒模拟器的Tick中被调用。
+	NextWakeUp(tick int) int
+}
+
+// StatefulController 需要保存到快照中的控制器实现本接口。恢复快照时，模拟器按照名称将状态交给新模拟器中同名的控制器，
+// 因此需要保存状态的控制器名称不能重复。没有实现本接口的控制器恢复之后从构造时的状态开始运行。
+type StatefulController interface {
+	Controller
+
+	// SaveState 返回控制器的状态，没有需要保存的状态时返回nil
+	SaveState() ([]byte, error)
+
+	// RestoreState 恢复SaveState返回的状态，在节点与Pod恢复之后调用
+	RestoreState(state []byte) error
+}
+
 type ControllerFunc struct {
 	NameString string
 	TickFunc   func()
+	// NextWakeUpFunc 事件驱动模式下返回下一次需要调用的Tick序号，为nil时每个Tick都需要调用
+	NextWakeUpFunc func(tick int) int
+	// SaveStateFunc 保存快照时返回控制器的状态，为nil时没有状态
+	SaveStateFunc func() ([]byte, error)
+	// RestoreStateFunc 恢复快照时恢复SaveStateFunc返回的状态
+	RestoreStateFunc func(state []byte) error
 }
 
-var _ Controller = &ControllerFunc{}
+var _ WakeUpController = &ControllerFunc{}
+var _ StatefulController = &ControllerFunc{}
 
 func (c *ControllerFunc) Tick() {
 	c.TickFunc()
@@ -161,6 +212,27 @@ func (c *ControllerFunc) Name() string {
 	return c.NameString
 }
 
+func (c *ControllerFunc) NextWakeUp(tick int) int {
+	if c.NextWakeUpFunc == nil {
+		return tick + 1
+	}
+	return c.NextWakeUpFunc(tick)
+}
+
+func (c *ControllerFunc) SaveState() ([]byte, error) {
+	if c.SaveStateFunc == nil {
+		return nil, nil
+	}
+	return c.SaveStateFunc()
+}
+
+func (c *ControllerFunc) RestoreState(state []byte) error {
+	if c.RestoreStateFunc == nil {
+		return fmt.Errorf("controller %s can not restore state", c.NameString)
+	}
+	return c.RestoreStateFunc(state)
+}
+
 // DeploymentController 模拟Kubernetes的控制器，根据其配置的模板构建Pod，然后通过Tick方法提交到本集群
 // 用户可以实现本接口，以定制Pod的提交。如批处理任务中某些Pod先于另一些Pod提交，或者在线业务中，压力增大时提交更多的Pod的
 // 逻辑。
@@ -3,6 +3,7 @@ package informers
 import (
 	"context"
 	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util"
 	"github.com/packagewjx/k8s-scheduler-sim/pkg/util/fake"
 	"github.com/sirupsen/logrus"
 	apicorev1 "k8s.io/api/core/v1"
@@ -15,8 +16,9 @@ import (
 func TestNodeInformer(t *testing.T) {
 	logrus.SetLevel(logrus.TraceLevel)
 
-	fakeClient := fake.NewFakeKubernetesInterface()
-	factory := NewSharedInformerFactory(fakeClient)
+	queue := util.NewSynchronizedMessageQueue()
+	fakeClient := fake.NewFakeKubernetesInterface(queue)
+	factory := NewSharedInformerFactory(fakeClient, queue)
 
 	// 测试通知是否正常
 
@@ -9,9 +9,8 @@ import (
 	"time"
 )
 
-// Please Note there is a race condition where the creation of resultChan will happen after the first event publishing,
-// causing lost of event. To prevent this, wait for some times after starting the factory or Run before using any
-//kubernetes.Interface function in order to let the informer subscribe topic first.
+// Run subscribes the topic synchronously and returns immediately, so events published after Run returns will not be
+// lost. The SharedInformerFactory calls Run synchronously for this reason.
 func NewSharedIndexInformer(topic string, keyFunc cache.KeyFunc) (cache.SharedIndexInformer, error) {
 	return &sharedIndexInformer{
 		keyFunc: keyFunc,
@@ -22,8 +21,10 @@ func NewSharedIndexInformer(topic string, keyFunc cache.KeyFunc) (cache.SharedIn
 }
 
 type sharedIndexInformer struct {
-	keyFunc   cache.KeyFunc
-	topic     string
+	keyFunc cache.KeyFunc
+	topic   string
+	// queue 订阅的消息队列，由SharedInformerFactory设置
+	queue     util.MessageQueue
 	store     cache.Store
 	listeners []cache.ResourceEventHandler
 	isStop    bool
@@ -64,7 +65,7 @@ func (s *sharedIndexInformer) GetController() cache.Controller {
 }
 
 func (s *sharedIndexInformer) Run(stopCh <-chan struct{}) {
-	err := util.GetMessageQueue().SubscribeSynchronously(s.topic, func(ev *watch.Event) {
+	err := s.queue.SubscribeSynchronously(s.topic, func(ev *watch.Event) {
 		if s.isStop {
 			return
 		}
@@ -1,6 +1,7 @@
 package informers
 
 import (
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/util"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/informers"
@@ -30,9 +31,12 @@ import (
 	"sync"
 )
 
-func NewSharedInformerFactory(client kubernetes.Interface) informers.SharedInformerFactory {
+// NewSharedInformerFactory 构造从queue接收事件的SharedInformerFactory。client需要将资源的变化发布到同一个消息队列中，
+// 以便同一个进程中的多个模拟器互不影响。
+func NewSharedInformerFactory(client kubernetes.Interface, queue util.MessageQueue) informers.SharedInformerFactory {
 	return &sharedInformerFactory{
 		client:           client,
+		queue:            queue,
 		informers:        make(map[reflect.Type]cache.SharedIndexInformer),
 		startedInformers: make(map[reflect.Type]bool),
 	}
@@ -43,6 +47,7 @@ type sharedInformerFactory struct {
 	informers        map[reflect.Type]cache.SharedIndexInformer
 	startedInformers map[reflect.Type]bool
 	client           kubernetes.Interface
+	queue            util.MessageQueue
 	lock             sync.Mutex
 }
 
@@ -52,7 +57,12 @@ func (f *sharedInformerFactory) Start(stopCh <-chan struct{}) {
 
 	for resourceType, informer := range f.informers {
 		if !f.startedInformers[resourceType] {
-			go informer.Run(stopCh)
+			if informer, ok := informer.(*sharedIndexInformer); ok {
+				// 订阅话题之后立即返回，同步调用以保证Start返回之后创建的资源不会丢失事件
+				informer.Run(stopCh)
+			} else {
+				go informer.Run(stopCh)
+			}
 			f.startedInformers[resourceType] = true
 		}
 	}
@@ -69,6 +79,9 @@ func (f *sharedInformerFactory) InformerFor(obj runtime.Object, newFunc internal
 	}
 
 	informer = newFunc(f.client, 0)
+	if informer, ok := informer.(*sharedIndexInformer); ok {
+		informer.queue = f.queue
+	}
 	f.informers[typ] = informer
 	return informer
 }
@@ -1,45 +1,37 @@
 package metrics
 
+import "fmt"
+
 type Aggregator interface {
 	// Aggregate 将新的统计数据纳入到总统计
 	Aggregate(tickMetrics *TickMetrics) *PeriodMetrics
 
+	// AggregateTicks 将连续ticks个Tick的相同统计数据纳入到总统计，用于事件驱动模式下跳过的Tick，使各个时间窗口按照
+	// 模拟的Tick而不是执行的Tick计算
+	AggregateTicks(tickMetrics *TickMetrics, ticks int) *PeriodMetrics
+
 	// Get 获取最新的统计数据
 	Get() *PeriodMetrics
+
+	// SaveState 返回当前的统计状态，用于保存快照
+	SaveState() *AggregatorState
+
+	// RestoreState 恢复SaveState返回的统计状态
+	RestoreState(state *AggregatorState) error
 }
 
 func NewAggregator() Aggregator {
 	return &aggregator{
-		count:    0,
-		cpuSum:   0,
-		cpu60:    newRingQueue(60),
-		cpu300:   newRingQueue(300),
-		cpu1500:  newRingQueue(1500),
-		memSum:   0,
-		mem60:    newRingQueue(60),
-		mem300:   newRingQueue(300),
-		mem1500:  newRingQueue(1500),
-		loadSum:  0,
-		load60:   newRingQueue(60),
-		load300:  newRingQueue(300),
-		load1500: newRingQueue(1500),
+		cpu:  newWindow(),
+		mem:  newWindow(),
+		load: newWindow(),
 	}
 }
 
 type aggregator struct {
-	count        int
-	cpuSum       float64
-	cpu60        *ringQueue
-	cpu300       *ringQueue
-	cpu1500      *ringQueue
-	memSum       float64
-	mem60        *ringQueue
-	mem300       *ringQueue
-	mem1500      *ringQueue
-	loadSum      float64
-	load60       *ringQueue
-	load300      *ringQueue
-	load1500     *ringQueue
+	cpu          *window
+	mem          *window
+	load         *window
 	latestMetric *PeriodMetrics
 }
 
@@ -48,29 +40,43 @@ func (a *aggregator) Get() *PeriodMetrics {
 }
 
 func (a *aggregator) Aggregate(tickMetrics *TickMetrics) *PeriodMetrics {
-	a.count++
-	a.cpuSum += tickMetrics.CpuUsage
-	a.memSum += tickMetrics.MemUsage
-	a.loadSum += tickMetrics.Load
-
-	a.latestMetric = &PeriodMetrics{
-		CpuUsageLastTick:           tickMetrics.CpuUsage,
-		CpuUsageAverage:            a.cpuSum / float64(a.count),
-		CpuUsageAverageIn60Ticks:   a.cpu60.add(tickMetrics.CpuUsage),
-		CpuUsageAverageIn300Ticks:  a.cpu300.add(tickMetrics.CpuUsage),
-		CpuUsageAverageIn1500Ticks: a.cpu1500.add(tickMetrics.CpuUsage),
-		MemUsageLastTick:           tickMetrics.MemUsage,
-		MemUsageAverage:            a.memSum / float64(a.count),
-		MemUsageAverageIn60Ticks:   a.mem60.add(tickMetrics.MemUsage),
-		MemUsageAverageIn300Ticks:  a.mem300.add(tickMetrics.MemUsage),
-		MemUsageAverageIn1500Ticks: a.mem1500.add(tickMetrics.MemUsage),
-		LoadLastTick:               tickMetrics.Load,
-		LoadAverage:                a.loadSum / float64(a.count),
-		LoadAverageIn60Ticks:       a.load60.add(tickMetrics.Load),
-		LoadAverageIn300Ticks:      a.load300.add(tickMetrics.Load),
-		LoadAverageIn1500Ticks:     a.load1500.add(tickMetrics.Load),
+	return a.AggregateTicks(tickMetrics, 1)
+}
+
+func (a *aggregator) AggregateTicks(tickMetrics *TickMetrics, ticks int) *PeriodMetrics {
+	m := &PeriodMetrics{
+		CpuUsageLastTick: tickMetrics.CpuUsage,
+		MemUsageLastTick: tickMetrics.MemUsage,
+		LoadLastTick:     tickMetrics.Load,
 	}
-	return a.latestMetric
+	m.CpuUsageAverage, m.CpuUsageAverageIn60Ticks, m.CpuUsageAverageIn300Ticks, m.CpuUsageAverageIn1500Ticks =
+		a.cpu.add(tickMetrics.CpuUsage, ticks)
+	m.MemUsageAverage, m.MemUsageAverageIn60Ticks, m.MemUsageAverageIn300Ticks, m.MemUsageAverageIn1500Ticks =
+		a.mem.add(tickMetrics.MemUsage, ticks)
+	m.LoadAverage, m.LoadAverageIn60Ticks, m.LoadAverageIn300Ticks, m.LoadAverageIn1500Ticks =
+		a.load.add(tickMetrics.Load, ticks)
+	a.latestMetric = m
+	return m
+}
+
+func (a *aggregator) SaveState() *AggregatorState {
+	return &AggregatorState{
+		Windows: []*WindowState{a.cpu.saveState(), a.mem.saveState(), a.load.saveState()},
+		Latest:  a.latestMetric,
+	}
+}
+
+func (a *aggregator) RestoreState(state *AggregatorState) error {
+	if len(state.Windows) != 3 {
+		return fmt.Errorf("aggregator state should have 3 windows, not %d", len(state.Windows))
+	}
+	for i, w := range []*window{a.cpu, a.mem, a.load} {
+		if err := w.restoreState(state.Windows[i]); err != nil {
+			return err
+		}
+	}
+	a.latestMetric = state.Latest
+	return nil
 }
 
 func newRingQueue(capacity int) *ringQueue {
@@ -101,3 +107,14 @@ func (queue *ringQueue) add(num float64) (average float64) {
 	}
 	return queue.sum / float64(queue.size)
 }
+
+// addRepeated 加入ticks个相同的数值。超过容量的部分会被移出队列，因此最多加入容量个
+func (queue *ringQueue) addRepeated(num float64, ticks int) (average float64) {
+	if ticks > len(queue.arr) {
+		ticks = len(queue.arr)
+	}
+	for i := 0; i < ticks; i++ {
+		average = queue.add(num)
+	}
+	return average
+}
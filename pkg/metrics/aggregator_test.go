@@ -95,3 +95,40 @@ func TestRingQueue(t *testing.T) {
 func floatEquals(a, b float64) bool {
 	return math.Abs(a-b) < 0.0001
 }
+
+func TestAggregateTicks(t *testing.T) {
+	agg := NewAggregator()
+	repeated := NewAggregator()
+	cluster := NewClusterAggregator()
+	clusterRepeated := NewClusterAggregator()
+	for i, ticks := range []int{1, 30, 100, 2000, 1} {
+		met := &TickMetrics{CpuUsage: float64(i) * 0.1, MemUsage: float64(i) * 0.2, Load: float64(i) * 0.15}
+		clusterMet := &ClusterTickMetrics{CpuCapacity: 10, CpuUsed: float64(i), MemCapacity: 10, MemUsed: int64(i)}
+		var expected *PeriodMetrics
+		var expectedCluster *ClusterPeriodMetrics
+		for j := 0; j < ticks; j++ {
+			expected = agg.Aggregate(met)
+			expectedCluster = cluster.Aggregate(clusterMet)
+		}
+		if actual := repeated.AggregateTicks(met, ticks); !periodEquals(expected, actual) {
+			t.Errorf("aggregating %d ticks should be %v, not %v", ticks, expected, actual)
+		}
+		actual := clusterRepeated.AggregateTicks(clusterMet, ticks)
+		for k, v := range actual.values() {
+			if !floatEquals(v, expectedCluster.values()[k]) {
+				t.Errorf("aggregating %d ticks should be %v, not %v", ticks, expectedCluster, actual)
+				break
+			}
+		}
+	}
+}
+
+func periodEquals(a, b *PeriodMetrics) bool {
+	return floatEquals(a.CpuUsageAverage, b.CpuUsageAverage) &&
+		floatEquals(a.CpuUsageAverageIn60Ticks, b.CpuUsageAverageIn60Ticks) &&
+		floatEquals(a.CpuUsageAverageIn300Ticks, b.CpuUsageAverageIn300Ticks) &&
+		floatEquals(a.CpuUsageAverageIn1500Ticks, b.CpuUsageAverageIn1500Ticks) &&
+		floatEquals(a.MemUsageAverageIn60Ticks, b.MemUsageAverageIn60Ticks) &&
+		floatEquals(a.LoadAverageIn300Ticks, b.LoadAverageIn300Ticks) &&
+		floatEquals(a.LoadAverage, b.LoadAverage)
+}
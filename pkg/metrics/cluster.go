@@ -0,0 +1,203 @@
+package metrics
+
+import "fmt"
+
+// ClusterTickMetrics 整个集群在一个Tick中的资源与Pod统计
+type ClusterTickMetrics struct {
+	Nodes int `json:"nodes"`
+	// CpuCapacity 所有节点的CPU核数之和
+	CpuCapacity float64 `json:"cpuCapacity"`
+	// CpuUsed 所有节点实际使用的CPU核数之和
+	CpuUsed float64 `json:"cpuUsed"`
+	// CpuAllocated 所有运行中的Pod的CpuLimit之和，即调度器分配出去的CPU
+	CpuAllocated float64 `json:"cpuAllocated"`
+	MemCapacity  int64   `json:"memCapacity"`
+	MemUsed      int64   `json:"memUsed"`
+	MemAllocated int64   `json:"memAllocated"`
+
+	PendingPods   int `json:"pendingPods"`
+	RunningPods   int `json:"runningPods"`
+	SucceededPods int `json:"succeededPods"`
+	FailedPods    int `json:"failedPods"`
+}
+
+// CpuUsage 集群CPU使用率
+func (m *ClusterTickMetrics) CpuUsage() float64 {
+	return ratio(m.CpuUsed, m.CpuCapacity)
+}
+
+// MemUsage 集群内存使用率
+func (m *ClusterTickMetrics) MemUsage() float64 {
+	return ratio(float64(m.MemUsed), float64(m.MemCapacity))
+}
+
+// CpuAllocationGap 已分配但没有实际使用的CPU占总CPU的比例。超分配时为负数
+func (m *ClusterTickMetrics) CpuAllocationGap() float64 {
+	return ratio(m.CpuAllocated-m.CpuUsed, m.CpuCapacity)
+}
+
+// MemAllocationGap 已分配但没有实际使用的内存占总内存的比例。超分配时为负数
+func (m *ClusterTickMetrics) MemAllocationGap() float64 {
+	return ratio(float64(m.MemAllocated-m.MemUsed), float64(m.MemCapacity))
+}
+
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// ClusterPeriodMetrics 集群使用率与分配差距在不同时间窗口内的平均值
+type ClusterPeriodMetrics struct {
+	CpuUsageLastTick                   float64 `json:"cpuUsageLastTick"`
+	CpuUsageAverage                    float64 `json:"cpuUsageAverage"`
+	CpuUsageAverageIn60Ticks           float64 `json:"cpuUsageAverageIn60Ticks"`
+	CpuUsageAverageIn300Ticks          float64 `json:"cpuUsageAverageIn300Ticks"`
+	CpuUsageAverageIn1500Ticks         float64 `json:"cpuUsageAverageIn1500Ticks"`
+	MemUsageLastTick                   float64 `json:"memUsageLastTick"`
+	MemUsageAverage                    float64 `json:"memUsageAverage"`
+	MemUsageAverageIn60Ticks           float64 `json:"memUsageAverageIn60Ticks"`
+	MemUsageAverageIn300Ticks          float64 `json:"memUsageAverageIn300Ticks"`
+	MemUsageAverageIn1500Ticks         float64 `json:"memUsageAverageIn1500Ticks"`
+	CpuAllocationGapLastTick           float64 `json:"cpuAllocationGapLastTick"`
+	CpuAllocationGapAverage            float64 `json:"cpuAllocationGapAverage"`
+	CpuAllocationGapAverageIn60Ticks   float64 `json:"cpuAllocationGapAverageIn60Ticks"`
+	CpuAllocationGapAverageIn300Ticks  float64 `json:"cpuAllocationGapAverageIn300Ticks"`
+	CpuAllocationGapAverageIn1500Ticks float64 `json:"cpuAllocationGapAverageIn1500Ticks"`
+	MemAllocationGapLastTick           float64 `json:"memAllocationGapLastTick"`
+	MemAllocationGapAverage            float64 `json:"memAllocationGapAverage"`
+	MemAllocationGapAverageIn60Ticks   float64 `json:"memAllocationGapAverageIn60Ticks"`
+	MemAllocationGapAverageIn300Ticks  float64 `json:"memAllocationGapAverageIn300Ticks"`
+	MemAllocationGapAverageIn1500Ticks float64 `json:"memAllocationGapAverageIn1500Ticks"`
+}
+
+// ClusterMetrics 集群在一个Tick的统计数据
+type ClusterMetrics struct {
+	Tick int `json:"tick"`
+	*ClusterTickMetrics
+	*ClusterPeriodMetrics
+	*FragmentationMetrics
+}
+
+// ClusterMetricsColumns CSV格式的各列名称，与ClusterMetrics的JSON字段名一致
+var ClusterMetricsColumns = []string{"tick", "nodes",
+	"cpuCapacity", "cpuUsed", "cpuAllocated", "memCapacity", "memUsed", "memAllocated",
+	"pendingPods", "runningPods", "succeededPods", "failedPods",
+	"cpuUsageLastTick", "cpuUsageAverage", "cpuUsageAverageIn60Ticks", "cpuUsageAverageIn300Ticks", "cpuUsageAverageIn1500Ticks",
+	"memUsageLastTick", "memUsageAverage", "memUsageAverageIn60Ticks", "memUsageAverageIn300Ticks", "memUsageAverageIn1500Ticks",
+	"cpuAllocationGapLastTick", "cpuAllocationGapAverage", "cpuAllocationGapAverageIn60Ticks", "cpuAllocationGapAverageIn300Ticks", "cpuAllocationGapAverageIn1500Ticks",
+	"memAllocationGapLastTick", "memAllocationGapAverage", "memAllocationGapAverageIn60Ticks", "memAllocationGapAverageIn300Ticks", "memAllocationGapAverageIn1500Ticks",
+	"strandedCpu", "strandedMem", "emptyNodes", "fragmentationIndex",
+}
+
+func (m *ClusterPeriodMetrics) values() []float64 {
+	return []float64{
+		m.CpuUsageLastTick, m.CpuUsageAverage, m.CpuUsageAverageIn60Ticks, m.CpuUsageAverageIn300Ticks, m.CpuUsageAverageIn1500Ticks,
+		m.MemUsageLastTick, m.MemUsageAverage, m.MemUsageAverageIn60Ticks, m.MemUsageAverageIn300Ticks, m.MemUsageAverageIn1500Ticks,
+		m.CpuAllocationGapLastTick, m.CpuAllocationGapAverage, m.CpuAllocationGapAverageIn60Ticks, m.CpuAllocationGapAverageIn300Ticks, m.CpuAllocationGapAverageIn1500Ticks,
+		m.MemAllocationGapLastTick, m.MemAllocationGapAverage, m.MemAllocationGapAverageIn60Ticks, m.MemAllocationGapAverageIn300Ticks, m.MemAllocationGapAverageIn1500Ticks,
+	}
+}
+
+// ClusterAggregator 与Aggregator相同，统计集群的各个时间窗口内的平均值
+type ClusterAggregator interface {
+	// Aggregate 将新的统计数据纳入到总统计
+	Aggregate(tickMetrics *ClusterTickMetrics) *ClusterPeriodMetrics
+	// AggregateTicks 与Aggregator的AggregateTicks相同
+	AggregateTicks(tickMetrics *ClusterTickMetrics, ticks int) *ClusterPeriodMetrics
+	// Get 获取最新的统计数据
+	Get() *ClusterPeriodMetrics
+	// SaveState 返回当前的统计状态，用于保存快照
+	SaveState() *ClusterAggregatorState
+	// RestoreState 恢复SaveState返回的统计状态
+	RestoreState(state *ClusterAggregatorState) error
+}
+
+func NewClusterAggregator() ClusterAggregator {
+	return &clusterAggregator{
+		cpu:    newWindow(),
+		mem:    newWindow(),
+		cpuGap: newWindow(),
+		memGap: newWindow(),
+	}
+}
+
+type clusterAggregator struct {
+	cpu          *window
+	mem          *window
+	cpuGap       *window
+	memGap       *window
+	latestMetric *ClusterPeriodMetrics
+}
+
+func (a *clusterAggregator) Get() *ClusterPeriodMetrics {
+	return a.latestMetric
+}
+
+func (a *clusterAggregator) Aggregate(tickMetrics *ClusterTickMetrics) *ClusterPeriodMetrics {
+	return a.AggregateTicks(tickMetrics, 1)
+}
+
+func (a *clusterAggregator) AggregateTicks(tickMetrics *ClusterTickMetrics, ticks int) *ClusterPeriodMetrics {
+	m := &ClusterPeriodMetrics{}
+	m.CpuUsageLastTick = tickMetrics.CpuUsage()
+	m.CpuUsageAverage, m.CpuUsageAverageIn60Ticks, m.CpuUsageAverageIn300Ticks, m.CpuUsageAverageIn1500Ticks =
+		a.cpu.add(m.CpuUsageLastTick, ticks)
+	m.MemUsageLastTick = tickMetrics.MemUsage()
+	m.MemUsageAverage, m.MemUsageAverageIn60Ticks, m.MemUsageAverageIn300Ticks, m.MemUsageAverageIn1500Ticks =
+		a.mem.add(m.MemUsageLastTick, ticks)
+	m.CpuAllocationGapLastTick = tickMetrics.CpuAllocationGap()
+	m.CpuAllocationGapAverage, m.CpuAllocationGapAverageIn60Ticks, m.CpuAllocationGapAverageIn300Ticks,
+		m.CpuAllocationGapAverageIn1500Ticks = a.cpuGap.add(m.CpuAllocationGapLastTick, ticks)
+	m.MemAllocationGapLastTick = tickMetrics.MemAllocationGap()
+	m.MemAllocationGapAverage, m.MemAllocationGapAverageIn60Ticks, m.MemAllocationGapAverageIn300Ticks,
+		m.MemAllocationGapAverageIn1500Ticks = a.memGap.add(m.MemAllocationGapLastTick, ticks)
+	a.latestMetric = m
+	return m
+}
+
+func (a *clusterAggregator) SaveState() *ClusterAggregatorState {
+	return &ClusterAggregatorState{
+		Windows: []*WindowState{a.cpu.saveState(), a.mem.saveState(), a.cpuGap.saveState(), a.memGap.saveState()},
+		Latest:  a.latestMetric,
+	}
+}
+
+func (a *clusterAggregator) RestoreState(state *ClusterAggregatorState) error {
+	if len(state.Windows) != 4 {
+		return fmt.Errorf("cluster aggregator state should have 4 windows, not %d", len(state.Windows))
+	}
+	for i, w := range []*window{a.cpu, a.mem, a.cpuGap, a.memGap} {
+		if err := w.restoreState(state.Windows[i]); err != nil {
+			return err
+		}
+	}
+	a.latestMetric = state.Latest
+	return nil
+}
+
+// window 统计一项指标的总平均值，以及最近60、300、1500个Tick的平均值
+type window struct {
+	count int
+	sum   float64
+	q60   *ringQueue
+	q300  *ringQueue
+	q1500 *ringQueue
+}
+
+func newWindow() *window {
+	return &window{
+		q60:   newRingQueue(60),
+		q300:  newRingQueue(300),
+		q1500: newRingQueue(1500),
+	}
+}
+
+// add 将连续ticks个Tick的相同数值num纳入统计
+func (w *window) add(num float64, ticks int) (average, average60, average300, average1500 float64) {
+	w.count += ticks
+	w.sum += num * float64(ticks)
+	return w.sum / float64(w.count), w.q60.addRepeated(num, ticks), w.q300.addRepeated(num, ticks),
+		w.q1500.addRepeated(num, ticks)
+}
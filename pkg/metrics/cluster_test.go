@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestClusterAggregator(t *testing.T) {
+	agg := NewClusterAggregator()
+	met := &ClusterTickMetrics{
+		Nodes:        2,
+		CpuCapacity:  8,
+		MemCapacity:  100,
+		CpuAllocated: 6,
+		MemAllocated: 50,
+	}
+	for i := 0; i < 100; i++ {
+		met.CpuUsed = float64(i % 5)
+		met.MemUsed = 60
+		data := agg.Aggregate(met)
+		if !floatEquals(data.CpuUsageLastTick, float64(i%5)/8) {
+			t.Errorf("CPU使用率不对")
+		}
+		if !floatEquals(data.CpuAllocationGapLastTick, (6-float64(i%5))/8) {
+			t.Errorf("CPU分配差距不对")
+		}
+		if !floatEquals(data.MemAllocationGapLastTick, -0.1) || !floatEquals(data.MemUsageAverageIn60Ticks, 0.6) {
+			t.Errorf("内存统计不对")
+		}
+	}
+	// 最后60个Tick中，CPU使用核数0到4各出现12次
+	if !floatEquals(agg.Get().CpuUsageAverageIn60Ticks, 2.0/8) || !floatEquals(agg.Get().CpuUsageAverage, 2.0/8) {
+		t.Errorf("长期平均不对")
+	}
+
+	empty := NewClusterAggregator().Aggregate(&ClusterTickMetrics{})
+	if empty.CpuUsageLastTick != 0 || empty.MemAllocationGapLastTick != 0 {
+		t.Errorf("没有节点时应该为0")
+	}
+}
+
+func testClusterMetrics() *ClusterMetrics {
+	tickMetrics := &ClusterTickMetrics{Nodes: 2, CpuCapacity: 8, CpuUsed: 2, CpuAllocated: 4, MemCapacity: 100,
+		MemUsed: 10, MemAllocated: 20, PendingPods: 1, RunningPods: 3, SucceededPods: 5}
+	return &ClusterMetrics{
+		Tick:                 7,
+		ClusterTickMetrics:   tickMetrics,
+		ClusterPeriodMetrics: NewClusterAggregator().Aggregate(tickMetrics),
+	}
+}
+
+func TestClusterCSVSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewClusterCSVSink(buf)
+	if err := sink.WriteCluster(testClusterMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("should have 2 lines, not %d", len(lines))
+	}
+	if lines[0] != strings.Join(ClusterMetricsColumns, ",") {
+		t.Errorf("wrong header %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "7,2,8,2,4,100,10,20,1,3,5,0,0.25,0.25,") {
+		t.Errorf("wrong record %s", lines[1])
+	}
+	if len(strings.Split(lines[1], ",")) != len(ClusterMetricsColumns) {
+		t.Errorf("wrong number of columns %s", lines[1])
+	}
+}
+
+func TestClusterJSONLinesSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewMultiClusterSink(NewClusterJSONLinesSink(buf), NewClusterTableSink(&bytes.Buffer{}))
+	if err := sink.WriteCluster(testClusterMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &ClusterMetrics{}
+	if err := json.Unmarshal(buf.Bytes(), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Tick != 7 || m.SucceededPods != 5 || m.MemCapacity != 100 || !floatEquals(m.CpuAllocationGapLastTick, 0.25) {
+		t.Errorf("wrong metrics %s", buf.String())
+	}
+}
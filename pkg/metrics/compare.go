@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// RunSummary 一次运行的汇总统计，用于比较使用不同调度器配置运行同一场景的结果
+type RunSummary struct {
+	Name string `json:"name"`
+	// Cluster 整个运行期间集群使用率与分配差距的统计
+	Cluster *ClusterPeriodMetrics `json:"cluster"`
+	// Pods 所有Pod调度记录的汇总
+	Pods *PodSummary `json:"pods"`
+	// ServiceSlowdown 在线服务Pod每个Tick的减速比例的分布，反映同一节点上其他Pod对在线服务的干扰
+	ServiceSlowdown *Distribution `json:"serviceSlowdown"`
+}
+
+// ComparedMetric 参与比较的一项指标
+type ComparedMetric struct {
+	Name string
+	// LowerIsBetter 值越小越好，否则值越大越好
+	LowerIsBetter bool
+	Value         func(s *RunSummary) float64
+}
+
+// ComparedMetrics 默认参与比较的指标
+var ComparedMetrics = []*ComparedMetric{
+	{"CpuUsage", false, func(s *RunSummary) float64 { return s.Cluster.CpuUsageAverage }},
+	{"MemUsage", false, func(s *RunSummary) float64 { return s.Cluster.MemUsageAverage }},
+	{"CpuAllocationGap", true, func(s *RunSummary) float64 { return s.Cluster.CpuAllocationGapAverage }},
+	{"MemAllocationGap", true, func(s *RunSummary) float64 { return s.Cluster.MemAllocationGapAverage }},
+	{"BoundPods", false, func(s *RunSummary) float64 { return float64(s.Pods.Bound) }},
+	{"CompletedPods", false, func(s *RunSummary) float64 { return float64(s.Pods.Completed) }},
+	{"UnschedulablePods", true, func(s *RunSummary) float64 { return float64(s.Pods.Unschedulable) }},
+	{"WaitMean", true, func(s *RunSummary) float64 { return s.Pods.WaitTicks.Mean }},
+	{"WaitP90", true, func(s *RunSummary) float64 { return s.Pods.WaitTicks.P90 }},
+	{"WaitP99", true, func(s *RunSummary) float64 { return s.Pods.WaitTicks.P99 }},
+	{"MakespanMean", true, func(s *RunSummary) float64 { return s.Pods.Makespan.Mean }},
+	{"MakespanP99", true, func(s *RunSummary) float64 { return s.Pods.Makespan.P99 }},
+	{"ServiceSlowdownMean", true, func(s *RunSummary) float64 { return s.ServiceSlowdown.Mean }},
+	{"ServiceSlowdownP99", true, func(s *RunSummary) float64 { return s.ServiceSlowdown.P99 }},
+}
+
+// MetricComparison 一项指标在各次运行中的值
+type MetricComparison struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+	// Relative 各次运行相对第一次运行（基线）的变化比例。基线为0时，值也为0的运行为0，否则为正负无穷
+	Relative []float64 `json:"-"`
+	// Winner 最好的运行的下标，有多个运行并列最好时为-1
+	Winner int `json:"winner"`
+}
+
+// Comparison 多次运行的逐项比较，第一次运行为基线
+type Comparison struct {
+	Runs    []string            `json:"runs"`
+	Metrics []*MetricComparison `json:"metrics"`
+}
+
+// Compare 使用ComparedMetrics逐项比较各次运行的汇总统计
+func Compare(summaries []*RunSummary) *Comparison {
+	c := &Comparison{
+		Runs:    make([]string, len(summaries)),
+		Metrics: make([]*MetricComparison, 0, len(ComparedMetrics)),
+	}
+	for i, s := range summaries {
+		c.Runs[i] = s.Name
+	}
+	for _, metric := range ComparedMetrics {
+		m := &MetricComparison{
+			Name:     metric.Name,
+			Values:   make([]float64, len(summaries)),
+			Relative: make([]float64, len(summaries)),
+			Winner:   -1,
+		}
+		for i, s := range summaries {
+			m.Values[i] = metric.Value(s)
+			m.Relative[i] = relativeChange(m.Values[0], m.Values[i])
+		}
+		m.Winner = winner(m.Values, metric.LowerIsBetter)
+		c.Metrics = append(c.Metrics, m)
+	}
+	return c
+}
+
+func relativeChange(base, value float64) float64 {
+	if base == 0 {
+		if value == 0 {
+			return 0
+		}
+		return math.Inf(int(math.Copysign(1, value)))
+	}
+	return (value - base) / math.Abs(base)
+}
+
+// winner 返回唯一最好的值的下标，没有唯一最好的值时返回-1
+func winner(values []float64, lowerIsBetter bool) int {
+	best, count := -1, 0
+	for i, v := range values {
+		if best < 0 {
+			best, count = i, 1
+			continue
+		}
+		better := v > values[best]
+		if lowerIsBetter {
+			better = v < values[best]
+		}
+		if better {
+			best, count = i, 1
+		} else if v == values[best] {
+			count++
+		}
+	}
+	if count != 1 {
+		return -1
+	}
+	return best
+}
+
+// WriteComparison 以表格形式输出比较结果。每次运行一列，除基线之外的运行在值之后给出相对基线的变化
+func WriteComparison(w io.Writer, c *Comparison) error {
+	header := fmt.Sprintf("%-20s", "Metric")
+	for _, run := range c.Runs {
+		header += fmt.Sprintf("\t%-20s", run)
+	}
+	if _, err := fmt.Fprintln(w, header+"\tWinner"); err != nil {
+		return err
+	}
+	for _, m := range c.Metrics {
+		line := fmt.Sprintf("%-20s", m.Name)
+		for i, v := range m.Values {
+			cell := fmt.Sprintf("%.4f", v)
+			if i > 0 {
+				cell += " (" + formatRelative(m.Relative[i]) + ")"
+			}
+			line += fmt.Sprintf("\t%-20s", cell)
+		}
+		winnerName := "-"
+		if m.Winner >= 0 {
+			winnerName = c.Runs[m.Winner]
+		}
+		if _, err := fmt.Fprintln(w, line+"\t"+winnerName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatRelative(relative float64) string {
+	if math.IsInf(relative, 0) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", relative*100)
+}
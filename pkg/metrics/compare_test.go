@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testRunSummary(name string, cpuUsage, waitMean float64, bound int) *RunSummary {
+	return &RunSummary{
+		Name:            name,
+		Cluster:         &ClusterPeriodMetrics{CpuUsageAverage: cpuUsage},
+		Pods:            &PodSummary{Bound: bound, WaitTicks: &Distribution{Mean: waitMean}, Makespan: &Distribution{}},
+		ServiceSlowdown: &Distribution{},
+	}
+}
+
+func findMetric(c *Comparison, name string) *MetricComparison {
+	for _, m := range c.Metrics {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestCompare(t *testing.T) {
+	c := Compare([]*RunSummary{
+		testRunSummary("base", 0.5, 4, 10),
+		testRunSummary("packing", 0.6, 2, 10),
+		testRunSummary("spread", 0.4, 0, 9),
+	})
+	if len(c.Runs) != 3 || len(c.Metrics) != len(ComparedMetrics) {
+		t.Fatalf("wrong comparison %+v", c)
+	}
+
+	cpu := findMetric(c, "CpuUsage")
+	if cpu.Winner != 1 {
+		t.Errorf("packing should win CpuUsage, not %d", cpu.Winner)
+	}
+	if !floatEquals(cpu.Relative[0], 0) || !floatEquals(cpu.Relative[1], 0.2) || !floatEquals(cpu.Relative[2], -0.2) {
+		t.Errorf("wrong relative change %v", cpu.Relative)
+	}
+	if wait := findMetric(c, "WaitMean"); wait.Winner != 2 || !floatEquals(wait.Relative[2], -1) {
+		t.Errorf("spread should win WaitMean, got %+v", wait)
+	}
+	if bound := findMetric(c, "BoundPods"); bound.Winner != -1 {
+		t.Errorf("BoundPods should be a tie, not %d", bound.Winner)
+	}
+
+	c = Compare([]*RunSummary{testRunSummary("a", 0, 0, 0), testRunSummary("b", 0.1, 0, 0)})
+	if cpu = findMetric(c, "CpuUsage"); !math.IsInf(cpu.Relative[1], 1) {
+		t.Errorf("change from zero baseline should be +Inf, not %f", cpu.Relative[1])
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteComparison(buf, c); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(ComparedMetrics)+1 {
+		t.Fatalf("should have %d lines, not %d", len(ComparedMetrics)+1, len(lines))
+	}
+	if !strings.Contains(lines[1], "n/a") || !strings.HasSuffix(lines[1], "\tb") {
+		t.Errorf("wrong line %q", lines[1])
+	}
+}
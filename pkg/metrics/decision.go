@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"io"
+	"os"
+)
+
+// DecisionRecord 调度器一次调度尝试的决策过程
+type DecisionRecord struct {
+	Tick          int    `json:"tick"`
+	Pod           string `json:"pod"`
+	SchedulerName string `json:"schedulerName"`
+	// Node 选中的节点，调度失败时为空
+	Node string `json:"node"`
+	// Error 调度失败的原因
+	Error string `json:"error,omitempty"`
+	// PreFilter PreFilter插件失败时的状态信息，此时所有节点都没有经过过滤
+	PreFilter string `json:"preFilter,omitempty"`
+	// PreScore PreScore插件失败时的状态信息，此时所有节点都没有分数
+	PreScore string `json:"preScore,omitempty"`
+	// Nodes 各个候选节点的过滤与打分结果，按照节点名称排列
+	Nodes []*NodeDecision `json:"nodes"`
+}
+
+// NodeDecision 一个候选节点的过滤与打分结果
+type NodeDecision struct {
+	Node string `json:"node"`
+	// FilteredBy 拒绝该节点的Filter插件，通过过滤时为空
+	FilteredBy string `json:"filteredBy,omitempty"`
+	// Reason Filter插件拒绝该节点的状态信息
+	Reason string `json:"reason,omitempty"`
+	// Scores 各个Score插件的分数。只有一个节点通过过滤时调度器不打分，此时为空
+	Scores []*PluginScore `json:"scores,omitempty"`
+	// TotalScore 各个插件的分数乘以权重之和，即调度器选择节点时比较的分数
+	TotalScore int64 `json:"totalScore"`
+}
+
+// PluginScore 一个Score插件对一个节点的打分
+type PluginScore struct {
+	Plugin string `json:"plugin"`
+	// Raw Score返回的原始分数
+	Raw int64 `json:"raw"`
+	// Normalized NormalizeScore之后的分数，插件没有实现NormalizeScore时与Raw相同
+	Normalized int64 `json:"normalized"`
+	Weight     int64 `json:"weight"`
+}
+
+// DecisionSink 接收调度器每次调度尝试的决策过程
+type DecisionSink interface {
+	WriteDecision(record *DecisionRecord) error
+
+	// Close 与MetricsSink的Close相同
+	Close() error
+}
+
+// DecisionFileName 调度决策记录的默认文件名
+const DecisionFileName = "decisions.jsonl"
+
+// NewDecisionSink 构造输出JSON Lines的DecisionSink。path为空或者为“-”时输出到标准输出，否则创建文件。
+func NewDecisionSink(path string) (DecisionSink, error) {
+	if path == "" || path == "-" {
+		return NewDecisionJSONLinesSink(os.Stdout), nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &decisionFileSink{DecisionSink: NewDecisionJSONLinesSink(file), file: file}, nil
+}
+
+// NewDecisionJSONLinesSink 构造输出JSON Lines的DecisionSink，每行为一个DecisionRecord
+func NewDecisionJSONLinesSink(w io.Writer) DecisionSink {
+	return newJSONLinesSink(w).(*jsonLinesSink)
+}
+
+func (s *jsonLinesSink) WriteDecision(record *DecisionRecord) error {
+	return s.encoder.Encode(record)
+}
+
+// decisionFileSink 在关闭时同时关闭文件
+type decisionFileSink struct {
+	DecisionSink
+	file *os.File
+}
+
+func (s *decisionFileSink) Close() error {
+	err := s.DecisionSink.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// NewMultiDecisionSink 构造将决策过程同时写入多个DecisionSink的DecisionSink
+func NewMultiDecisionSink(sinks ...DecisionSink) DecisionSink {
+	return multiDecisionSink(sinks)
+}
+
+type multiDecisionSink []DecisionSink
+
+func (s multiDecisionSink) WriteDecision(record *DecisionRecord) error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.WriteDecision(record); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (s multiDecisionSink) Close() error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecisionJSONLinesSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewMultiDecisionSink(NewDecisionJSONLinesSink(buf))
+	records := []*DecisionRecord{
+		{Tick: 1, Pod: "a", SchedulerName: "default-scheduler", Node: "node-1", Nodes: []*NodeDecision{
+			{Node: "node-0", FilteredBy: "NodeResourcesFit", Reason: "Insufficient cpu"},
+			{Node: "node-1", Scores: []*PluginScore{{Plugin: "NodeResourcesLeastAllocated", Raw: 40, Normalized: 40, Weight: 1}},
+				TotalScore: 40},
+		}},
+		{Tick: 2, Pod: "b", SchedulerName: "default-scheduler", Error: "0/2 nodes are available", Nodes: []*NodeDecision{}},
+	}
+	for _, record := range records {
+		if err := sink.WriteDecision(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("should have 2 lines, not %d", len(lines))
+	}
+	decoded := &DecisionRecord{}
+	if err := json.Unmarshal([]byte(lines[0]), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Node != "node-1" || len(decoded.Nodes) != 2 || decoded.Nodes[0].FilteredBy != "NodeResourcesFit" ||
+		decoded.Nodes[1].Scores[0].Raw != 40 {
+		t.Errorf("wrong decoded record %+v", decoded)
+	}
+	if strings.Contains(lines[0], `"error"`) || !strings.Contains(lines[1], `"error":"0/2 nodes are available"`) {
+		t.Errorf("error should only be written for failed attempts")
+	}
+}
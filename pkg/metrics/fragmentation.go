@@ -0,0 +1,97 @@
+package metrics
+
+// ExhaustedThreshold 节点某项资源的空闲量低于容量的此比例时，视为该资源已经耗尽
+const ExhaustedThreshold = 0.05
+
+// NodeResource 计算碎片化指标所需的节点资源状态
+type NodeResource struct {
+	CpuCapacity float64
+	// CpuFree 节点尚未分配给Pod的CPU核数，即容量减去已绑定的Pod请求的CPU之和
+	CpuFree     float64
+	MemCapacity int64
+	MemFree     int64
+	PodCapacity int
+	// Pods 节点上已绑定的Pod数量
+	Pods int
+}
+
+// NodeFragmentation 一个节点的碎片化指标
+type NodeFragmentation struct {
+	// LargestPodCpu 与LargestPodMem 节点能够容纳的最大Pod，Pod数量已满时为0
+	LargestPodCpu float64 `json:"largestPodCpu"`
+	LargestPodMem int64   `json:"largestPodMem"`
+	// StrandedCpu 由于内存或Pod数量耗尽而无法再分配的空闲CPU核数
+	StrandedCpu float64 `json:"strandedCpu"`
+	// StrandedMem 由于CPU或Pod数量耗尽而无法再分配的空闲内存
+	StrandedMem int64 `json:"strandedMem"`
+}
+
+// FragmentationMetrics 整个集群的碎片化与装箱质量指标
+type FragmentationMetrics struct {
+	StrandedCpu float64 `json:"strandedCpu"`
+	StrandedMem int64   `json:"strandedMem"`
+	// EmptyNodes 没有绑定任何Pod的节点数
+	EmptyNodes int `json:"emptyNodes"`
+	// FragmentationIndex 碎片化指数，取值0～1。对CPU与内存分别计算 1 - 最大的单节点空闲量 / 总空闲量，并取平均值。
+	// 空闲资源集中在一个节点时为0，越分散越接近1
+	FragmentationIndex float64 `json:"fragmentationIndex"`
+}
+
+// Fragmentation 计算各个节点与整个集群的碎片化指标，返回的节点指标与nodes一一对应
+func Fragmentation(nodes []*NodeResource) (*FragmentationMetrics, []*NodeFragmentation) {
+	cluster := &FragmentationMetrics{}
+	perNode := make([]*NodeFragmentation, len(nodes))
+	cpuFreeSum, cpuFreeMax := float64(0), float64(0)
+	memFreeSum, memFreeMax := int64(0), int64(0)
+	for i, node := range nodes {
+		cpuFree, memFree := node.CpuFree, node.MemFree
+		if cpuFree < 0 {
+			cpuFree = 0
+		}
+		if memFree < 0 {
+			memFree = 0
+		}
+		cpuFreeSum += cpuFree
+		memFreeSum += memFree
+		if cpuFree > cpuFreeMax {
+			cpuFreeMax = cpuFree
+		}
+		if memFree > memFreeMax {
+			memFreeMax = memFree
+		}
+
+		podsExhausted := node.Pods >= node.PodCapacity
+		cpuExhausted := cpuFree < node.CpuCapacity*ExhaustedThreshold
+		memExhausted := float64(memFree) < float64(node.MemCapacity)*ExhaustedThreshold
+		m := &NodeFragmentation{}
+		if !podsExhausted {
+			m.LargestPodCpu = cpuFree
+			m.LargestPodMem = memFree
+		}
+		if podsExhausted || memExhausted {
+			m.StrandedCpu = cpuFree
+		}
+		if podsExhausted || cpuExhausted {
+			m.StrandedMem = memFree
+		}
+		perNode[i] = m
+
+		cluster.StrandedCpu += m.StrandedCpu
+		cluster.StrandedMem += m.StrandedMem
+		if node.Pods == 0 {
+			cluster.EmptyNodes++
+		}
+	}
+
+	cluster.FragmentationIndex = (fragmentationIndex(cpuFreeMax, cpuFreeSum) +
+		fragmentationIndex(float64(memFreeMax), float64(memFreeSum))) / 2
+	return cluster, perNode
+}
+
+// fragmentationIndex 单项资源的碎片化指数，没有空闲资源时为0
+func fragmentationIndex(freeMax, freeSum float64) float64 {
+	if freeSum == 0 {
+		return 0
+	}
+	return 1 - freeMax/freeSum
+}
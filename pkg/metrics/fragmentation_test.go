@@ -0,0 +1,43 @@
+package metrics
+
+import "testing"
+
+func TestFragmentation(t *testing.T) {
+	nodes := []*NodeResource{
+		// 内存耗尽，剩余的CPU无法使用
+		{CpuCapacity: 4, CpuFree: 3, MemCapacity: 100, MemFree: 2, PodCapacity: 10, Pods: 1},
+		// Pod数量已满
+		{CpuCapacity: 4, CpuFree: 1, MemCapacity: 100, MemFree: 40, PodCapacity: 2, Pods: 2},
+		// 空节点
+		{CpuCapacity: 4, CpuFree: 4, MemCapacity: 100, MemFree: 100, PodCapacity: 10, Pods: 0},
+		// 超分配
+		{CpuCapacity: 4, CpuFree: -1, MemCapacity: 100, MemFree: 58, PodCapacity: 10, Pods: 3},
+	}
+	cluster, perNode := Fragmentation(nodes)
+
+	if perNode[0].StrandedCpu != 3 || perNode[0].StrandedMem != 0 || perNode[0].LargestPodCpu != 3 {
+		t.Errorf("wrong fragmentation of node 0: %+v", perNode[0])
+	}
+	if perNode[1].StrandedCpu != 1 || perNode[1].StrandedMem != 40 || perNode[1].LargestPodMem != 0 {
+		t.Errorf("wrong fragmentation of node 1: %+v", perNode[1])
+	}
+	if perNode[2].StrandedCpu != 0 || perNode[2].LargestPodCpu != 4 || perNode[2].LargestPodMem != 100 {
+		t.Errorf("wrong fragmentation of node 2: %+v", perNode[2])
+	}
+	if perNode[3].StrandedMem != 58 || perNode[3].LargestPodCpu != 0 {
+		t.Errorf("wrong fragmentation of node 3: %+v", perNode[3])
+	}
+
+	if cluster.StrandedCpu != 4 || cluster.StrandedMem != 98 || cluster.EmptyNodes != 1 {
+		t.Errorf("wrong cluster fragmentation: %+v", cluster)
+	}
+	// CPU: 1 - 4/8，内存：1 - 100/200
+	if !floatEquals(cluster.FragmentationIndex, 0.5) {
+		t.Errorf("wrong fragmentation index %f", cluster.FragmentationIndex)
+	}
+
+	cluster, _ = Fragmentation([]*NodeResource{{CpuCapacity: 4, MemCapacity: 100, PodCapacity: 10, Pods: 1}})
+	if cluster.FragmentationIndex != 0 || cluster.EmptyNodes != 0 {
+		t.Errorf("full cluster should not be fragmented: %+v", cluster)
+	}
+}
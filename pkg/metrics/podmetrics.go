@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PodTickMetrics 一个运行中的Pod在一个Tick中的资源分配与使用情况
+type PodTickMetrics struct {
+	Tick int    `json:"tick"`
+	Pod  string `json:"pod"`
+	Node string `json:"node"`
+	// CpuRequested Pod通过ResourceRequest请求的CPU核数
+	CpuRequested float64 `json:"cpuRequested"`
+	// SlotsGranted 内核调度器分配的时间片之和
+	SlotsGranted float64 `json:"slotsGranted"`
+	// SlotsEffective 经过CPU压力缩减之后，Pod实际得到的时间片之和
+	SlotsEffective float64 `json:"slotsEffective"`
+	// PressureReduction 由于节点CPU压力过高，时间片缩减后的比例，没有缩减时为1
+	PressureReduction float64 `json:"pressureReduction"`
+	MemRequested      int64   `json:"memRequested"`
+	MemGranted        int64   `json:"memGranted"`
+	MemUsed           int64   `json:"memUsed"`
+	Load              float64 `json:"load"`
+	// Slowdown 由于资源不足而损失的执行进度比例，取值0～1。0代表得到了请求的全部资源，1代表完全没有得到执行
+	Slowdown float64 `json:"slowdown"`
+}
+
+// ComputeSlowdown 根据实际得到的时间片与内存计算Slowdown。CPU与内存中不足程度较大的一项决定执行进度
+func (m *PodTickMetrics) ComputeSlowdown() float64 {
+	progress := float64(1)
+	if m.CpuRequested > 0 && m.SlotsEffective < m.CpuRequested {
+		progress = m.SlotsEffective / m.CpuRequested
+	}
+	if m.MemRequested > 0 && m.MemGranted < m.MemRequested {
+		if memProgress := float64(m.MemGranted) / float64(m.MemRequested); memProgress < progress {
+			progress = memProgress
+		}
+	}
+	if progress < 0 {
+		progress = 0
+	}
+	return 1 - progress
+}
+
+// PodMetricsSink 接收模拟器每个Tick中各个Pod的资源分配与使用情况
+type PodMetricsSink interface {
+	// WritePods 写入一个Tick中所有运行中的Pod的统计数据
+	WritePods(tick int, metrics []*PodTickMetrics) error
+
+	// Close 与MetricsSink的Close相同
+	Close() error
+}
+
+// PodMetricsColumns CSV格式的各列名称，与PodTickMetrics的JSON字段名一致
+var PodMetricsColumns = []string{"tick", "pod", "node", "cpuRequested", "slotsGranted", "slotsEffective",
+	"pressureReduction", "memRequested", "memGranted", "memUsed", "load", "slowdown"}
+
+// NewPodSink 与NewSink相同，构造接收Pod统计数据的PodMetricsSink
+func NewPodSink(format, path string) (PodMetricsSink, error) {
+	s, err := openSink(format, path)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PodSinkFileName 返回格式对应的Pod统计数据的默认文件名
+func PodSinkFileName(format string) string {
+	if format == SinkTable {
+		return "podmetrics.txt"
+	}
+	return "podmetrics." + format
+}
+
+// NewPodTableSink 构造输出定宽表格的PodMetricsSink，每个Tick输出一次表头
+func NewPodTableSink(w io.Writer) PodMetricsSink {
+	return newTableSink(w)
+}
+
+// NewPodCSVSink 构造输出CSV的PodMetricsSink，第一行为表头PodMetricsColumns
+func NewPodCSVSink(w io.Writer) PodMetricsSink {
+	return newCSVSink(w)
+}
+
+// NewPodGzipCSVSink 构造输出gzip压缩CSV的PodMetricsSink
+func NewPodGzipCSVSink(w io.Writer) PodMetricsSink {
+	return newGzipCSVSink(w)
+}
+
+// NewPodJSONLinesSink 构造输出JSON Lines的PodMetricsSink，每行为一个PodTickMetrics
+func NewPodJSONLinesSink(w io.Writer) PodMetricsSink {
+	return newJSONLinesSink(w)
+}
+
+func (s *tableSink) WritePods(_ int, metrics []*PodTickMetrics) error {
+	_, err := fmt.Fprintln(s.w, "Pod                 \tNode                \tCPUReq\tSlots\tEffect\tReduce\tLoad \tSlowdown")
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		_, err = fmt.Fprintf(s.w, "%-20s\t%-20s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", m.Pod, m.Node, m.CpuRequested,
+			m.SlotsGranted, m.SlotsEffective, m.PressureReduction, m.Load, m.Slowdown)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *csvSink) WritePods(tick int, metrics []*PodTickMetrics) error {
+	if err := s.writeHeader(PodMetricsColumns); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		record := []string{strconv.Itoa(tick), m.Pod, m.Node}
+		for _, v := range []float64{m.CpuRequested, m.SlotsGranted, m.SlotsEffective, m.PressureReduction} {
+			record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		for _, v := range []int64{m.MemRequested, m.MemGranted, m.MemUsed} {
+			record = append(record, strconv.FormatInt(v, 10))
+		}
+		record = append(record, strconv.FormatFloat(m.Load, 'f', -1, 64), strconv.FormatFloat(m.Slowdown, 'f', -1, 64))
+		if err := s.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonLinesSink) WritePods(tick int, metrics []*PodTickMetrics) error {
+	for _, m := range metrics {
+		m.Tick = tick
+		if err := s.encoder.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewMultiPodSink 构造将Pod统计数据同时写入多个PodMetricsSink的PodMetricsSink
+func NewMultiPodSink(sinks ...PodMetricsSink) PodMetricsSink {
+	return multiPodSink(sinks)
+}
+
+type multiPodSink []PodMetricsSink
+
+func (s multiPodSink) WritePods(tick int, metrics []*PodTickMetrics) error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.WritePods(tick, metrics); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (s multiPodSink) Close() error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestComputeSlowdown(t *testing.T) {
+	m := &PodTickMetrics{CpuRequested: 2, SlotsGranted: 2, SlotsEffective: 2, MemRequested: 100, MemGranted: 100}
+	if m.ComputeSlowdown() != 0 {
+		t.Errorf("should not slow down")
+	}
+	m.SlotsEffective = 1.5
+	if !floatEquals(m.ComputeSlowdown(), 0.25) {
+		t.Errorf("wrong cpu slowdown %f", m.ComputeSlowdown())
+	}
+	m.MemGranted = 50
+	if !floatEquals(m.ComputeSlowdown(), 0.5) {
+		t.Errorf("wrong memory slowdown %f", m.ComputeSlowdown())
+	}
+	m = &PodTickMetrics{CpuRequested: 1}
+	if m.ComputeSlowdown() != 1 {
+		t.Errorf("pod without slots should be fully slowed down")
+	}
+}
+
+func testPodMetrics() []*PodTickMetrics {
+	return []*PodTickMetrics{
+		{Pod: "a", Node: "n", CpuRequested: 2, SlotsGranted: 2, SlotsEffective: 1.8, PressureReduction: 0.9,
+			MemRequested: 10, MemGranted: 10, MemUsed: 8, Load: 1, Slowdown: 0.1},
+		{Pod: "b", Node: "n", CpuRequested: 1, PressureReduction: 1, Slowdown: 1},
+	}
+}
+
+func TestPodSinks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewPodCSVSink(buf)
+	if err := sink.WritePods(5, testPodMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("should have 3 lines, not %d", len(lines))
+	}
+	if lines[0] != strings.Join(PodMetricsColumns, ",") {
+		t.Errorf("wrong header %s", lines[0])
+	}
+	if lines[1] != "5,a,n,2,2,1.8,0.9,10,10,8,1,0.1" {
+		t.Errorf("wrong record %s", lines[1])
+	}
+
+	buf.Reset()
+	sink = NewMultiPodSink(NewPodJSONLinesSink(buf), NewPodTableSink(&bytes.Buffer{}))
+	_ = sink.WritePods(5, testPodMetrics())
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m := &PodTickMetrics{}
+	if err := json.Unmarshal([]byte(strings.Split(buf.String(), "\n")[1]), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Tick != 5 || m.Pod != "b" || m.Slowdown != 1 {
+		t.Errorf("wrong metrics %s", buf.String())
+	}
+}
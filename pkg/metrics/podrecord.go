@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PodRecord 一个Pod从创建到结束的调度记录。尚未发生的事件的Tick为-1
+type PodRecord struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Node      string `json:"node"`
+	// CreationTick 调度器收到Pod的Tick
+	CreationTick int `json:"creationTick"`
+	// BindTick Pod绑定到节点的Tick
+	BindTick int `json:"bindTick"`
+	// Attempts 调度器尝试调度的次数，包括最后成功的一次
+	Attempts int `json:"attempts"`
+	// UnschedulableReasons 各次调度失败的原因，连续相同的原因只记录一次
+	UnschedulableReasons []string `json:"unschedulableReasons"`
+	// CompletionTick Pod进入Succeeded或Failed的Tick
+	CompletionTick int    `json:"completionTick"`
+	Phase          string `json:"phase"`
+}
+
+// NewPodRecord 构造在tick时创建的Pod的记录
+func NewPodRecord(name, algorithm string, tick int) *PodRecord {
+	return &PodRecord{
+		Name:                 name,
+		Algorithm:            algorithm,
+		CreationTick:         tick,
+		BindTick:             -1,
+		UnschedulableReasons: []string{},
+		CompletionTick:       -1,
+	}
+}
+
+// WaitTicks 从创建到绑定所等待的Tick数，尚未绑定时返回false
+func (r *PodRecord) WaitTicks() (int, bool) {
+	if r.BindTick < 0 {
+		return 0, false
+	}
+	return r.BindTick - r.CreationTick, true
+}
+
+// Makespan 从创建到结束所用的Tick数，尚未结束时返回false
+func (r *PodRecord) Makespan() (int, bool) {
+	if r.CompletionTick < 0 {
+		return 0, false
+	}
+	return r.CompletionTick - r.CreationTick, true
+}
+
+// Distribution 一组数值的分布概况
+type Distribution struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// NewDistribution 计算values的分布，百分位数使用最近秩方法。values为空时所有值为0
+func NewDistribution(values []float64) *Distribution {
+	d := &Distribution{Count: len(values)}
+	if len(values) == 0 {
+		return d
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	sum := float64(0)
+	for _, v := range sorted {
+		sum += v
+	}
+	d.Mean = sum / float64(len(sorted))
+	d.P50 = percentile(sorted, 0.5)
+	d.P90 = percentile(sorted, 0.9)
+	d.P99 = percentile(sorted, 0.99)
+	d.Max = sorted[len(sorted)-1]
+	return d
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// PodSummary 所有Pod调度记录的汇总
+type PodSummary struct {
+	Pods      int `json:"pods"`
+	Bound     int `json:"bound"`
+	Completed int `json:"completed"`
+	// Unschedulable 从未绑定且至少有一次调度失败的Pod数量
+	Unschedulable int `json:"unschedulable"`
+	// WaitTicks 已绑定的Pod的等待时间分布
+	WaitTicks *Distribution `json:"waitTicks"`
+	// Makespan 已结束的Pod，即批处理任务的完成时间分布
+	Makespan *Distribution `json:"makespan"`
+}
+
+// SummarizePods 汇总Pod调度记录
+func SummarizePods(records []*PodRecord) *PodSummary {
+	summary := &PodSummary{Pods: len(records)}
+	waits := make([]float64, 0, len(records))
+	makespans := make([]float64, 0, len(records))
+	for _, record := range records {
+		if wait, ok := record.WaitTicks(); ok {
+			summary.Bound++
+			waits = append(waits, float64(wait))
+		} else if len(record.UnschedulableReasons) > 0 {
+			summary.Unschedulable++
+		}
+		if makespan, ok := record.Makespan(); ok {
+			summary.Completed++
+			makespans = append(makespans, float64(makespan))
+		}
+	}
+	summary.WaitTicks = NewDistribution(waits)
+	summary.Makespan = NewDistribution(makespans)
+	return summary
+}
+
+// WritePodSummary 以表格形式输出汇总
+func WritePodSummary(w io.Writer, summary *PodSummary) error {
+	_, err := fmt.Fprintf(w, "Pods %d, Bound %d, Completed %d, Unschedulable %d\n", summary.Pods, summary.Bound,
+		summary.Completed, summary.Unschedulable)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintln(w, "Ticks   \tCount\tMean   \tP50  \tP90  \tP99  \tMax  "); err != nil {
+		return err
+	}
+	for _, item := range []struct {
+		name string
+		d    *Distribution
+	}{{"Wait", summary.WaitTicks}, {"Makespan", summary.Makespan}} {
+		_, err = fmt.Fprintf(w, "%-8s\t%-5d\t%-7.3f\t%-5.0f\t%-5.0f\t%-5.0f\t%.0f\n", item.name, item.d.Count, item.d.Mean,
+			item.d.P50, item.d.P90, item.d.P99, item.d.Max)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PodRecordColumns CSV格式的各列名称，与PodRecord的JSON字段名一致。多个调度失败原因使用“; ”分隔
+var PodRecordColumns = []string{"name", "algorithm", "node", "creationTick", "bindTick", "attempts",
+	"unschedulableReasons", "completionTick", "phase"}
+
+// PodRecordFileName 返回格式对应的Pod调度记录的默认文件名
+func PodRecordFileName(format string) string {
+	if format == SinkTable {
+		return "pods.txt"
+	}
+	return "pods." + format
+}
+
+// SavePodRecords 按照格式保存Pod调度记录。与NewSink相同，path为空或者为“-”时输出到标准输出，否则创建文件。
+func SavePodRecords(format, path string, records []*PodRecord) error {
+	if path == "" || path == "-" {
+		return WritePodRecords(os.Stdout, format, records)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WritePodRecords(file, format, records)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WritePodRecords 按照格式输出Pod调度记录，支持的格式与MetricsSink相同
+func WritePodRecords(w io.Writer, format string, records []*PodRecord) error {
+	switch format {
+	case SinkTable:
+		return writePodRecordsTable(w, records)
+	case SinkCSV:
+		return writePodRecordsCSV(w, records)
+	case SinkGzipCSV:
+		gz := gzip.NewWriter(w)
+		err := writePodRecordsCSV(gz, records)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	case SinkJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported metrics format %s", format)
+	}
+}
+
+func writePodRecordsTable(w io.Writer, records []*PodRecord) error {
+	_, err := fmt.Fprintln(w, "Pod                 \tNode                \tCreated\tBound\tAttempts\tCompleted\tPhase")
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		_, err = fmt.Fprintf(w, "%-20s\t%-20s\t%-7d\t%-5d\t%-8d\t%-9d\t%s\n", r.Name, r.Node, r.CreationTick, r.BindTick,
+			r.Attempts, r.CompletionTick, r.Phase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePodRecordsCSV(w io.Writer, records []*PodRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(PodRecordColumns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		err := writer.Write([]string{r.Name, r.Algorithm, r.Node, strconv.Itoa(r.CreationTick), strconv.Itoa(r.BindTick),
+			strconv.Itoa(r.Attempts), strings.Join(r.UnschedulableReasons, "; "), strconv.Itoa(r.CompletionTick), r.Phase})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewDistribution(t *testing.T) {
+	values := make([]float64, 0, 100)
+	for i := 100; i > 0; i-- {
+		values = append(values, float64(i))
+	}
+	d := NewDistribution(values)
+	if d.Count != 100 || !floatEquals(d.Mean, 50.5) || d.P50 != 50 || d.P90 != 90 || d.P99 != 99 || d.Max != 100 {
+		t.Errorf("wrong distribution %+v", d)
+	}
+	if values[0] != 100 {
+		t.Errorf("should not modify values")
+	}
+
+	d = NewDistribution([]float64{3})
+	if d.P50 != 3 || d.P99 != 3 {
+		t.Errorf("wrong distribution %+v", d)
+	}
+	if d = NewDistribution(nil); d.Count != 0 || d.Mean != 0 {
+		t.Errorf("wrong distribution %+v", d)
+	}
+}
+
+func testPodRecords() []*PodRecord {
+	bound := NewPodRecord("bound", "BatchPod", 1)
+	bound.Node = "node-0"
+	bound.BindTick = 3
+	bound.Attempts = 2
+	bound.UnschedulableReasons = []string{"0/1 nodes are available: 1 Insufficient cpu."}
+	bound.CompletionTick = 11
+	bound.Phase = "Succeeded"
+	running := NewPodRecord("running", "SimServicePod", 2)
+	running.BindTick = 2
+	running.Attempts = 1
+	running.Phase = "Running"
+	unschedulable := NewPodRecord("unschedulable", "BatchPod", 2)
+	unschedulable.Attempts = 1
+	unschedulable.UnschedulableReasons = []string{"a", "b"}
+	unschedulable.Phase = "Pending"
+	return []*PodRecord{bound, running, unschedulable}
+}
+
+func TestSummarizePods(t *testing.T) {
+	summary := SummarizePods(testPodRecords())
+	if summary.Pods != 3 || summary.Bound != 2 || summary.Completed != 1 || summary.Unschedulable != 1 {
+		t.Errorf("wrong summary %+v", summary)
+	}
+	if summary.WaitTicks.Count != 2 || summary.WaitTicks.Mean != 1 || summary.WaitTicks.Max != 2 {
+		t.Errorf("wrong wait distribution %+v", summary.WaitTicks)
+	}
+	if summary.Makespan.Count != 1 || summary.Makespan.P99 != 10 {
+		t.Errorf("wrong makespan distribution %+v", summary.Makespan)
+	}
+	if err := WritePodSummary(&bytes.Buffer{}, summary); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWritePodRecords(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WritePodRecords(buf, SinkCSV, testPodRecords()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("should have 4 lines, not %d", len(lines))
+	}
+	if lines[3] != "unschedulable,BatchPod,,2,-1,1,a; b,-1,Pending" {
+		t.Errorf("wrong record %s", lines[3])
+	}
+
+	for _, format := range []string{SinkTable, SinkJSONLines, SinkGzipCSV} {
+		buf.Reset()
+		if err := WritePodRecords(buf, format, testPodRecords()); err != nil || buf.Len() == 0 {
+			t.Errorf("error writing %s: %v", format, err)
+		}
+	}
+	if err := WritePodRecords(buf, "parquet", nil); err == nil {
+		t.Error("should not support parquet")
+	}
+}
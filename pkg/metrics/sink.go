@@ -0,0 +1,413 @@
+package metrics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NodeMetrics 一个节点在一个Tick的统计数据
+type NodeMetrics struct {
+	Tick int    `json:"tick"`
+	Node string `json:"node"`
+	*PeriodMetrics
+	*NodeFragmentation
+}
+
+// MetricsSink 接收模拟器每个Tick中各个节点的统计数据，并输出到文件等位置
+type MetricsSink interface {
+	// Write 写入一个Tick中所有节点的统计数据
+	Write(tick int, metrics []*NodeMetrics) error
+
+	// Close 刷新缓冲。由NewSink创建的MetricsSink同时关闭其文件，其余MetricsSink不关闭传入的io.Writer
+	Close() error
+}
+
+// ClusterMetricsSink 接收模拟器每个Tick的集群统计数据
+type ClusterMetricsSink interface {
+	// WriteCluster 写入一个Tick的集群统计数据
+	WriteCluster(metrics *ClusterMetrics) error
+
+	// Close 与MetricsSink的Close相同
+	Close() error
+}
+
+// sink 内置的各个格式同时实现了MetricsSink、ClusterMetricsSink与PodMetricsSink，但一个sink只应该接收其中一种统计数据
+type sink interface {
+	MetricsSink
+	WriteCluster(metrics *ClusterMetrics) error
+	WritePods(tick int, metrics []*PodTickMetrics) error
+}
+
+// 内置的MetricsSink格式
+const (
+	// SinkTable 每个Tick输出一张定宽表格，便于人阅读
+	SinkTable = "table"
+	SinkCSV   = "csv"
+	// SinkJSONLines 每行为一个NodeMetrics的JSON
+	SinkJSONLines = "jsonl"
+	// SinkGzipCSV 使用gzip压缩的CSV，适合大规模模拟
+	SinkGzipCSV = "csv.gz"
+)
+
+// MetricsColumns CSV格式的各列名称，与NodeMetrics的JSON字段名一致
+var MetricsColumns = []string{"tick", "node",
+	"cpuUsageLastTick", "cpuUsageAverage", "cpuUsageAverageIn60Ticks", "cpuUsageAverageIn300Ticks", "cpuUsageAverageIn1500Ticks",
+	"memUsageLastTick", "memUsageAverage", "memUsageAverageIn60Ticks", "memUsageAverageIn300Ticks", "memUsageAverageIn1500Ticks",
+	"loadLastTick", "loadAverage", "loadAverageIn60Ticks", "loadAverageIn300Ticks", "loadAverageIn1500Ticks",
+	"largestPodCpu", "largestPodMem", "strandedCpu", "strandedMem",
+}
+
+func (m *PeriodMetrics) values() []float64 {
+	return []float64{
+		m.CpuUsageLastTick, m.CpuUsageAverage, m.CpuUsageAverageIn60Ticks, m.CpuUsageAverageIn300Ticks, m.CpuUsageAverageIn1500Ticks,
+		m.MemUsageLastTick, m.MemUsageAverage, m.MemUsageAverageIn60Ticks, m.MemUsageAverageIn300Ticks, m.MemUsageAverageIn1500Ticks,
+		m.LoadLastTick, m.LoadAverage, m.LoadAverageIn60Ticks, m.LoadAverageIn300Ticks, m.LoadAverageIn1500Ticks,
+	}
+}
+
+// NewSink 根据格式构造MetricsSink。path为空或者为“-”时输出到标准输出，否则创建文件。
+func NewSink(format, path string) (MetricsSink, error) {
+	s, err := openSink(format, path)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewClusterSink 与NewSink相同，构造接收集群统计数据的ClusterMetricsSink
+func NewClusterSink(format, path string) (ClusterMetricsSink, error) {
+	s, err := openSink(format, path)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func openSink(format, path string) (sink, error) {
+	var newSink func(w io.Writer) sink
+	switch format {
+	case SinkTable:
+		newSink = newTableSink
+	case SinkCSV:
+		newSink = newCSVSink
+	case SinkJSONLines:
+		newSink = newJSONLinesSink
+	case SinkGzipCSV:
+		newSink = newGzipCSVSink
+	default:
+		return nil, fmt.Errorf("unsupported metrics format %s", format)
+	}
+
+	if path == "" || path == "-" {
+		return newSink(os.Stdout), nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{sink: newSink(file), file: file}, nil
+}
+
+// fileSink 在关闭时同时关闭文件
+type fileSink struct {
+	sink
+	file *os.File
+}
+
+func (s *fileSink) Close() error {
+	err := s.sink.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// SinkFileName 返回格式对应的默认文件名
+func SinkFileName(format string) string {
+	if format == SinkTable {
+		return "metrics.txt"
+	}
+	return "metrics." + format
+}
+
+// ClusterSinkFileName 返回格式对应的集群统计数据的默认文件名
+func ClusterSinkFileName(format string) string {
+	if format == SinkTable {
+		return "cluster.txt"
+	}
+	return "cluster." + format
+}
+
+// NewTableSink 构造输出定宽表格的MetricsSink，每个Tick输出一次表头
+func NewTableSink(w io.Writer) MetricsSink {
+	return newTableSink(w)
+}
+
+// NewClusterTableSink 构造输出定宽表格的ClusterMetricsSink，每个Tick输出一行集群统计数据
+func NewClusterTableSink(w io.Writer) ClusterMetricsSink {
+	return newTableSink(w)
+}
+
+func newTableSink(w io.Writer) sink {
+	return &tableSink{w: w}
+}
+
+type tableSink struct {
+	w io.Writer
+}
+
+func (s *tableSink) Write(_ int, metrics []*NodeMetrics) error {
+	_, err := fmt.Fprintln(s.w, "Node                \tCPU  \tCPUALL\tCPU60\tCPU300\tCPU1500\tMem  \tMemALL\tMem60\tMem300\tMem1500\tLoad \tLoadALL\tLoad60\tLoad300\tLoad1500")
+	if err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		_, err = fmt.Fprintf(s.w, "%-20s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", metric.Node,
+			metric.CpuUsageLastTick, metric.CpuUsageAverage, metric.CpuUsageAverageIn60Ticks, metric.CpuUsageAverageIn300Ticks, metric.CpuUsageAverageIn1500Ticks,
+			metric.MemUsageLastTick, metric.MemUsageAverage, metric.MemUsageAverageIn60Ticks, metric.MemUsageAverageIn300Ticks, metric.MemUsageAverageIn1500Ticks,
+			metric.LoadLastTick, metric.LoadAverage, metric.LoadAverageIn60Ticks, metric.LoadAverageIn300Ticks, metric.LoadAverageIn1500Ticks)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *tableSink) WriteCluster(m *ClusterMetrics) error {
+	_, err := fmt.Fprintln(s.w, "Cluster\tNodes\tCPU  \tCPUALL\tCPU60\tCPUGap\tMem  \tMemALL\tMem60\tMemGap\tPending\tRunning\tSucceeded\tFailed\tStrandedCPU\tEmpty\tFrag ")
+	if err != nil {
+		return err
+	}
+	fragmentation := m.FragmentationMetrics
+	if fragmentation == nil {
+		fragmentation = &FragmentationMetrics{}
+	}
+	_, err = fmt.Fprintf(s.w, "%-7d\t%-5d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%-7d\t%-7d\t%-9d\t%-6d\t%-11.3f\t%-5d\t%.3f\n", m.Tick, m.Nodes,
+		m.CpuUsageLastTick, m.CpuUsageAverage, m.CpuUsageAverageIn60Ticks, m.CpuAllocationGapLastTick,
+		m.MemUsageLastTick, m.MemUsageAverage, m.MemUsageAverageIn60Ticks, m.MemAllocationGapLastTick,
+		m.PendingPods, m.RunningPods, m.SucceededPods, m.FailedPods,
+		fragmentation.StrandedCpu, fragmentation.EmptyNodes, fragmentation.FragmentationIndex)
+	return err
+}
+
+func (s *tableSink) Close() error {
+	return nil
+}
+
+// NewCSVSink 构造输出CSV的MetricsSink，第一行为表头MetricsColumns
+func NewCSVSink(w io.Writer) MetricsSink {
+	return newCSVSink(w)
+}
+
+// NewClusterCSVSink 构造输出CSV的ClusterMetricsSink，第一行为表头ClusterMetricsColumns
+func NewClusterCSVSink(w io.Writer) ClusterMetricsSink {
+	return newCSVSink(w)
+}
+
+// NewGzipCSVSink 构造输出gzip压缩CSV的MetricsSink
+func NewGzipCSVSink(w io.Writer) MetricsSink {
+	return newGzipCSVSink(w)
+}
+
+// NewClusterGzipCSVSink 构造输出gzip压缩CSV的ClusterMetricsSink
+func NewClusterGzipCSVSink(w io.Writer) ClusterMetricsSink {
+	return newGzipCSVSink(w)
+}
+
+func newCSVSink(w io.Writer) sink {
+	return &csvSink{writer: csv.NewWriter(w)}
+}
+
+func newGzipCSVSink(w io.Writer) sink {
+	gz := gzip.NewWriter(w)
+	return &csvSink{gz: gz, writer: csv.NewWriter(gz)}
+}
+
+type csvSink struct {
+	gz     *gzip.Writer
+	writer *csv.Writer
+	// headerWritten 表头在第一次写入时输出
+	headerWritten bool
+}
+
+func (s *csvSink) writeHeader(columns []string) error {
+	if s.headerWritten {
+		return nil
+	}
+	s.headerWritten = true
+	return s.writer.Write(columns)
+}
+
+func (s *csvSink) Write(tick int, metrics []*NodeMetrics) error {
+	if err := s.writeHeader(MetricsColumns); err != nil {
+		return err
+	}
+	record := make([]string, len(MetricsColumns))
+	for _, metric := range metrics {
+		record[0] = strconv.Itoa(tick)
+		record[1] = metric.Node
+		values := metric.values()
+		for i, v := range values {
+			record[i+2] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		fragmentation := metric.NodeFragmentation
+		if fragmentation == nil {
+			fragmentation = &NodeFragmentation{}
+		}
+		i := len(values) + 2
+		record[i] = strconv.FormatFloat(fragmentation.LargestPodCpu, 'f', -1, 64)
+		record[i+1] = strconv.FormatInt(fragmentation.LargestPodMem, 10)
+		record[i+2] = strconv.FormatFloat(fragmentation.StrandedCpu, 'f', -1, 64)
+		record[i+3] = strconv.FormatInt(fragmentation.StrandedMem, 10)
+		if err := s.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *csvSink) WriteCluster(m *ClusterMetrics) error {
+	if err := s.writeHeader(ClusterMetricsColumns); err != nil {
+		return err
+	}
+	record := make([]string, 0, len(ClusterMetricsColumns))
+	for _, v := range []int{m.Tick, m.Nodes} {
+		record = append(record, strconv.Itoa(v))
+	}
+	record = append(record, strconv.FormatFloat(m.CpuCapacity, 'f', -1, 64),
+		strconv.FormatFloat(m.CpuUsed, 'f', -1, 64), strconv.FormatFloat(m.CpuAllocated, 'f', -1, 64))
+	for _, v := range []int64{m.MemCapacity, m.MemUsed, m.MemAllocated} {
+		record = append(record, strconv.FormatInt(v, 10))
+	}
+	for _, v := range []int{m.PendingPods, m.RunningPods, m.SucceededPods, m.FailedPods} {
+		record = append(record, strconv.Itoa(v))
+	}
+	for _, v := range m.ClusterPeriodMetrics.values() {
+		record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	fragmentation := m.FragmentationMetrics
+	if fragmentation == nil {
+		fragmentation = &FragmentationMetrics{}
+	}
+	record = append(record, strconv.FormatFloat(fragmentation.StrandedCpu, 'f', -1, 64),
+		strconv.FormatInt(fragmentation.StrandedMem, 10), strconv.Itoa(fragmentation.EmptyNodes),
+		strconv.FormatFloat(fragmentation.FragmentationIndex, 'f', -1, 64))
+	return s.writer.Write(record)
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	err := s.writer.Error()
+	if s.gz != nil {
+		if gzErr := s.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+// NewJSONLinesSink 构造输出JSON Lines的MetricsSink，每行为一个NodeMetrics
+func NewJSONLinesSink(w io.Writer) MetricsSink {
+	return newJSONLinesSink(w)
+}
+
+// NewClusterJSONLinesSink 构造输出JSON Lines的ClusterMetricsSink，每行为一个ClusterMetrics
+func NewClusterJSONLinesSink(w io.Writer) ClusterMetricsSink {
+	return newJSONLinesSink(w)
+}
+
+func newJSONLinesSink(w io.Writer) sink {
+	buffered := bufio.NewWriter(w)
+	return &jsonLinesSink{buffered: buffered, encoder: json.NewEncoder(buffered)}
+}
+
+type jsonLinesSink struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (s *jsonLinesSink) Write(tick int, metrics []*NodeMetrics) error {
+	for _, metric := range metrics {
+		metric.Tick = tick
+		if err := s.encoder.Encode(metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonLinesSink) WriteCluster(m *ClusterMetrics) error {
+	return s.encoder.Encode(m)
+}
+
+func (s *jsonLinesSink) Close() error {
+	return s.buffered.Flush()
+}
+
+// NewMultiSink 构造将统计数据同时写入多个MetricsSink的MetricsSink
+func NewMultiSink(sinks ...MetricsSink) MetricsSink {
+	return multiSink(sinks)
+}
+
+type multiSink []MetricsSink
+
+func (s multiSink) Write(tick int, metrics []*NodeMetrics) error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.Write(tick, metrics); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (s multiSink) Close() error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+// NewMultiClusterSink 构造将集群统计数据同时写入多个ClusterMetricsSink的ClusterMetricsSink
+func NewMultiClusterSink(sinks ...ClusterMetricsSink) ClusterMetricsSink {
+	return multiClusterSink(sinks)
+}
+
+type multiClusterSink []ClusterMetricsSink
+
+func (s multiClusterSink) WriteCluster(metrics *ClusterMetrics) error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.WriteCluster(metrics); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (s multiClusterSink) Close() error {
+	errs := make([]string, 0)
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
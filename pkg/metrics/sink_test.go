@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testNodeMetrics() []*NodeMetrics {
+	return []*NodeMetrics{
+		{Tick: 3, Node: "a", PeriodMetrics: &PeriodMetrics{CpuUsageLastTick: 0.5, LoadLastTick: 1}},
+		{Tick: 3, Node: "b", PeriodMetrics: &PeriodMetrics{MemUsageAverage: 0.25}},
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewCSVSink(buf)
+	if err := sink.Write(3, testNodeMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(4, testNodeMetrics()[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("should have 4 lines, not %d", len(lines))
+	}
+	if lines[0] != strings.Join(MetricsColumns, ",") {
+		t.Errorf("wrong header %s", lines[0])
+	}
+	if lines[1] != "3,a,0.5,0,0,0,0,0,0,0,0,0,1,0,0,0,0,0,0,0,0" {
+		t.Errorf("wrong record %s", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "4,a,") {
+		t.Errorf("wrong record %s", lines[3])
+	}
+}
+
+func TestGzipCSVSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewGzipCSVSink(buf)
+	_ = sink.Write(3, testNodeMetrics())
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "\n") != 3 {
+		t.Errorf("should have 3 lines: %s", data)
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewJSONLinesSink(buf)
+	_ = sink.Write(3, testNodeMetrics())
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("should have 2 lines, not %d", len(lines))
+	}
+	m := &NodeMetrics{}
+	if err := json.Unmarshal([]byte(lines[1]), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Tick != 3 || m.Node != "b" || m.MemUsageAverage != 0.25 {
+		t.Errorf("wrong metrics %v", m)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = NewSink("parquet", ""); err == nil {
+		t.Error("should not support parquet")
+	}
+
+	sinks := make([]MetricsSink, 0, 4)
+	for _, format := range []string{SinkTable, SinkCSV, SinkJSONLines, SinkGzipCSV} {
+		sink, err := NewSink(format, filepath.Join(dir, SinkFileName(format)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sinks = append(sinks, sink)
+	}
+	sink := NewMultiSink(sinks...)
+	if err = sink.Write(3, testNodeMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"metrics.txt", "metrics.csv", "metrics.jsonl", "metrics.csv.gz"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.Size() == 0 {
+			t.Errorf("%s should not be empty", name)
+		}
+	}
+}
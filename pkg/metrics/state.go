@@ -0,0 +1,73 @@
+package metrics
+
+import "fmt"
+
+// AggregatorState 节点统计器的状态，保存在模拟器的快照中
+type AggregatorState struct {
+	// Windows 依次为CPU使用率、内存使用率与负载的统计状态
+	Windows []*WindowState `json:"windows"`
+	Latest  *PeriodMetrics `json:"latest,omitempty"`
+}
+
+// ClusterAggregatorState 集群统计器的状态，保存在模拟器的快照中
+type ClusterAggregatorState struct {
+	// Windows 依次为CPU使用率、内存使用率、CPU分配差距与内存分配差距的统计状态
+	Windows []*WindowState        `json:"windows"`
+	Latest  *ClusterPeriodMetrics `json:"latest,omitempty"`
+}
+
+// WindowState 一项指标的统计状态。保存了各个时间窗口内的原始数据与累加值，恢复之后的统计结果与没有中断时完全相同
+type WindowState struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	// Queues 依次为最近60、300与1500个Tick的时间窗口
+	Queues []*RingQueueState `json:"queues"`
+}
+
+// RingQueueState 一个时间窗口的状态
+type RingQueueState struct {
+	Values []float64 `json:"values"`
+	Head   int       `json:"head"`
+	Sum    float64   `json:"sum"`
+}
+
+func (w *window) saveState() *WindowState {
+	return &WindowState{
+		Count:  w.count,
+		Sum:    w.sum,
+		Queues: []*RingQueueState{w.q60.saveState(), w.q300.saveState(), w.q1500.saveState()},
+	}
+}
+
+func (w *window) restoreState(state *WindowState) error {
+	if len(state.Queues) != 3 {
+		return fmt.Errorf("window state should have 3 queues, not %d", len(state.Queues))
+	}
+	for i, queue := range []*ringQueue{w.q60, w.q300, w.q1500} {
+		if err := queue.restoreState(state.Queues[i]); err != nil {
+			return err
+		}
+	}
+	w.count = state.Count
+	w.sum = state.Sum
+	return nil
+}
+
+func (queue *ringQueue) saveState() *RingQueueState {
+	return &RingQueueState{
+		Values: append([]float64{}, queue.arr[:queue.size]...),
+		Head:   queue.head,
+		Sum:    queue.sum,
+	}
+}
+
+func (queue *ringQueue) restoreState(state *RingQueueState) error {
+	if len(state.Values) > len(queue.arr) || state.Head < 0 || (state.Head > 0 && state.Head >= len(state.Values)) {
+		return fmt.Errorf("invalid state of ring queue with capacity %d", len(queue.arr))
+	}
+	copy(queue.arr, state.Values)
+	queue.size = len(state.Values)
+	queue.head = state.Head
+	queue.sum = state.Sum
+	return nil
+}
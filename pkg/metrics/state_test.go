@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAggregatorState(t *testing.T) {
+	agg := NewAggregator()
+	for i := 0; i < 2000; i++ {
+		agg.Aggregate(&TickMetrics{CpuUsage: float64(i%7) * 0.1, MemUsage: float64(i%3) * 0.3, Load: float64(i%11) * 0.07})
+	}
+	data, err := json.Marshal(agg.SaveState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &AggregatorState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewAggregator()
+	if err = restored.RestoreState(state); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(agg.Get(), restored.Get()) {
+		t.Errorf("latest metrics should be restored")
+	}
+	// 恢复之后的统计结果应该与没有中断时完全相同
+	for i := 0; i < 100; i++ {
+		met := &TickMetrics{CpuUsage: float64(i%5) * 0.2, MemUsage: 0.5, Load: float64(i) * 0.01}
+		if !reflect.DeepEqual(agg.Aggregate(met), restored.Aggregate(met)) {
+			t.Fatalf("restored aggregator differs at tick %d", i)
+		}
+	}
+}
+
+func TestClusterAggregatorState(t *testing.T) {
+	agg := NewClusterAggregator()
+	met := &ClusterTickMetrics{Nodes: 2, CpuCapacity: 8, MemCapacity: 100, CpuAllocated: 6, MemAllocated: 50}
+	for i := 0; i < 100; i++ {
+		met.CpuUsed = float64(i % 5)
+		agg.Aggregate(met)
+	}
+	restored := NewClusterAggregator()
+	if err := restored.RestoreState(agg.SaveState()); err != nil {
+		t.Fatal(err)
+	}
+	met.CpuUsed = 3
+	if !reflect.DeepEqual(agg.Aggregate(met), restored.Aggregate(met)) {
+		t.Errorf("restored cluster aggregator differs")
+	}
+
+	if err := restored.RestoreState(&ClusterAggregatorState{}); err == nil {
+		t.Errorf("should reject invalid state")
+	}
+}
@@ -6,24 +6,28 @@ type TickMetrics struct {
 	// MemUsage 内存使用百分比
 	MemUsage float64
 	Load     float64
+	// CpuUsed 实际使用的CPU核数
+	CpuUsed float64
+	// MemUsed 实际使用的内存字节数
+	MemUsed int64
 }
 
 type PeriodMetrics struct {
-	CpuUsageLastTick           float64
-	CpuUsageAverage            float64
-	CpuUsageAverageIn60Ticks   float64
-	CpuUsageAverageIn300Ticks  float64
-	CpuUsageAverageIn1500Ticks float64
-	MemUsageLastTick           float64
-	MemUsageAverage            float64
-	MemUsageAverageIn60Ticks   float64
-	MemUsageAverageIn300Ticks  float64
-	MemUsageAverageIn1500Ticks float64
-	LoadLastTick               float64
-	LoadAverage                float64
-	LoadAverageIn60Ticks       float64
-	LoadAverageIn300Ticks      float64
-	LoadAverageIn1500Ticks     float64
+	CpuUsageLastTick           float64 `json:"cpuUsageLastTick"`
+	CpuUsageAverage            float64 `json:"cpuUsageAverage"`
+	CpuUsageAverageIn60Ticks   float64 `json:"cpuUsageAverageIn60Ticks"`
+	CpuUsageAverageIn300Ticks  float64 `json:"cpuUsageAverageIn300Ticks"`
+	CpuUsageAverageIn1500Ticks float64 `json:"cpuUsageAverageIn1500Ticks"`
+	MemUsageLastTick           float64 `json:"memUsageLastTick"`
+	MemUsageAverage            float64 `json:"memUsageAverage"`
+	MemUsageAverageIn60Ticks   float64 `json:"memUsageAverageIn60Ticks"`
+	MemUsageAverageIn300Ticks  float64 `json:"memUsageAverageIn300Ticks"`
+	MemUsageAverageIn1500Ticks float64 `json:"memUsageAverageIn1500Ticks"`
+	LoadLastTick               float64 `json:"loadLastTick"`
+	LoadAverage                float64 `json:"loadAverage"`
+	LoadAverageIn60Ticks       float64 `json:"loadAverageIn60Ticks"`
+	LoadAverageIn300Ticks      float64 `json:"loadAverageIn300Ticks"`
+	LoadAverageIn1500Ticks     float64 `json:"loadAverageIn1500Ticks"`
 }
 
 type ServiceCallMetrics struct {
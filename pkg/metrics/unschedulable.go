@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 调度失败原因的分类
+const (
+	ReasonInsufficientCpu    = "InsufficientCpu"
+	ReasonInsufficientMemory = "InsufficientMemory"
+	ReasonTooManyPods        = "TooManyPods"
+	ReasonTaint              = "Taint"
+	// ReasonAffinity 节点选择器、节点亲和性以及Pod亲和性与反亲和性
+	ReasonAffinity          = "Affinity"
+	ReasonTopologySpread    = "TopologySpread"
+	ReasonNodeUnschedulable = "NodeUnschedulable"
+	ReasonNoNodes           = "NoNodes"
+	ReasonOther             = "Other"
+)
+
+// reasonPatterns 调度器给出的节点不可用原因中的关键字与分类，按顺序匹配
+var reasonPatterns = []struct {
+	pattern string
+	reason  string
+}{
+	{"Insufficient cpu", ReasonInsufficientCpu},
+	{"Insufficient memory", ReasonInsufficientMemory},
+	{"Too many pods", ReasonTooManyPods},
+	{"taint", ReasonTaint},
+	{"affinity", ReasonAffinity},
+	{"node selector", ReasonAffinity},
+	{"topology spread", ReasonTopologySpread},
+	{"were unschedulable", ReasonNodeUnschedulable},
+}
+
+// resourceReasons 只由资源不足导致的失败可以使用现有节点的空闲资源，其他原因的失败需要新的节点
+var resourceReasons = map[string]bool{
+	ReasonInsufficientCpu:    true,
+	ReasonInsufficientMemory: true,
+	ReasonTooManyPods:        true,
+}
+
+// ClassifyUnschedulable 将调度失败的信息分类，返回排序后的各个分类。调度器的信息形如“0/3 nodes are available:
+// 1 Insufficient cpu, 2 node(s) had taints that the pod didn't tolerate.”，各个节点的原因分别分类
+func ClassifyUnschedulable(message string) []string {
+	const available = "nodes are available: "
+	idx := strings.Index(message, available)
+	if idx < 0 {
+		if strings.Contains(message, "no nodes available") {
+			return []string{ReasonNoNodes}
+		}
+		return []string{ReasonOther}
+	}
+	set := make(map[string]bool)
+	for _, part := range strings.Split(strings.TrimSuffix(message[idx+len(available):], "."), ", ") {
+		reason := ReasonOther
+		for _, p := range reasonPatterns {
+			if strings.Contains(part, p.pattern) {
+				reason = p.reason
+				break
+			}
+		}
+		set[reason] = true
+	}
+	reasons := make([]string, 0, len(set))
+	for reason := range set {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// PodRequest Pod请求的资源
+type PodRequest struct {
+	Cpu float64
+	Mem int64
+}
+
+// UnschedulableGroup 最后一次调度失败的原因分类相同的一组Pod
+type UnschedulableGroup struct {
+	Reasons []string `json:"reasons"`
+	// Message 其中一个Pod最后一次调度失败的信息
+	Message string `json:"message"`
+	// Pods 至少调度失败过一次的Pod数量，包括之后绑定成功的Pod
+	Pods int `json:"pods"`
+	// Pending 直到模拟结束仍未绑定的Pod数量
+	Pending int `json:"pending"`
+	// Retries 调度失败的总次数
+	Retries    int `json:"retries"`
+	MaxRetries int `json:"maxRetries"`
+	// AdditionalCpu、AdditionalMem与AdditionalPods 使仍未绑定的Pod都能够调度所需增加的最小节点容量
+	AdditionalCpu  float64 `json:"additionalCpu"`
+	AdditionalMem  int64   `json:"additionalMem"`
+	AdditionalPods int     `json:"additionalPods"`
+	// Examples 部分仍未绑定的Pod的名称
+	Examples []string `json:"examples"`
+}
+
+// UnschedulableReport 调度失败的Pod的诊断报告
+type UnschedulableReport struct {
+	// Pods 至少调度失败过一次的Pod数量
+	Pods int `json:"pods"`
+	// Pending 至少调度失败过一次，并且直到模拟结束仍未绑定的Pod数量
+	Pending int `json:"pending"`
+	// Groups 按照Pod数量从多到少排列的各组
+	Groups []*UnschedulableGroup `json:"groups"`
+}
+
+// maxExamples 每组最多列出的Pod名称数量
+const maxExamples = 5
+
+// DiagnoseUnschedulable 按照最后一次调度失败的原因将Pod分组，并计算每组仍未绑定的Pod所需增加的节点容量。requests为仍未
+// 绑定的Pod的请求，nodes为模拟结束时各个节点的资源状态，其中的空闲资源需要与调度器相同，按照已绑定的Pod的请求计算，
+// 而不是实际使用量。
+//
+// 只由资源不足导致失败的组，先按照请求从大到小依次放入现有节点的空闲资源（First Fit Decreasing），放不下的Pod的请求之和
+// 即为需要增加的容量；其他原因导致失败的组，现有节点无法使用，需要一个能够容纳所有Pod的新节点，且新节点需要满足这些Pod的
+// 污点容忍与亲和性要求。各组分别计算，互不占用空闲资源。
+func DiagnoseUnschedulable(records []*PodRecord, requests map[string]*PodRequest, nodes []*NodeResource) *UnschedulableReport {
+	report := &UnschedulableReport{Groups: make([]*UnschedulableGroup, 0)}
+	groups := make(map[string]*UnschedulableGroup)
+	pending := make(map[string][]string)
+	for _, record := range records {
+		if len(record.UnschedulableReasons) == 0 {
+			continue
+		}
+		message := record.UnschedulableReasons[len(record.UnschedulableReasons)-1]
+		reasons := ClassifyUnschedulable(message)
+		key := strings.Join(reasons, "+")
+		group, ok := groups[key]
+		if !ok {
+			group = &UnschedulableGroup{Reasons: reasons, Message: message, Examples: []string{}}
+			groups[key] = group
+			report.Groups = append(report.Groups, group)
+		}
+		retries := record.Attempts
+		if record.BindTick >= 0 {
+			retries--
+		}
+		group.Pods++
+		group.Retries += retries
+		if retries > group.MaxRetries {
+			group.MaxRetries = retries
+		}
+		report.Pods++
+		if record.BindTick < 0 {
+			group.Pending++
+			report.Pending++
+			pending[key] = append(pending[key], record.Name)
+			if len(group.Examples) < maxExamples {
+				group.Examples = append(group.Examples, record.Name)
+			}
+		}
+	}
+
+	for key, group := range groups {
+		podRequests := make([]*PodRequest, 0, len(pending[key]))
+		for _, name := range pending[key] {
+			if request, ok := requests[name]; ok {
+				podRequests = append(podRequests, request)
+			}
+		}
+		onlyResources := true
+		for _, reason := range group.Reasons {
+			onlyResources = onlyResources && resourceReasons[reason]
+		}
+		var free []*NodeResource
+		if onlyResources {
+			free = nodes
+		}
+		group.AdditionalCpu, group.AdditionalMem, group.AdditionalPods = additionalCapacity(podRequests, free)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Pods > report.Groups[j].Pods
+	})
+	return report
+}
+
+// additionalCapacity 将Pod按照请求从大到小放入nodes的空闲资源，返回放不下的Pod的请求之和
+func additionalCapacity(requests []*PodRequest, nodes []*NodeResource) (cpu float64, mem int64, pods int) {
+	sorted := make([]*PodRequest, len(requests))
+	copy(sorted, requests)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Cpu != sorted[j].Cpu {
+			return sorted[i].Cpu > sorted[j].Cpu
+		}
+		return sorted[i].Mem > sorted[j].Mem
+	})
+	free := make([]NodeResource, len(nodes))
+	for i, node := range nodes {
+		free[i] = *node
+	}
+
+	for _, request := range sorted {
+		placed := false
+		for i := range free {
+			node := &free[i]
+			if node.Pods < node.PodCapacity && node.CpuFree >= request.Cpu && node.MemFree >= request.Mem {
+				node.CpuFree -= request.Cpu
+				node.MemFree -= request.Mem
+				node.Pods++
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			cpu += request.Cpu
+			mem += request.Mem
+			pods++
+		}
+	}
+	return cpu, mem, pods
+}
+
+// WriteUnschedulableReport 以表格形式输出诊断报告
+func WriteUnschedulableReport(w io.Writer, report *UnschedulableReport) error {
+	_, err := fmt.Fprintf(w, "Unschedulable pods %d, still pending %d\n", report.Pods, report.Pending)
+	if err != nil || len(report.Groups) == 0 {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "Reasons                       \tPods \tPending\tRetries\tMaxRetries\tAddCPU\tAddMem      \tAddPods")
+	if err != nil {
+		return err
+	}
+	for _, g := range report.Groups {
+		_, err = fmt.Fprintf(w, "%-30s\t%-5d\t%-7d\t%-7d\t%-10d\t%-6.2f\t%-12d\t%d\n", strings.Join(g.Reasons, "+"), g.Pods,
+			g.Pending, g.Retries, g.MaxRetries, g.AdditionalCpu, g.AdditionalMem, g.AdditionalPods)
+		if err != nil {
+			return err
+		}
+	}
+	for _, g := range report.Groups {
+		if _, err = fmt.Fprintf(w, "%s: %s\n", strings.Join(g.Reasons, "+"), g.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
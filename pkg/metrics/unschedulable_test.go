@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassifyUnschedulable(t *testing.T) {
+	cases := map[string][]string{
+		"0/3 nodes are available: 1 Insufficient cpu, 2 Insufficient memory.": {ReasonInsufficientCpu, ReasonInsufficientMemory},
+		"0/3 nodes are available: 1 Insufficient cpu, 2 node(s) had taints that the pod didn't tolerate.": {
+			ReasonInsufficientCpu, ReasonTaint},
+		"0/2 nodes are available: 2 node(s) didn't match node selector.":                         {ReasonAffinity},
+		"0/2 nodes are available: 2 node(s) didn't match pod anti-affinity rules.":               {ReasonAffinity},
+		"0/2 nodes are available: 2 node(s) didn't match pod topology spread constraints.":       {ReasonTopologySpread},
+		"0/2 nodes are available: 1 Too many pods, 1 node(s) were unschedulable.":                {ReasonNodeUnschedulable, ReasonTooManyPods},
+		"0/1 nodes are available: 1 node(s) didn't have free ports for the requested pod ports.": {ReasonOther},
+		"no nodes available to schedule pods":                                                    {ReasonNoNodes},
+		"scheduling timed out after 10s":                                                         {ReasonOther},
+	}
+	for message, expected := range cases {
+		if reasons := ClassifyUnschedulable(message); !reflect.DeepEqual(reasons, expected) {
+			t.Errorf("%s should be %v, not %v", message, expected, reasons)
+		}
+	}
+}
+
+func TestDiagnoseUnschedulable(t *testing.T) {
+	const cpuMessage = "0/2 nodes are available: 2 Insufficient cpu."
+	const taintMessage = "0/2 nodes are available: 2 node(s) had taints that the pod didn't tolerate."
+	newRecord := func(name string, attempts, bindTick int, reasons ...string) *PodRecord {
+		record := NewPodRecord(name, "BatchPod", 0)
+		record.Attempts = attempts
+		record.BindTick = bindTick
+		record.UnschedulableReasons = reasons
+		return record
+	}
+	records := []*PodRecord{
+		newRecord("ok", 1, 0),
+		newRecord("big", 3, -1, cpuMessage),
+		newRecord("medium", 2, -1, cpuMessage),
+		newRecord("small", 2, -1, cpuMessage),
+		newRecord("retried", 4, 5, taintMessage, cpuMessage),
+		newRecord("tainted", 2, -1, taintMessage),
+	}
+	requests := map[string]*PodRequest{
+		"big":     {Cpu: 6, Mem: 1 << 30},
+		"medium":  {Cpu: 3, Mem: 1 << 30},
+		"small":   {Cpu: 1, Mem: 1 << 30},
+		"tainted": {Cpu: 1, Mem: 1 << 30},
+	}
+	nodes := []*NodeResource{
+		{CpuCapacity: 8, CpuFree: 2, MemCapacity: 8 << 30, MemFree: 4 << 30, PodCapacity: 10, Pods: 2},
+		{CpuCapacity: 8, CpuFree: 2.5, MemCapacity: 8 << 30, MemFree: 4 << 30, PodCapacity: 10, Pods: 2},
+	}
+
+	report := DiagnoseUnschedulable(records, requests, nodes)
+	if report.Pods != 5 || report.Pending != 4 || len(report.Groups) != 2 {
+		t.Fatalf("wrong report %+v", report)
+	}
+	cpu := report.Groups[0]
+	if !reflect.DeepEqual(cpu.Reasons, []string{ReasonInsufficientCpu}) || cpu.Pods != 4 || cpu.Pending != 3 {
+		t.Fatalf("wrong cpu group %+v", cpu)
+	}
+	// retried最后绑定成功，4次尝试中3次失败
+	if cpu.Retries != 3+2+2+3 || cpu.MaxRetries != 3 {
+		t.Errorf("wrong retries %d, max %d", cpu.Retries, cpu.MaxRetries)
+	}
+	// small放入现有节点，big与medium需要新的容量
+	if !floatEquals(cpu.AdditionalCpu, 9) || cpu.AdditionalMem != 2<<30 || cpu.AdditionalPods != 2 {
+		t.Errorf("wrong additional capacity %f, %d, %d", cpu.AdditionalCpu, cpu.AdditionalMem, cpu.AdditionalPods)
+	}
+	if !reflect.DeepEqual(cpu.Examples, []string{"big", "medium", "small"}) {
+		t.Errorf("wrong examples %v", cpu.Examples)
+	}
+
+	// 污点导致的失败不能使用现有节点的空闲资源
+	taint := report.Groups[1]
+	if taint.Pending != 1 || !floatEquals(taint.AdditionalCpu, 1) || taint.AdditionalPods != 1 {
+		t.Errorf("wrong taint group %+v", taint)
+	}
+	if nodes[0].CpuFree != 2 {
+		t.Errorf("should not modify nodes")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteUnschedulableReport(buf, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Unschedulable pods 5, still pending 4\n") ||
+		!strings.Contains(buf.String(), "Taint: "+taintMessage) {
+		t.Errorf("wrong output %s", buf.String())
+	}
+}
@@ -22,6 +22,8 @@ func (alg *batchPodAlgorithm) Terminate() {
 type BatchPodState struct {
 	MemUsage  int64   `json:"memUsage"`
 	TotalTick float64 `json:"totalTick"`
+	// Terminated 是否已经被通知停止，仅用于恢复快照
+	Terminated bool `json:"terminated,omitempty"`
 }
 
 const BatchPod = "BatchPod"
@@ -39,10 +41,20 @@ var BatchPodFactory core.PodAlgorithmFactory = func(stateJson string, pod *core.
 		Pod:           pod,
 		MemUsage:      state.MemUsage,
 		TotalTick:     state.TotalTick,
-		markTerminate: false,
+		markTerminate: state.Terminated,
 	}, nil
 }
 
+// SaveState 保存剩余的运行时间，恢复时从剩余的时间继续运行
+func (alg *batchPodAlgorithm) SaveState() (string, error) {
+	data, err := json.Marshal(&BatchPodState{
+		MemUsage:   alg.MemUsage,
+		TotalTick:  alg.TotalTick,
+		Terminated: alg.markTerminate,
+	})
+	return string(data), err
+}
+
 func (alg *batchPodAlgorithm) ResourceRequest() (cpu float64, mem int64) {
 	return alg.Pod.CpuLimit, alg.MemUsage
 }
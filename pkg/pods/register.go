@@ -5,4 +5,5 @@ import "github.com/packagewjx/k8s-scheduler-sim/pkg/core"
 func init() {
 	core.RegisterPodAlgorithmFactory(BatchPod, BatchPodFactory)
 	core.RegisterPodAlgorithmFactory(SimServicePod, simServicePodFacory)
+	core.RegisterPodAlgorithmFactory(TraceReplayPod, TraceReplayPodFactory)
 }
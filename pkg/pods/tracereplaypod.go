@@ -0,0 +1,221 @@
+package pods
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/pkg/errors"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const TraceReplayPod = "TraceReplayPod"
+
+// UsageSample 数据集中Pod在某一时刻的资源使用情况
+type UsageSample struct {
+	// Tick 采样时刻，为相对于Pod开始运行的Tick数
+	Tick float64 `json:"tick"`
+	// Cpu 使用的CPU核数
+	Cpu float64 `json:"cpu"`
+	// Mem 使用的内存字节数
+	Mem int64 `json:"mem"`
+}
+
+// TraceReplayPodState TraceReplayPod的初始化状态。Samples与File二者选一，Samples为空时从File读取。
+type TraceReplayPodState struct {
+	Samples []*UsageSample `json:"samples,omitempty"`
+	// File 保存使用数据的CSV文件，每行的格式为tick,cpu,mem
+	File string `json:"file,omitempty"`
+	// Duration 数据集中Pod的运行时长，单位为Tick。为0时，视为最后一个采样点再经过一个采样间隔后结束。
+	Duration float64 `json:"duration,omitempty"`
+	// Progress、Elapsed与Terminated为运行状态，仅用于恢复快照
+	Progress   float64 `json:"progress,omitempty"`
+	Elapsed    int     `json:"elapsed,omitempty"`
+	Terminated bool    `json:"terminated,omitempty"`
+}
+
+// traceReplayPodAlgorithm 按照数据集中记录的资源使用时间序列运行的Pod。采样间隔大于一个Tick时，使用线性插值计算每个
+// Tick的资源使用。
+//
+// Pod维护其在时间序列上的进度。若节点分配的时间片不少于所记录的CPU使用，则每个Tick前进一个Tick；否则按照分配与记录的
+// 比值前进，以模拟资源不足时的减速。内存不足时同样按比例减速，但至少按照minMemoryRatio前进。进度到达Duration时，Pod运行结束。
+type traceReplayPodAlgorithm struct {
+	pod      *core.Pod
+	samples  []*UsageSample
+	duration float64
+	// progress 在时间序列上的进度，单位为Tick
+	progress float64
+	// elapsed 实际运行的Tick数，与progress的比值即为减速比
+	elapsed       int
+	markTerminate bool
+}
+
+// minMemoryRatio 内存不足时进度比例的下限。不足的内存视为换出到硬盘，Pod仍能缓慢运行，因此没有分配到内存时也不会停止
+const minMemoryRatio = 0.01
+
+var TraceReplayPodFactory core.PodAlgorithmFactory = func(stateJson string, pod *core.Pod) (core.PodAlgorithm, error) {
+	state := &TraceReplayPodState{}
+	if stateJson != "" {
+		err := json.Unmarshal([]byte(stateJson), state)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error parsing state json")
+		}
+	}
+
+	samples := state.Samples
+	if len(samples) == 0 && state.File != "" {
+		var err error
+		samples, err = readUsageSamples(state.File)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error reading usage file %s", state.File))
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no usage samples")
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Tick < samples[j].Tick
+	})
+
+	duration := state.Duration
+	if duration <= 0 {
+		last := samples[len(samples)-1].Tick
+		interval := float64(1)
+		if len(samples) > 1 {
+			interval = last - samples[len(samples)-2].Tick
+		}
+		duration = last + interval
+	}
+
+	return &traceReplayPodAlgorithm{
+		pod:           pod,
+		samples:       samples,
+		duration:      duration,
+		progress:      state.Progress,
+		elapsed:       state.Elapsed,
+		markTerminate: state.Terminated,
+	}, nil
+}
+
+// SaveState 保存采样数据与当前进度，恢复时不再需要读取数据文件
+func (alg *traceReplayPodAlgorithm) SaveState() (string, error) {
+	data, err := json.Marshal(&TraceReplayPodState{
+		Samples:    alg.samples,
+		Duration:   alg.duration,
+		Progress:   alg.progress,
+		Elapsed:    alg.elapsed,
+		Terminated: alg.markTerminate,
+	})
+	return string(data), err
+}
+
+func readUsageSamples(path string) ([]*UsageSample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 3
+	samples := make([]*UsageSample, 0, 128)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d", line))
+		}
+		sample := &UsageSample{}
+		if sample.Tick, err = strconv.ParseFloat(record[0], 64); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d", line))
+		}
+		if sample.Cpu, err = strconv.ParseFloat(record[1], 64); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d", line))
+		}
+		if sample.Mem, err = strconv.ParseInt(record[2], 10, 64); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("line %d", line))
+		}
+		samples = append(samples, sample)
+	}
+}
+
+// usageAt 使用线性插值计算进度为tick时的资源使用
+func (alg *traceReplayPodAlgorithm) usageAt(tick float64) (cpu float64, mem int64) {
+	idx := sort.Search(len(alg.samples), func(i int) bool {
+		return alg.samples[i].Tick > tick
+	})
+	if idx == 0 {
+		return alg.samples[0].Cpu, alg.samples[0].Mem
+	}
+	if idx == len(alg.samples) {
+		last := alg.samples[len(alg.samples)-1]
+		return last.Cpu, last.Mem
+	}
+	prev, next := alg.samples[idx-1], alg.samples[idx]
+	ratio := (tick - prev.Tick) / (next.Tick - prev.Tick)
+	cpu = prev.Cpu + (next.Cpu-prev.Cpu)*ratio
+	mem = prev.Mem + int64(float64(next.Mem-prev.Mem)*ratio)
+	return
+}
+
+func (alg *traceReplayPodAlgorithm) Tick(slot []float64, mem int64) (Load float64, MemUsage int64) {
+	if alg.markTerminate || alg.progress >= alg.duration {
+		alg.pod.Status.Phase = v1.PodSucceeded
+		return 0, 0
+	}
+	alg.elapsed++
+
+	cpuRequired, memRequired := alg.usageAt(alg.progress)
+	slotSum := float64(0)
+	for i := 0; i < len(slot); i++ {
+		slotSum += slot[i]
+	}
+
+	// 时间片不足时减速，并使用所有的时间片
+	step := float64(1)
+	if cpuRequired > 0 {
+		if slotSum < cpuRequired {
+			step = slotSum / cpuRequired
+			Load = 1
+		} else {
+			Load = cpuRequired / slotSum
+		}
+	}
+
+	MemUsage = memRequired
+	if memRequired > mem {
+		MemUsage = mem
+		ratio := float64(mem) / float64(memRequired)
+		if ratio < minMemoryRatio {
+			ratio = minMemoryRatio
+		}
+		step *= ratio
+	}
+
+	alg.progress += step
+	return
+}
+
+// ResourceRequest 返回当前进度所需要的CPU核数（向上取整）与内存，不超过Pod的限制
+func (alg *traceReplayPodAlgorithm) ResourceRequest() (cpu float64, mem int64) {
+	cpu, mem = alg.usageAt(alg.progress)
+	cpu = math.Ceil(cpu)
+	if cpu > alg.pod.CpuLimit {
+		cpu = alg.pod.CpuLimit
+	}
+	if alg.pod.MemLimit > 0 && mem > alg.pod.MemLimit {
+		mem = alg.pod.MemLimit
+	}
+	return
+}
+
+func (alg *traceReplayPodAlgorithm) Terminate() {
+	alg.markTerminate = true
+}
@@ -0,0 +1,155 @@
+package pods
+
+import (
+	"encoding/json"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"io/ioutil"
+	"k8s.io/api/core/v1"
+	"os"
+	"testing"
+)
+
+func newTraceReplayPod(t *testing.T, state *TraceReplayPodState) (*core.Pod, *traceReplayPodAlgorithm) {
+	argByte, _ := json.Marshal(state)
+	pod := &core.Pod{
+		Pod:      v1.Pod{},
+		CpuLimit: 4,
+		MemLimit: 1 << 30,
+	}
+	alg, err := TraceReplayPodFactory(string(argByte), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Algorithm = alg
+	return pod, alg.(*traceReplayPodAlgorithm)
+}
+
+func TestTraceReplayPodInterpolation(t *testing.T) {
+	pod, alg := newTraceReplayPod(t, &TraceReplayPodState{
+		Samples: []*UsageSample{
+			{Tick: 4, Cpu: 3, Mem: 500},
+			{Tick: 0, Cpu: 1, Mem: 100},
+		},
+	})
+
+	// 采样间隔为4，因此在第8个Tick结束
+	if alg.duration != 8 {
+		t.Errorf("duration should be 8, not %f", alg.duration)
+	}
+	if cpu, mem := alg.ResourceRequest(); cpu != 1 || mem != 100 {
+		t.Errorf("request should be 1 100, not %f %d", cpu, mem)
+	}
+
+	slot := []float64{1, 1, 1, 1}
+	expectedLoad := []float64{0.25, 0.375, 0.5, 0.625, 0.75, 0.75, 0.75, 0.75}
+	expectedMem := []int64{100, 200, 300, 400, 500, 500, 500, 500}
+	for i := 0; i < len(expectedLoad); i++ {
+		load, mem := alg.Tick(slot, 1<<30)
+		if load != expectedLoad[i] || mem != expectedMem[i] {
+			t.Errorf("tick %d should be %f %d, not %f %d", i, expectedLoad[i], expectedMem[i], load, mem)
+		}
+	}
+	if cpu, _ := alg.ResourceRequest(); cpu != 3 {
+		t.Errorf("request should be 3, not %f", cpu)
+	}
+
+	alg.Tick(slot, 1<<30)
+	if pod.Status.Phase != v1.PodSucceeded {
+		t.Error("pod should be succeeded")
+	}
+}
+
+func TestTraceReplayPodSlowdown(t *testing.T) {
+	_, alg := newTraceReplayPod(t, &TraceReplayPodState{
+		Samples:  []*UsageSample{{Tick: 0, Cpu: 2, Mem: 1000}},
+		Duration: 4,
+	})
+
+	// 只有一半的CPU，进度减半
+	load, _ := alg.Tick([]float64{1}, 1<<30)
+	if load != 1 || alg.progress != 0.5 {
+		t.Errorf("load should be 1 and progress 0.5, not %f %f", load, alg.progress)
+	}
+
+	// 内存也只有一半，进度再减半
+	_, mem := alg.Tick([]float64{1}, 500)
+	if mem != 500 || alg.progress != 0.75 {
+		t.Errorf("mem should be 500 and progress 0.75, not %d %f", mem, alg.progress)
+	}
+
+	ticks := 2
+	for alg.progress < alg.duration {
+		alg.Tick([]float64{1, 1}, 1<<30)
+		ticks++
+	}
+	if ticks != 6 || alg.elapsed != 6 {
+		t.Errorf("should take 6 ticks, not %d", ticks)
+	}
+}
+
+func TestTraceReplayPodFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "usage*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("0,0.5,100\n10,1.5,300\n")
+	_ = file.Close()
+
+	_, alg := newTraceReplayPod(t, &TraceReplayPodState{File: file.Name()})
+	if len(alg.samples) != 2 || alg.duration != 20 {
+		t.Fatalf("should read 2 samples with duration 20, not %d %f", len(alg.samples), alg.duration)
+	}
+	if cpu, mem := alg.usageAt(5); cpu != 1 || mem != 200 {
+		t.Errorf("usage at 5 should be 1 200, not %f %d", cpu, mem)
+	}
+}
+
+func TestTraceReplayPodSaveState(t *testing.T) {
+	_, alg := newTraceReplayPod(t, &TraceReplayPodState{
+		Samples: []*UsageSample{{Tick: 0, Cpu: 1, Mem: 100}, {Tick: 4, Cpu: 3, Mem: 500}},
+	})
+	alg.Tick([]float64{1, 1, 1, 1}, 1<<30)
+	alg.Tick([]float64{1}, 1<<30)
+
+	state, err := alg.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := TraceReplayPodFactory(state, &core.Pod{CpuLimit: 4, MemLimit: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := restored.(*traceReplayPodAlgorithm)
+	if replay.progress != alg.progress || replay.elapsed != alg.elapsed || replay.duration != alg.duration {
+		t.Errorf("restored state should be %f %d %f, not %f %d %f", alg.progress, alg.elapsed, alg.duration,
+			replay.progress, replay.elapsed, replay.duration)
+	}
+	for i := 0; i < 3; i++ {
+		load, mem := alg.Tick([]float64{1, 1}, 1<<30)
+		rload, rmem := replay.Tick([]float64{1, 1}, 1<<30)
+		if load != rload || mem != rmem {
+			t.Errorf("restored pod should run the same at tick %d", i)
+		}
+	}
+}
+
+func TestTraceReplayPodNoMemory(t *testing.T) {
+	pod, alg := newTraceReplayPod(t, &TraceReplayPodState{
+		Samples:  []*UsageSample{{Tick: 0, Cpu: 1, Mem: 1000}},
+		Duration: 1,
+	})
+
+	// 没有分配到内存时按照下限前进
+	_, mem := alg.Tick([]float64{1}, 0)
+	if mem != 0 || alg.progress != minMemoryRatio {
+		t.Errorf("mem should be 0 and progress %f, not %d %f", minMemoryRatio, mem, alg.progress)
+	}
+
+	for i := 0; i < 1000 && pod.Status.Phase != v1.PodSucceeded; i++ {
+		alg.Tick([]float64{1}, 0)
+	}
+	if pod.Status.Phase != v1.PodSucceeded {
+		t.Error("pod without memory should still succeed")
+	}
+}
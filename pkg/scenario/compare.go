@@ -0,0 +1,205 @@
+package scenario
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/metrics"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+	"sync"
+	"time"
+)
+
+// Variant 对比实验中的一组调度器配置
+type Variant struct {
+	// Name 变体的名称，用于输出比较结果
+	Name string
+	// SchedulerName 不为空时，所有工作负载的Pod使用该调度器Profile
+	SchedulerName string
+	// SchedulerConfig 不为空时，使用该KubeSchedulerConfiguration文件代替场景中的调度器配置
+	SchedulerConfig string
+}
+
+// Sinks 模拟器的统计数据输出
+type Sinks struct {
+	Metrics []metrics.MetricsSink
+	Cluster []metrics.ClusterMetricsSink
+	Pods    []metrics.PodMetricsSink
+}
+
+// close 关闭所有输出，用于构造模拟器失败时。模拟器运行结束后由模拟器关闭其输出
+func (s *Sinks) close() {
+	_ = metrics.NewMultiSink(s.Metrics...).Close()
+	_ = metrics.NewMultiClusterSink(s.Cluster...).Close()
+	_ = metrics.NewMultiPodSink(s.Pods...).Close()
+}
+
+// CompareOptions 对比实验的运行参数
+type CompareOptions struct {
+	// Parallel 并发运行各个变体。各个模拟器的随机选择互不影响，因此结果与顺序运行相同
+	Parallel bool
+	// Sinks 返回变体的统计数据输出，为nil时只收集比较所需的数据
+	Sinks func(v *Variant) (*Sinks, error)
+}
+
+// ComparisonResult 对比实验的结果，各项的顺序与变体的顺序相同
+type ComparisonResult struct {
+	// Seed 各个变体使用的随机数种子
+	Seed int64 `json:"seed"`
+	// Simulators 各个变体运行结束后的模拟器，用于查看调度记录等
+	Simulators []core.SchedulerSimulator `json:"-"`
+	Summaries  []*metrics.RunSummary     `json:"summaries"`
+	// Comparison 以第一个变体为基线的逐项比较
+	Comparison *metrics.Comparison `json:"comparison"`
+}
+
+// Compare 使用相同的种子，分别以各个变体的调度器配置运行同一场景，并比较集群使用率、Pod等待时间与在线服务的减速比例等指标。
+// 场景没有设置种子时随机生成一个，并记录在结果中以便复现。
+func Compare(s *Scenario, variants []*Variant, opts *CompareOptions) (*ComparisonResult, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variant to compare")
+	}
+	if opts == nil {
+		opts = &CompareOptions{}
+	}
+	seed := s.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		logrus.Infof("Scenario %s has no seed, comparing with seed %d", s.Name, seed)
+	}
+
+	result := &ComparisonResult{
+		Seed:       seed,
+		Simulators: make([]core.SchedulerSimulator, len(variants)),
+		Summaries:  make([]*metrics.RunSummary, len(variants)),
+	}
+	errs := make([]error, len(variants))
+	wg := sync.WaitGroup{}
+	for i, v := range variants {
+		run := func(i int, v *Variant) {
+			result.Simulators[i], result.Summaries[i], errs[i] = runVariant(s, v, seed, opts)
+		}
+		if !opts.Parallel {
+			run(i, v)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, v *Variant) {
+			defer wg.Done()
+			run(i, v)
+		}(i, v)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error running variant %s", variants[i].Name))
+		}
+	}
+	result.Comparison = metrics.Compare(result.Summaries)
+	return result, nil
+}
+
+// runVariant 使用变体的调度器配置运行场景的副本
+func runVariant(s *Scenario, v *Variant, seed int64, opts *CompareOptions) (core.SchedulerSimulator,
+	*metrics.RunSummary, error) {
+	variant, err := s.clone()
+	if err != nil {
+		return nil, nil, err
+	}
+	variant.Seed = seed
+	if v.SchedulerName != "" {
+		variant.SetSchedulerName(v.SchedulerName)
+	}
+	if v.SchedulerConfig != "" {
+		variant.SchedulerConfig = v.SchedulerConfig
+	}
+
+	sinks := &Sinks{}
+	if opts.Sinks != nil {
+		if sinks, err = opts.Sinks(v); err != nil {
+			return nil, nil, err
+		}
+		if sinks == nil {
+			sinks = &Sinks{}
+		}
+	}
+	sim, err := Build(variant)
+	if err != nil {
+		sinks.close()
+		return nil, nil, err
+	}
+	cluster := &lastClusterSink{}
+	slowdown := &serviceSlowdownSink{sim: sim, service: make(map[string]bool)}
+	sim.SetMetricsSinks(sinks.Metrics...)
+	sim.SetClusterMetricsSinks(append(sinks.Cluster, cluster)...)
+	sim.SetPodMetricsSinks(append(sinks.Pods, slowdown)...)
+
+	logrus.Infof("Running variant %s of scenario %s for %d ticks", v.Name, s.Name, variant.TotalTick)
+	sim.Run()
+
+	summary := &metrics.RunSummary{
+		Name:            v.Name,
+		Cluster:         cluster.last,
+		Pods:            metrics.SummarizePods(sim.GetPodRecords()),
+		ServiceSlowdown: metrics.NewDistribution(slowdown.values),
+	}
+	if summary.Cluster == nil {
+		summary.Cluster = &metrics.ClusterPeriodMetrics{}
+	}
+	return sim, summary, nil
+}
+
+// clone 通过序列化复制场景，使各个变体的修改互不影响
+func (s *Scenario) clone() (*Scenario, error) {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// lastClusterSink 保存最后一个Tick的集群统计数据，其中的平均值即为整个运行期间的平均值
+type lastClusterSink struct {
+	last *metrics.ClusterPeriodMetrics
+}
+
+func (s *lastClusterSink) WriteCluster(m *metrics.ClusterMetrics) error {
+	if m.ClusterPeriodMetrics != nil {
+		s.last = m.ClusterPeriodMetrics
+	}
+	return nil
+}
+
+func (s *lastClusterSink) Close() error {
+	return nil
+}
+
+// serviceSlowdownSink 收集在线服务Pod每个Tick的减速比例
+type serviceSlowdownSink struct {
+	sim core.SchedulerSimulator
+	// service 缓存Pod是否为在线服务
+	service map[string]bool
+	values  []float64
+}
+
+func (s *serviceSlowdownSink) WritePods(_ int, podMetrics []*metrics.PodTickMetrics) error {
+	for _, m := range podMetrics {
+		isService, ok := s.service[m.Pod]
+		if !ok {
+			if pod, err := s.sim.GetPod(m.Pod); err == nil {
+				isService = pod.Annotations[core.PodAnnotationAlgorithm] == pods.SimServicePod
+			}
+			s.service[m.Pod] = isService
+		}
+		if isService {
+			s.values = append(s.values, m.Slowdown)
+		}
+	}
+	return nil
+}
+
+func (s *serviceSlowdownSink) Close() error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package scenario
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	s, err := Parse([]byte(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.TotalTick = 30
+	s.Seed = 42
+
+	// 相同的配置运行两次，结果应该完全相同
+	result, err := Compare(s, []*Variant{
+		{Name: "a", SchedulerName: "default-scheduler"},
+		{Name: "b", SchedulerName: "default-scheduler"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Seed != 42 || len(result.Summaries) != 2 || len(result.Simulators) != 2 {
+		t.Fatalf("wrong result %+v", result)
+	}
+	a, b := result.Summaries[0], result.Summaries[1]
+	if a.Pods.Pods == 0 || a.Pods.Pods != b.Pods.Pods {
+		t.Errorf("both variants should have the same pods, not %d and %d", a.Pods.Pods, b.Pods.Pods)
+	}
+	if a.ServiceSlowdown.Count == 0 {
+		t.Errorf("should collect slowdown of service pods")
+	}
+	for _, m := range result.Comparison.Metrics {
+		if m.Values[0] != m.Values[1] || m.Winner != -1 {
+			t.Errorf("metric %s should be the same in both variants, got %v", m.Name, m.Values)
+		}
+	}
+	// 变体不应修改原场景
+	if s.Workloads[0].Pod.SchedulerName != "" || s.Seed != 42 {
+		t.Errorf("scenario should not be modified")
+	}
+
+	if _, err = Compare(s, nil, nil); err == nil {
+		t.Errorf("should fail without variants")
+	}
+}
@@ -0,0 +1,490 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/controllers"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace/alibaba"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace/google"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/apis/config"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// LoadFile 读取YAML或JSON格式的场景文件
+func LoadFile(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error reading scenario file %s", path))
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error parsing scenario file %s", path))
+	}
+	return s, nil
+}
+
+// Parse 解析YAML或JSON格式的场景，并检查其合法性
+func Parse(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate 检查场景的合法性，并填充默认值
+func (s *Scenario) Validate() error {
+	if s.TotalTick <= 0 {
+		return fmt.Errorf("totalTick must larger than 0")
+	}
+	if s.TickDuration != "" {
+		if d, err := time.ParseDuration(s.TickDuration); err != nil || d <= 0 {
+			return fmt.Errorf("invalid tickDuration %s", s.TickDuration)
+		}
+	}
+	if s.SchedulingBudget < 0 {
+		return fmt.Errorf("schedulingBudget must not be negative")
+	}
+	if s.SchedulingLatency != nil {
+		if _, err := core.NewSchedulingLatency(s.SchedulingLatency.Model, s.SchedulingLatency.Value); err != nil {
+			return err
+		}
+		// 调度器实际运行的时间每次都不同，确定性模式下只能使用与实际运行时间无关的模型
+		if s.Seed != 0 && s.SchedulingLatency.Model == core.LatencyMeasured {
+			return fmt.Errorf("scheduling latency %s can not be used with seed", core.LatencyMeasured)
+		}
+	}
+	if s.StopWhen != nil && (s.StopWhen.IdleTicks < 0 || s.StopWhen.MaxWaitTicks < 0) {
+		return fmt.Errorf("ticks of stopWhen must not be negative")
+	}
+
+	for i, pool := range s.NodePools {
+		if pool.Name == "" {
+			return fmt.Errorf("node pool %d has no name", i)
+		}
+		if pool.Count <= 0 {
+			return fmt.Errorf("node pool %s: count must larger than 0", pool.Name)
+		}
+		for _, quantity := range []string{pool.Cpu, pool.Memory, pool.Pods} {
+			if _, err := resource.ParseQuantity(quantity); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("node pool %s: invalid quantity %q", pool.Name, quantity))
+			}
+		}
+		if pool.CoreScheduler == "" {
+			pool.CoreScheduler = core.FairScheduler
+		}
+		if _, ok := core.GetCoreSchedulerFactory(pool.CoreScheduler); !ok {
+			return fmt.Errorf("node pool %s: no CoreScheduler %s", pool.Name, pool.CoreScheduler)
+		}
+	}
+
+	for i, cls := range s.PriorityClasses {
+		if cls.Name == "" {
+			return fmt.Errorf("PriorityClass %d has no name", i)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, w := range s.Workloads {
+		if w.Name == "" {
+			return fmt.Errorf("workload %d has no name", i)
+		}
+		if names[w.Name] {
+			return fmt.Errorf("duplicate workload %s", w.Name)
+		}
+		names[w.Name] = true
+		if err := w.validate(); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("workload %s", w.Name))
+		}
+	}
+	return nil
+}
+
+func (w *Workload) validate() error {
+	if w.DeployTick < 0 {
+		return fmt.Errorf("deployTick must not be negative")
+	}
+	switch w.DeployTime {
+	case "":
+		w.DeployTime = DeployBeforeUpdate
+	case DeployBeforeUpdate, DeployAfterUpdate:
+	default:
+		return fmt.Errorf("invalid deployTime %s", w.DeployTime)
+	}
+
+	switch w.Type {
+	case WorkloadService:
+		if w.Service == nil {
+			return fmt.Errorf("service workload requires service")
+		}
+		if w.Replicas <= 0 {
+			return fmt.Errorf("replicas must larger than 0")
+		}
+		return w.validatePod(pods.SimServicePod)
+	case WorkloadReplication:
+		if w.Replicas <= 0 {
+			return fmt.Errorf("replicas must larger than 0")
+		}
+		return w.validatePod(pods.SimServicePod)
+	case WorkloadBatch:
+		if w.Count <= 0 {
+			return fmt.Errorf("count must larger than 0")
+		}
+		return w.validatePod(pods.BatchPod)
+	case WorkloadTrace:
+		if w.Trace == nil {
+			return fmt.Errorf("trace workload requires trace")
+		}
+		if w.Trace.Format != TraceAlibaba && w.Trace.Format != TraceGoogle {
+			return fmt.Errorf("unsupported trace format %s", w.Trace.Format)
+		}
+		if w.Trace.Dir == "" {
+			return fmt.Errorf("trace dir is empty")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported workload type %s", w.Type)
+	}
+}
+
+func (w *Workload) validatePod(defaultAlgorithm string) error {
+	if w.Pod == nil {
+		return fmt.Errorf("%s workload requires pod", w.Type)
+	}
+	if w.Pod.Cpu <= 0 {
+		return fmt.Errorf("pod cpu must larger than 0")
+	}
+	if _, err := resource.ParseQuantity(w.Pod.Memory); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("invalid pod memory %q", w.Pod.Memory))
+	}
+	if w.Pod.Algorithm == "" {
+		w.Pod.Algorithm = defaultAlgorithm
+	}
+	if _, ok := core.GetPodAlgorithmFactory(w.Pod.Algorithm); !ok {
+		return fmt.Errorf("no pod algorithm %s", w.Pod.Algorithm)
+	}
+	if w.Pod.SchedulerName == "" {
+		w.Pod.SchedulerName = v1.DefaultSchedulerName
+	}
+	return nil
+}
+
+// Build 根据场景构造模拟集群，创建节点与PriorityClass，并注册所有工作负载的控制器
+func Build(s *Scenario) (core.SchedulerSimulator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	var config *schedulerapi.KubeSchedulerConfiguration
+	if s.SchedulerConfig != "" {
+		loaded, err := core.LoadSchedulerConfig(s.SchedulerConfig)
+		if err != nil {
+			return nil, err
+		}
+		config = loaded
+	}
+	sim, err := core.NewSchedulerSimulatorWithConfig(s.TotalTick, config)
+	if err != nil {
+		return nil, err
+	}
+	if s.Seed != 0 {
+		sim.SetDeterministic(s.Seed)
+	}
+	if s.TickDuration != "" {
+		d, _ := time.ParseDuration(s.TickDuration)
+		sim.SetTickDuration(d)
+	}
+	sim.SetEventDriven(s.EventDriven)
+	sim.SetSchedulingBudget(s.SchedulingBudget)
+	if s.SchedulingLatency != nil {
+		latency, err := core.NewSchedulingLatency(s.SchedulingLatency.Model, s.SchedulingLatency.Value)
+		if err != nil {
+			return nil, err
+		}
+		sim.SetSchedulingLatency(latency)
+	}
+	if s.StopWhen != nil {
+		if s.StopWhen.AllPodsFinished {
+			sim.AddStopCondition(core.StopWhenAllPodsFinished())
+		}
+		if s.StopWhen.IdleTicks > 0 {
+			sim.AddStopCondition(core.StopWhenIdleFor(s.StopWhen.IdleTicks))
+		}
+		if s.StopWhen.MaxWaitTicks > 0 {
+			sim.AddStopCondition(core.StopWhenWaitExceeds(s.StopWhen.MaxWaitTicks))
+		}
+	}
+	client := sim.GetKubernetesClient()
+
+	for _, pool := range s.NodePools {
+		for i := 0; i < pool.Count; i++ {
+			node := core.BuildNode(fmt.Sprintf("%s-%d", pool.Name, i), pool.Cpu, pool.Memory, pool.Pods, pool.CoreScheduler)
+			if _, err := client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("error creating node %s", node.Name))
+			}
+		}
+	}
+
+	for _, cls := range s.PriorityClasses {
+		_, err := client.SchedulingV1().PriorityClasses().Create(context.TODO(), &schedulingv1.PriorityClass{
+			ObjectMeta: metav1.ObjectMeta{Name: cls.Name},
+			Value:      cls.Value,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error creating PriorityClass %s", cls.Name))
+		}
+	}
+
+	if err := registerWorkloads(sim, s); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// BranchSetup 返回注册场景中所有工作负载控制器的函数，用于core.Branch的Setup。分支的节点、PriorityClass与Pod由快照恢复，
+// 因此只注册控制器。分支可以使用与原模拟器不同的场景，但需要恢复状态的工作负载应该与原场景同名。
+func BranchSetup(s *Scenario) func(sim core.SchedulerSimulator) error {
+	return func(sim core.SchedulerSimulator) error {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		return registerWorkloads(sim, s)
+	}
+}
+
+// registerWorkloads 注册所有工作负载的控制器，需要延迟部署的工作负载由ControllerDeployer部署
+func registerWorkloads(sim core.SchedulerSimulator, s *Scenario) error {
+	var deployer controllers.ControllerDeployer
+	for _, w := range s.Workloads {
+		controller, err := newController(sim, w)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error building workload %s", w.Name))
+		}
+
+		if w.DeployTick > 0 {
+			if deployer == nil {
+				deployer = controllers.NewControllerDeployer(sim)
+				sim.RegisterBeforeUpdateController(deployer)
+			}
+			when := controllers.BeforeUpdate
+			if w.DeployTime == DeployAfterUpdate {
+				when = controllers.AfterUpdate
+			}
+			deployer.DeployAt(controller, w.DeployTick, when)
+		} else if w.DeployTime == DeployAfterUpdate {
+			sim.RegisterAfterUpdateController(controller)
+		} else {
+			sim.RegisterBeforeUpdateController(controller)
+		}
+	}
+
+	return nil
+}
+
+// Run 读取场景文件，构造模拟集群并运行
+func Run(path string) error {
+	s, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	sim, err := Build(s)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Running scenario %s", s.Name)
+	sim.Run()
+	return nil
+}
+
+func newController(sim core.SchedulerSimulator, w *Workload) (core.Controller, error) {
+	switch w.Type {
+	case WorkloadService:
+		return newServiceController(sim, w)
+	case WorkloadReplication:
+		template, err := buildPodTemplate(w)
+		if err != nil {
+			return nil, err
+		}
+		return controllers.NewReplicationController(sim, w.Name, w.Replicas, func() *v1.Pod {
+			return copyPod(sim, template, w.Name)
+		}), nil
+	case WorkloadBatch:
+		return newBatchController(sim, w)
+	case WorkloadTrace:
+		return newTraceController(sim, w.Trace)
+	default:
+		return nil, fmt.Errorf("unsupported workload type %s", w.Type)
+	}
+}
+
+func buildPodTemplate(w *Workload) (*v1.Pod, error) {
+	mem := resource.MustParse(w.Pod.Memory)
+	pod, err := trace.BuildPod("", w.Pod.Cpu, mem.Value(), w.Pod.Algorithm, w.Name, w.Pod.InitialState, w.Pod.SchedulerName)
+	if err != nil {
+		return nil, err
+	}
+	// 没有配置初始化状态时，传递空字符串以使用算法的默认状态
+	if w.Pod.InitialState == nil {
+		pod.Annotations[core.PodAnnotationInitialState] = ""
+	}
+	pod.Spec.PriorityClassName = w.Pod.PriorityClassName
+	return pod, nil
+}
+
+// copyPod 复制模板，并使用新的名称与UID。UID由模拟器的随机数生成器生成，以便确定性模式下Pod名称相同
+func copyPod(sim core.SchedulerSimulator, template *v1.Pod, prefix string) *v1.Pod {
+	uid := core.NewUID(sim.GetRand())
+	pod := template.DeepCopy()
+	pod.Name = fmt.Sprintf("%s-%s", prefix, uid)
+	pod.UID = uid
+	return pod
+}
+
+// newServiceController ServiceController在构造时即注册其ReplicationController，因此推迟到控制器第一次运行时才构造，
+// 以便在DeployTick时才开始部署Pod。
+func newServiceController(sim core.SchedulerSimulator, w *Workload) (core.Controller, error) {
+	template, err := buildPodTemplate(w)
+	if err != nil {
+		return nil, err
+	}
+
+	requestId := 0
+	factory := func() []*pods.ServiceContext {
+		res := make([]*pods.ServiceContext, w.Service.RequestsPerTick)
+		for i := 0; i < len(res); i++ {
+			requestId++
+			res[i] = &pods.ServiceContext{
+				RequestId:    requestId,
+				SlotRequired: w.Service.SlotRequired,
+				MemRequired:  w.Service.MemRequired,
+			}
+		}
+		return res
+	}
+
+	var service controllers.ServiceController
+	return &core.ControllerFunc{
+		NameString: w.Name,
+		TickFunc: func() {
+			if service == nil {
+				service = controllers.NewServiceController(sim, w.Name, w.Replicas, factory, template)
+			}
+			service.Tick()
+		},
+	}, nil
+}
+
+// newBatchController 构造在第一次运行时提交所有Pod的控制器
+func newBatchController(sim core.SchedulerSimulator, w *Workload) (core.Controller, error) {
+	template, err := buildPodTemplate(w)
+	if err != nil {
+		return nil, err
+	}
+
+	submitted := false
+	return &core.ControllerFunc{
+		NameString: w.Name,
+		TickFunc: func() {
+			if submitted {
+				return
+			}
+			submitted = true
+			for i := 0; i < w.Count; i++ {
+				pod := copyPod(sim, template, w.Name)
+				logrus.Debugf("Batch %s: Submitting pod %s", w.Name, pod.Name)
+				_, err := sim.GetKubernetesClient().CoreV1().Pods(core.DefaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+				if err != nil {
+					logrus.Errorf("Batch %s: error creating pod %s: %v", w.Name, pod.Name, err)
+				}
+			}
+		},
+		NextWakeUpFunc: func(tick int) int {
+			if submitted {
+				return -1
+			}
+			return tick + 1
+		},
+		SaveStateFunc: func() ([]byte, error) {
+			return json.Marshal(submitted)
+		},
+		RestoreStateFunc: func(state []byte) error {
+			return json.Unmarshal(state, &submitted)
+		},
+	}, nil
+}
+
+// traceTickSeconds 一个Tick对应数据集中的秒数。没有指定时与模拟器的Tick长度相同，但至少为1秒
+func traceTickSeconds(sim core.SchedulerSimulator, spec *TraceSpec) int64 {
+	if spec.TickSeconds > 0 {
+		return spec.TickSeconds
+	}
+	if seconds := int64(sim.GetClock().TickDuration() / time.Second); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
+
+func newTraceController(sim core.SchedulerSimulator, spec *TraceSpec) (core.Controller, error) {
+	switch spec.Format {
+	case TraceAlibaba:
+		opts := alibaba.DefaultOptions(alibaba.Version(spec.Version))
+		opts.TickSeconds = traceTickSeconds(sim, spec)
+		opts.StartTime, opts.EndTime, opts.MaxMachines = spec.StartTime, spec.EndTime, spec.MaxMachines
+		if spec.PodsPerNode > 0 {
+			opts.PodsPerNode = spec.PodsPerNode
+		}
+		if spec.CoreScheduler != "" {
+			opts.CoreScheduler = spec.CoreScheduler
+		}
+		if spec.SchedulerName != "" {
+			opts.SchedulerName = spec.SchedulerName
+		}
+		return alibaba.NewController(sim, spec.Dir, opts)
+	case TraceGoogle:
+		opts := google.DefaultOptions(google.Version(spec.Version))
+		opts.TickSeconds = traceTickSeconds(sim, spec)
+		if spec.StartTime > 0 {
+			opts.StartTime = spec.StartTime
+		}
+		opts.EndTime, opts.MaxMachines = spec.EndTime, spec.MaxMachines
+		if spec.PodsPerNode > 0 {
+			opts.PodsPerNode = spec.PodsPerNode
+		}
+		if spec.CoreScheduler != "" {
+			opts.CoreScheduler = spec.CoreScheduler
+		}
+		if spec.SchedulerName != "" {
+			opts.SchedulerName = spec.SchedulerName
+		}
+		return google.NewController(sim, spec.Dir, opts)
+	default:
+		return nil, fmt.Errorf("unsupported trace format %s", spec.Format)
+	}
+}
+
+// SetSchedulerName 使场景中所有工作负载的Pod均使用名为name的调度器Profile，用于比较不同的调度器
+func (s *Scenario) SetSchedulerName(name string) {
+	for _, w := range s.Workloads {
+		if w.Pod != nil {
+			w.Pod.SchedulerName = name
+		}
+		if w.Trace != nil {
+			w.Trace.SchedulerName = name
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package scenario
+
+import (
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"testing"
+)
+
+const testScenario = `
+name: test
+totalTick: 100
+nodePools:
+- name: node
+  count: 2
+  cpu: "8"
+  memory: 16Gi
+  pods: "110"
+priorityClasses:
+- name: high
+  value: 1000
+workloads:
+- name: web
+  type: service
+  replicas: 2
+  pod: {cpu: 1, memory: 1Gi}
+  service: {requestsPerTick: 10, slotRequired: 0.05, memRequired: 1024}
+- name: job
+  type: batch
+  deployTick: 10
+  deployTime: after
+  count: 5
+  pod:
+    cpu: 2
+    memory: 2Gi
+    priorityClassName: high
+    initialState: {memUsage: 1073741824, totalTick: 200}
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TotalTick != 100 || len(s.NodePools) != 1 || len(s.Workloads) != 2 {
+		t.Fatalf("wrong scenario %v", s)
+	}
+	if s.NodePools[0].CoreScheduler != core.FairScheduler {
+		t.Errorf("default CoreScheduler should be %s", core.FairScheduler)
+	}
+
+	web := s.Workloads[0]
+	if web.DeployTime != DeployBeforeUpdate || web.Pod.Algorithm != pods.SimServicePod {
+		t.Errorf("wrong defaults of service workload: %s %s", web.DeployTime, web.Pod.Algorithm)
+	}
+
+	job := s.Workloads[1]
+	if job.DeployTick != 10 || job.DeployTime != DeployAfterUpdate || job.Pod.Algorithm != pods.BatchPod {
+		t.Errorf("wrong batch workload %v", job)
+	}
+	pod, err := buildPodTemplate(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.Annotations[core.PodAnnotationInitialState] != `{"memUsage":1073741824,"totalTick":200}` {
+		t.Errorf("wrong initial state %s", pod.Annotations[core.PodAnnotationInitialState])
+	}
+	if pod.Annotations[core.PodAnnotationMemLimit] != "2147483648" || pod.Spec.PriorityClassName != "high" {
+		t.Errorf("wrong pod template %v", pod)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	s, err := Parse([]byte(`{"totalTick": 10, "workloads": [{"name": "t", "type": "trace",
+		"trace": {"format": "google", "version": 2019, "dir": "/data"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Workloads[0].Trace.Version != 2019 {
+		t.Errorf("wrong trace %v", s.Workloads[0].Trace)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []string{
+		`totalTick: 0`,
+		`{totalTick: 10, unknown: 1}`,
+		`{totalTick: 10, nodePools: [{name: n, count: 1, cpu: x, memory: 1Gi, pods: "10"}]}`,
+		`{totalTick: 10, workloads: [{name: w, type: unknown}]}`,
+		`{totalTick: 10, workloads: [{name: w, type: batch, count: 1, pod: {cpu: 1, memory: 1Gi, algorithm: none}}]}`,
+		`{totalTick: 10, workloads: [{name: w, type: replication, pod: {cpu: 1, memory: 1Gi}}]}`,
+		`{totalTick: 10, workloads: [{name: w, type: trace, trace: {format: azure, dir: /data}}]}`,
+		`{totalTick: 10, schedulingBudget: -1}`,
+		`{totalTick: 10, tickDuration: 1x}`,
+		`{totalTick: 10, schedulingLatency: {model: random, value: 1}}`,
+		`{totalTick: 10, seed: 1, schedulingLatency: {model: measured, value: 1}}`,
+		`{totalTick: 10, stopWhen: {idleTicks: -1}}`,
+	}
+	for _, c := range cases {
+		if _, err := Parse([]byte(c)); err == nil {
+			t.Errorf("should fail parsing %s", c)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// scenario 使用YAML或JSON格式的场景文件描述一次模拟实验，包括模拟的总Tick数、节点池、PriorityClass与各种工作负载，
+// 并根据场景文件构造与运行模拟集群，从而无需为每个实验编写Go代码。
+//
+// 一个简单的场景文件如下：
+//
+//	name: example
+//	totalTick: 1000
+//	nodePools:
+//	- name: node
+//	  count: 10
+//	  cpu: "32"
+//	  memory: 64Gi
+//	  pods: "110"
+//	workloads:
+//	- name: web
+//	  type: service
+//	  replicas: 10
+//	  pod: {cpu: 1, memory: 1Gi}
+//	  service: {requestsPerTick: 1000, slotRequired: 0.05, memRequired: 1024}
+//	- name: job
+//	  type: batch
+//	  deployTick: 100
+//	  count: 50
+//	  pod: {cpu: 2, memory: 2Gi, initialState: {memUsage: 1073741824, totalTick: 200}}
+package scenario
+
+type WorkloadType string
+
+const (
+	// WorkloadService 使用ServiceController模拟在线服务
+	WorkloadService = WorkloadType("service")
+	// WorkloadReplication 使用ReplicationController维持一定数量的Pod
+	WorkloadReplication = WorkloadType("replication")
+	// WorkloadBatch 在部署时一次性提交一定数量的Pod，默认使用BatchPod
+	WorkloadBatch = WorkloadType("batch")
+	// WorkloadTrace 回放集群数据集
+	WorkloadTrace = WorkloadType("trace")
+)
+
+// DeployTime 工作负载的控制器在节点更新之前或之后运行
+type DeployTime string
+
+const (
+	DeployBeforeUpdate = DeployTime("before")
+	DeployAfterUpdate  = DeployTime("after")
+)
+
+type TraceFormat string
+
+const (
+	TraceAlibaba = TraceFormat("alibaba")
+	TraceGoogle  = TraceFormat("google")
+)
+
+// Scenario 一次模拟实验的描述
+type Scenario struct {
+	Name string `json:"name,omitempty"`
+	// TotalTick 模拟集群的总运行周期
+	TotalTick int `json:"totalTick"`
+	// TickDuration 一个Tick对应的模拟时间，如"1s"、"5m"，为空时为1秒
+	TickDuration string `json:"tickDuration,omitempty"`
+	// Seed 不为0时以确定性模式运行，相同Seed的两次运行输出相同的统计数据
+	Seed int64 `json:"seed,omitempty"`
+	// EventDriven 使用事件驱动模式，跳过没有事件发生的Tick，适用于回放较长的数据集
+	EventDriven bool `json:"eventDriven,omitempty"`
+	// SchedulingBudget 每个Tick调度器最多尝试调度的次数，为0时不限制
+	SchedulingBudget int `json:"schedulingBudget,omitempty"`
+	// SchedulingLatency 调度延迟模型，为空时调度不消耗模拟时间
+	SchedulingLatency *SchedulingLatency `json:"schedulingLatency,omitempty"`
+	// SchedulerConfig KubeSchedulerConfiguration文件的路径，用于配置多个调度器Profile及其插件，为空时使用默认配置
+	SchedulerConfig string `json:"schedulerConfig,omitempty"`
+	// StopWhen 提前结束模拟的条件，任意一个满足时结束
+	StopWhen        *StopWhen        `json:"stopWhen,omitempty"`
+	NodePools       []*NodePool      `json:"nodePools,omitempty"`
+	PriorityClasses []*PriorityClass `json:"priorityClasses,omitempty"`
+	Workloads       []*Workload      `json:"workloads,omitempty"`
+}
+
+// StopWhen 提前结束模拟的条件
+type StopWhen struct {
+	// AllPodsFinished 所有Pod都已经结束时停止
+	AllPodsFinished bool `json:"allPodsFinished,omitempty"`
+	// IdleTicks 不为0时，集群连续该数量的Tick没有等待调度与运行中的Pod时停止
+	IdleTicks int `json:"idleTicks,omitempty"`
+	// MaxWaitTicks 不为0时，有Pod等待调度超过该数量的Tick时停止
+	MaxWaitTicks int `json:"maxWaitTicks,omitempty"`
+}
+
+// SchedulingLatency 调度延迟模型的配置
+type SchedulingLatency struct {
+	// Model 模型名称，constant代表每次调度尝试消耗固定的Tick数，measured代表根据调度器实际运行的时间计算
+	Model string `json:"model"`
+	// Value constant模型每次调度消耗的Tick数，或measured模型中每秒实际运行时间对应的Tick数
+	Value float64 `json:"value"`
+}
+
+// NodePool 一组配置相同的节点，节点名称为“池名称-序号”
+type NodePool struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	// Cpu 、Memory与Pods为Kubernetes的资源数量格式，如“32”、“64Gi”
+	Cpu    string `json:"cpu"`
+	Memory string `json:"memory"`
+	Pods   string `json:"pods"`
+	// CoreScheduler 节点所使用的CoreScheduler，为空时使用FairScheduler
+	CoreScheduler string `json:"coreScheduler,omitempty"`
+}
+
+type PriorityClass struct {
+	Name  string `json:"name"`
+	Value int32  `json:"value"`
+}
+
+// PodTemplate 工作负载所创建的Pod的模板
+type PodTemplate struct {
+	// Cpu Pod的CPU限制核数
+	Cpu float64 `json:"cpu"`
+	// Memory Pod的内存限制，为Kubernetes的资源数量格式
+	Memory string `json:"memory"`
+	// Algorithm Pod的算法，为空时根据工作负载类型选择
+	Algorithm string `json:"algorithm,omitempty"`
+	// InitialState 算法的初始化状态，将转换为JSON传递给算法
+	InitialState      interface{} `json:"initialState,omitempty"`
+	SchedulerName     string      `json:"schedulerName,omitempty"`
+	PriorityClassName string      `json:"priorityClassName,omitempty"`
+}
+
+// ServiceSpec 在线服务在每个Tick收到的请求
+type ServiceSpec struct {
+	RequestsPerTick int     `json:"requestsPerTick"`
+	SlotRequired    float64 `json:"slotRequired"`
+	MemRequired     int64   `json:"memRequired"`
+}
+
+// TraceSpec 回放的数据集。数据集中的机器会作为节点加入集群。
+type TraceSpec struct {
+	Format TraceFormat `json:"format"`
+	// Version 数据集的年份，如2018
+	Version int    `json:"version"`
+	Dir     string `json:"dir"`
+	// TickSeconds 一个Tick对应数据集中的秒数，为0时与场景的tickDuration相同
+	TickSeconds int64 `json:"tickSeconds,omitempty"`
+	// 以下配置为0或空时使用数据集的默认配置
+	StartTime     int64  `json:"startTime,omitempty"`
+	EndTime       int64  `json:"endTime,omitempty"`
+	MaxMachines   int    `json:"maxMachines,omitempty"`
+	PodsPerNode   int    `json:"podsPerNode,omitempty"`
+	CoreScheduler string `json:"coreScheduler,omitempty"`
+	SchedulerName string `json:"schedulerName,omitempty"`
+}
+
+// Workload 一个工作负载，对应一个控制器。控制器在DeployTick时通过ControllerDeployer部署。
+type Workload struct {
+	Name string       `json:"name"`
+	Type WorkloadType `json:"type"`
+	// DeployTick 部署的Tick，为0时在模拟开始前部署
+	DeployTick int `json:"deployTick,omitempty"`
+	// DeployTime 为空时在节点更新之前运行
+	DeployTime DeployTime `json:"deployTime,omitempty"`
+	// Replicas service与replication类型的Pod数量
+	Replicas int `json:"replicas,omitempty"`
+	// Count batch类型提交的Pod数量
+	Count   int          `json:"count,omitempty"`
+	Pod     *PodTemplate `json:"pod,omitempty"`
+	Service *ServiceSpec `json:"service,omitempty"`
+	Trace   *TraceSpec   `json:"trace,omitempty"`
+}
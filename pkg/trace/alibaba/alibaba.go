@@ -0,0 +1,294 @@
+// alibaba 解析阿里巴巴集群数据集（https://github.com/alibaba/clusterdata），支持cluster-trace-v2017与
+// cluster-trace-v2018两个版本，并将其转换为模拟集群的节点与Pod。
+//
+// 2017年版本读取server_event.csv、batch_task.csv、batch_instance.csv、container_event.csv与container_usage.csv，
+// 2018年版本读取machine_meta.csv、batch_task.csv、batch_instance.csv、container_meta.csv与container_usage.csv。
+// 除节点文件外，其余文件均为可选。
+package alibaba
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"math"
+)
+
+type Version int
+
+const (
+	Version2017 = Version(2017)
+	Version2018 = Version(2018)
+)
+
+// ControllerName 回放控制器的名称，也是所创建Pod的DeploymentController
+const ControllerName = "alibaba-trace"
+
+type Options struct {
+	Version Version
+	// TickSeconds 一个Tick对应数据集中的秒数
+	TickSeconds int64
+	// StartTime 回放的起始时间戳，单位为秒。在此之前加入的节点与容器在第0个Tick加入，在此之前开始的批处理实例将被忽略。
+	StartTime int64
+	// EndTime 回放的结束时间戳，单位为秒，不包含。为0时不限制
+	EndTime int64
+	// MemoryScale 归一化内存为1时对应的字节数
+	MemoryScale int64
+	// PodsPerNode 每个节点能够运行的最多Pod数量
+	PodsPerNode int
+	// CoreScheduler 节点所使用的CoreScheduler
+	CoreScheduler string
+	// SchedulerName Pod所使用的调度器Profile
+	SchedulerName string
+	// MaxMachines 最多加入的节点数量，为0时不限制。用于缩小模拟的规模
+	MaxMachines int
+}
+
+// DefaultOptions 返回数据集版本的默认配置
+func DefaultOptions(version Version) *Options {
+	return &Options{
+		Version:       version,
+		TickSeconds:   1,
+		MemoryScale:   512 << 30,
+		PodsPerNode:   256,
+		CoreScheduler: core.FairScheduler,
+		SchedulerName: v1.DefaultSchedulerName,
+	}
+}
+
+// NewController 读取dir目录下的数据集，构造在相应Tick创建节点与Pod的控制器。该控制器应注册为BeforeUpdate控制器。
+func NewController(sim core.SchedulerSimulator, dir string, opts *Options) (core.Controller, error) {
+	events, err := Load(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return trace.NewReplayController(sim, ControllerName, events), nil
+}
+
+// Load 读取dir目录下的数据集，并转换为按Tick排序的集群事件
+func Load(dir string, opts *Options) ([]*trace.Event, error) {
+	if opts == nil {
+		opts = DefaultOptions(Version2018)
+	}
+	if opts.TickSeconds <= 0 {
+		return nil, fmt.Errorf("TickSeconds must larger than 0")
+	}
+
+	l := &loader{opts: opts, events: make([]*trace.Event, 0, 1024)}
+
+	machines, err := readMachines(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading machines")
+	}
+	l.addMachines(machines)
+
+	tasks, err := readTasks(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading batch tasks")
+	}
+	instances, err := readInstances(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading batch instances")
+	}
+	if err = l.addInstances(tasks, instances); err != nil {
+		return nil, err
+	}
+
+	containers, err := readContainers(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading containers")
+	}
+	usage, err := readUsage(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading container usage")
+	}
+	if err = l.addContainers(containers, usage); err != nil {
+		return nil, err
+	}
+
+	trace.SortEvents(l.events)
+	return l.events, nil
+}
+
+type loader struct {
+	opts   *Options
+	events []*trace.Event
+}
+
+// inRange 时间戳是否在回放的时间范围内
+func (l *loader) inRange(timestamp int64) bool {
+	return timestamp >= l.opts.StartTime && (l.opts.EndTime == 0 || timestamp < l.opts.EndTime)
+}
+
+// toTick 将时间戳转换为Tick，早于StartTime的时间戳视为第0个Tick
+func (l *loader) toTick(timestamp int64) int {
+	if timestamp < l.opts.StartTime {
+		return 0
+	}
+	return int((timestamp - l.opts.StartTime) / l.opts.TickSeconds)
+}
+
+func (l *loader) toBytes(normalized float64) int64 {
+	return int64(normalized * float64(l.opts.MemoryScale))
+}
+
+func (l *loader) addMachines(machines []*machineRecord) {
+	added := make(map[string]bool)
+	for _, machine := range machines {
+		if l.opts.EndTime != 0 && machine.timestamp >= l.opts.EndTime {
+			continue
+		}
+		if machine.remove {
+			if !added[machine.id] {
+				continue
+			}
+			l.events = append(l.events, &trace.Event{
+				Tick: l.toTick(machine.timestamp),
+				Type: trace.NodeRemove,
+				Name: machine.id,
+			})
+			delete(added, machine.id)
+			continue
+		}
+		if added[machine.id] || machine.cpu <= 0 {
+			continue
+		}
+		if l.opts.MaxMachines > 0 && len(added) >= l.opts.MaxMachines {
+			continue
+		}
+		added[machine.id] = true
+		node := core.BuildNode(machine.id, fmt.Sprintf("%d", machine.cpu), fmt.Sprintf("%d", l.toBytes(machine.mem)),
+			fmt.Sprintf("%d", l.opts.PodsPerNode), l.opts.CoreScheduler)
+		l.events = append(l.events, &trace.Event{
+			Tick: l.toTick(machine.timestamp),
+			Type: trace.NodeAdd,
+			Node: node,
+		})
+	}
+}
+
+func (l *loader) addInstances(tasks map[string]*taskRecord, instances []*instanceRecord) error {
+	for _, instance := range instances {
+		if !l.inRange(instance.start) || instance.end <= instance.start {
+			continue
+		}
+		cpu, mem := instance.cpuAvg, instance.memAvg
+		if task, ok := tasks[taskKey(instance.job, instance.task)]; ok {
+			cpu, mem = task.cpu, task.mem
+		}
+		if cpu <= 0 {
+			cpu = 1
+		}
+		memBytes := l.toBytes(mem)
+		// 在资源充足的情况下，运行时间与数据集中的时间一致
+		duration := float64(l.toTick(instance.end) - l.toTick(instance.start))
+		name := fmt.Sprintf("batch-%s-%s-%s", instance.job, instance.task, instance.name)
+		pod, err := trace.BuildPod(name, cpu, memBytes, pods.BatchPod, ControllerName, &pods.BatchPodState{
+			MemUsage:  l.toBytes(instance.memAvg),
+			TotalTick: duration * cpu,
+		}, l.opts.SchedulerName)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error building pod %s", name))
+		}
+		l.events = append(l.events, &trace.Event{
+			Tick: l.toTick(instance.start),
+			Type: trace.PodSubmit,
+			Pod:  pod,
+		})
+	}
+	return nil
+}
+
+func (l *loader) addContainers(containers []*containerRecord, usage map[string][]*usageRecord) error {
+	// 记录容器的删除时间
+	removeTime := make(map[string]int64)
+	for _, container := range containers {
+		if container.remove {
+			if _, ok := removeTime[container.id]; !ok {
+				removeTime[container.id] = container.timestamp
+			}
+		}
+	}
+
+	created := make(map[string]bool)
+	for _, container := range containers {
+		if container.remove || created[container.id] {
+			continue
+		}
+		if l.opts.EndTime != 0 && container.timestamp >= l.opts.EndTime {
+			continue
+		}
+		remove, removed := removeTime[container.id]
+		if removed && remove < l.opts.StartTime {
+			continue
+		}
+		created[container.id] = true
+
+		cpu := container.cpu
+		if cpu <= 0 {
+			cpu = 1
+		}
+		memBytes := l.toBytes(container.mem)
+		name := fmt.Sprintf("container-%s", container.id)
+		pod, err := l.buildContainerPod(name, container, cpu, memBytes, usage[container.id], remove, removed)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error building pod %s", name))
+		}
+		l.events = append(l.events, &trace.Event{
+			Tick: l.toTick(container.timestamp),
+			Type: trace.PodSubmit,
+			Pod:  pod,
+		})
+		if removed && (l.opts.EndTime == 0 || remove < l.opts.EndTime) {
+			l.events = append(l.events, &trace.Event{
+				Tick: l.toTick(remove),
+				Type: trace.PodFinish,
+				Name: name,
+			})
+		}
+	}
+	return nil
+}
+
+// buildContainerPod 构造容器对应的Pod。有使用数据的容器使用TraceReplayPod回放数据集中的资源使用，否则使用BatchPod，
+// 并视为完全使用所请求的资源。
+func (l *loader) buildContainerPod(name string, container *containerRecord, cpu float64, memBytes int64,
+	records []*usageRecord, remove int64, removed bool) (*v1.Pod, error) {
+	// 早于StartTime创建的容器在第0个Tick加入，使用数据的时刻从此时开始计算
+	start := container.timestamp
+	if start < l.opts.StartTime {
+		start = l.opts.StartTime
+	}
+
+	if len(records) > 0 {
+		samples := make([]*pods.UsageSample, len(records))
+		for i, record := range records {
+			samples[i] = &pods.UsageSample{
+				Tick: float64(record.timestamp-start) / float64(l.opts.TickSeconds),
+				Cpu:  cpu * record.cpuUtil / 100,
+				Mem:  int64(float64(memBytes) * record.memUtil / 100),
+			}
+		}
+		// 没有删除时间的容器回放到最后一个采样点为止
+		duration := float64(0)
+		if removed {
+			duration = float64(remove-start) / float64(l.opts.TickSeconds)
+		}
+		return trace.BuildPod(name, cpu, memBytes, pods.TraceReplayPod, ControllerName, &pods.TraceReplayPodState{
+			Samples:  samples,
+			Duration: duration,
+		}, l.opts.SchedulerName)
+	}
+
+	// 没有删除时间的容器一直运行，否则在资源充足的情况下运行到删除时间
+	totalTick := float64(math.MaxInt32)
+	if removed {
+		totalTick = float64(l.toTick(remove)-l.toTick(container.timestamp)) * cpu
+	}
+	return trace.BuildPod(name, cpu, memBytes, pods.BatchPod, ControllerName, &pods.BatchPodState{
+		MemUsage:  memBytes,
+		TotalTick: totalTick,
+	}, l.opts.SchedulerName)
+}
@@ -0,0 +1,128 @@
+package alibaba
+
+import (
+	"encoding/json"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "alibaba")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad2018(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		fileMachineMeta: "m_1,0,1,1,96,100,USING\n" +
+			"m_1,100,1,1,96,100,USING\n" +
+			"m_2,50,1,1,64,50,USING\n",
+		fileBatchTask: "M1,2,j_1,1,Terminated,100,200,100,1.5\n",
+		fileBatchInstance: "i_1,M1,j_1,1,Terminated,110,130,m_1,1,1,50,100,1.0,1.5\n" +
+			"i_2,M1,j_1,1,Failed,110,130,m_1,1,1,50,100,1.0,1.5\n",
+		fileContainerMeta: "c_1,m_1,0,app_1,started,400,400,3.13\n" +
+			"c_1,m_1,20,app_1,started,400,400,3.13\n",
+		fileContainerUsage: "c_1,m_1,10,50,80,,,,,,\n" +
+			"c_1,m_1,20,30,60,,,,,,\n",
+	})
+	defer os.RemoveAll(dir)
+
+	opts := DefaultOptions(Version2018)
+	opts.TickSeconds = 10
+	events, err := Load(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		tick int
+		typ  trace.EventType
+		name string
+	}{
+		{0, trace.NodeAdd, "m_1"},
+		{0, trace.PodSubmit, "container-c_1"},
+		{5, trace.NodeAdd, "m_2"},
+		{11, trace.PodSubmit, "batch-j_1-M1-i_1"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("should have %d events, not %d", len(expected), len(events))
+	}
+	for i, ev := range events {
+		name := ev.Name
+		if ev.Node != nil {
+			name = ev.Node.Name
+		} else if ev.Pod != nil {
+			name = ev.Pod.Name
+		}
+		if ev.Tick != expected[i].tick || ev.Type != expected[i].typ || name != expected[i].name {
+			t.Errorf("event %d should be %v, not %d %s %s", i, expected[i], ev.Tick, ev.Type, name)
+		}
+	}
+
+	node := events[2].Node
+	if cpu, _ := node.Status.Capacity.Cpu().AsInt64(); cpu != 64 {
+		t.Errorf("cpu should be 64 not %d", cpu)
+	}
+	if mem, _ := node.Status.Capacity.Memory().AsInt64(); mem != opts.MemoryScale/2 {
+		t.Errorf("memory should be %d not %d", opts.MemoryScale/2, mem)
+	}
+
+	container := events[1].Pod
+	if container.Annotations[core.PodAnnotationAlgorithm] != pods.TraceReplayPod {
+		t.Errorf("container should use %s, not %s", pods.TraceReplayPod, container.Annotations[core.PodAnnotationAlgorithm])
+	}
+	state := &pods.TraceReplayPodState{}
+	if err = json.Unmarshal([]byte(container.Annotations[core.PodAnnotationInitialState]), state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Samples) != 2 || state.Samples[0].Tick != 1 || state.Samples[0].Cpu != 2 {
+		t.Errorf("wrong usage samples %v", state.Samples)
+	}
+
+	pod := events[3].Pod
+	if pod.Annotations[core.PodAnnotationCpuLimit] != "1.000" {
+		t.Errorf("cpu limit should be 1.000, not %s", pod.Annotations[core.PodAnnotationCpuLimit])
+	}
+	if pod.Spec.Containers[0].Resources.Requests.Cpu().MilliValue() != 1000 {
+		t.Errorf("cpu request should be 1000m")
+	}
+}
+
+func TestLoad2017(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		fileServerEvent: "0,1,add,,64,0.69,1\n" +
+			"30,1,softerror,,64,0.69,1\n" +
+			"60,1,remove,,64,0.69,1\n",
+		fileContainerEvent: "5,Create,1001,1,4,0.1,0.1,\n" +
+			"40,Remove,1001,1,4,0.1,0.1,\n",
+	})
+	defer os.RemoveAll(dir)
+
+	events, err := Load(dir, DefaultOptions(Version2017))
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []trace.EventType{trace.NodeAdd, trace.PodSubmit, trace.PodFinish, trace.NodeRemove}
+	ticks := []int{0, 5, 40, 60}
+	if len(events) != len(types) {
+		t.Fatalf("should have %d events, not %d", len(types), len(events))
+	}
+	for i, ev := range events {
+		if ev.Type != types[i] || ev.Tick != ticks[i] {
+			t.Errorf("event %d should be %s at tick %d, not %s at tick %d", i, types[i], ticks[i], ev.Type, ev.Tick)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package alibaba
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 各版本数据集的文件名
+const (
+	fileServerEvent    = "server_event.csv"
+	fileMachineMeta    = "machine_meta.csv"
+	fileBatchTask      = "batch_task.csv"
+	fileBatchInstance  = "batch_instance.csv"
+	fileContainerEvent = "container_event.csv"
+	fileContainerMeta  = "container_meta.csv"
+	fileContainerUsage = "container_usage.csv"
+)
+
+type machineRecord struct {
+	timestamp int64
+	id        string
+	remove    bool
+	cpu       int64
+	// mem 归一化的内存大小，取值0～1
+	mem float64
+}
+
+type taskRecord struct {
+	// cpu 每个实例请求的CPU核数
+	cpu float64
+	// mem 每个实例请求的归一化内存大小，取值0～1
+	mem float64
+}
+
+type instanceRecord struct {
+	name  string
+	job   string
+	task  string
+	start int64
+	end   int64
+	// cpuAvg 实际使用的平均CPU核数
+	cpuAvg float64
+	// memAvg 实际使用的平均归一化内存大小，取值0～1
+	memAvg float64
+}
+
+type containerRecord struct {
+	timestamp int64
+	id        string
+	remove    bool
+	cpu       float64
+	mem       float64
+}
+
+// usageRecord 容器在某一时刻的资源使用情况
+type usageRecord struct {
+	timestamp int64
+	// cpuUtil 占所请求CPU的百分比
+	cpuUtil float64
+	// memUtil 占所请求内存的百分比
+	memUtil float64
+}
+
+// readCSV 逐行读取文件，文件不存在时返回os.ErrNotExist
+func readCSV(path string, handle func(record []string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error reading %s line %d", path, line))
+		}
+		if err = handle(record); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error parsing %s line %d", path, line))
+		}
+	}
+}
+
+// readOptionalCSV 与readCSV相同，但是文件不存在时不返回错误
+func readOptionalCSV(path string, handle func(record []string) error) error {
+	err := readCSV(path, handle)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// field 获取第idx列，不存在时返回空字符串
+func field(record []string, idx int) string {
+	if idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+// 数据集中存在大量缺失值，缺失值均视为0
+func parseFloat(record []string, idx int) (float64, error) {
+	s := field(record, idx)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseInt(record []string, idx int) (int64, error) {
+	s := field(record, idx)
+	if s == "" {
+		return 0, nil
+	}
+	// 部分时间戳以浮点数的形式给出
+	f, err := strconv.ParseFloat(s, 64)
+	return int64(f), err
+}
+
+func parseFloats(record []string, idx ...int) ([]float64, error) {
+	res := make([]float64, len(idx))
+	for i, id := range idx {
+		f, err := parseFloat(record, id)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid column %d", id))
+		}
+		res[i] = f
+	}
+	return res, nil
+}
+
+func readMachines(dir string, version Version) ([]*machineRecord, error) {
+	machines := make([]*machineRecord, 0, 1024)
+	switch version {
+	case Version2017:
+		// timestamp,machine_id,event_type,event_detail,number_of_cpus,normalized_memory,normalized_disk
+		err := readCSV(filepath.Join(dir, fileServerEvent), func(record []string) error {
+			eventType := field(record, 2)
+			if eventType == "softerror" {
+				return nil
+			}
+			ts, err := parseInt(record, 0)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 4, 5)
+			if err != nil {
+				return err
+			}
+			machines = append(machines, &machineRecord{
+				timestamp: ts,
+				id:        field(record, 1),
+				remove:    eventType == "remove" || eventType == "harderror",
+				cpu:       int64(values[0]),
+				mem:       values[1],
+			})
+			return nil
+		})
+		return machines, err
+	case Version2018:
+		// machine_id,time_stamp,failure_domain_1,failure_domain_2,cpu_num,mem_size,status
+		// 同一机器会有多条记录，仅第一次出现时视为加入集群
+		seen := make(map[string]bool)
+		err := readCSV(filepath.Join(dir, fileMachineMeta), func(record []string) error {
+			id := field(record, 0)
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+			ts, err := parseInt(record, 1)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 4, 5)
+			if err != nil {
+				return err
+			}
+			machines = append(machines, &machineRecord{
+				timestamp: ts,
+				id:        id,
+				cpu:       int64(values[0]),
+				mem:       values[1] / 100,
+			})
+			return nil
+		})
+		return machines, err
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+}
+
+// readTasks 读取批处理任务，键为job与task名称的组合
+func readTasks(dir string, version Version) (map[string]*taskRecord, error) {
+	tasks := make(map[string]*taskRecord)
+	var err error
+	switch version {
+	case Version2017:
+		// task_create_time,task_end_time,job_id,task_id,number_of_instances,status,number_of_cpus_requested,normalized_memory_requested
+		err = readOptionalCSV(filepath.Join(dir, fileBatchTask), func(record []string) error {
+			values, err := parseFloats(record, 6, 7)
+			if err != nil {
+				return err
+			}
+			tasks[taskKey(field(record, 2), field(record, 3))] = &taskRecord{cpu: values[0], mem: values[1]}
+			return nil
+		})
+	case Version2018:
+		// task_name,instance_num,job_name,task_type,status,start_time,end_time,plan_cpu,plan_mem
+		err = readOptionalCSV(filepath.Join(dir, fileBatchTask), func(record []string) error {
+			values, err := parseFloats(record, 7, 8)
+			if err != nil {
+				return err
+			}
+			tasks[taskKey(field(record, 2), field(record, 0))] = &taskRecord{cpu: values[0] / 100, mem: values[1] / 100}
+			return nil
+		})
+	default:
+		err = fmt.Errorf("unsupported version %d", version)
+	}
+	return tasks, err
+}
+
+func readInstances(dir string, version Version) ([]*instanceRecord, error) {
+	instances := make([]*instanceRecord, 0, 1024)
+	var err error
+	switch version {
+	case Version2017:
+		// start_timestamp,end_timestamp,job_id,task_id,machine_id,status,sequence_number,total_sequence_number,
+		// max_real_cpu_num,average_real_cpu_num,max_normalized_memory_usage,average_normalized_memory_usage
+		// 2017年的实例没有名称，使用所属任务内的序号命名
+		count := make(map[string]int)
+		err = readOptionalCSV(filepath.Join(dir, fileBatchInstance), func(record []string) error {
+			start, err := parseInt(record, 0)
+			if err != nil {
+				return err
+			}
+			end, err := parseInt(record, 1)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 9, 11)
+			if err != nil {
+				return err
+			}
+			job, task := field(record, 2), field(record, 3)
+			key := taskKey(job, task)
+			count[key]++
+			instances = append(instances, &instanceRecord{
+				name:   fmt.Sprintf("%d", count[key]),
+				job:    job,
+				task:   task,
+				start:  start,
+				end:    end,
+				cpuAvg: values[0],
+				memAvg: values[1],
+			})
+			return nil
+		})
+	case Version2018:
+		// instance_name,task_name,job_name,task_type,status,start_time,end_time,machine_id,seq_no,total_seq_no,
+		// cpu_avg,cpu_max,mem_avg,mem_max
+		err = readOptionalCSV(filepath.Join(dir, fileBatchInstance), func(record []string) error {
+			if field(record, 4) != "Terminated" {
+				return nil
+			}
+			start, err := parseInt(record, 5)
+			if err != nil {
+				return err
+			}
+			end, err := parseInt(record, 6)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 10, 12)
+			if err != nil {
+				return err
+			}
+			instances = append(instances, &instanceRecord{
+				name:   field(record, 0),
+				job:    field(record, 2),
+				task:   field(record, 1),
+				start:  start,
+				end:    end,
+				cpuAvg: values[0] / 100,
+				memAvg: values[1] / 100,
+			})
+			return nil
+		})
+	default:
+		err = fmt.Errorf("unsupported version %d", version)
+	}
+	return instances, err
+}
+
+func readContainers(dir string, version Version) ([]*containerRecord, error) {
+	containers := make([]*containerRecord, 0, 1024)
+	var err error
+	switch version {
+	case Version2017:
+		// ts,event,instance_id,machine_id,plan_cpu,plan_mem,plan_disk,cpuset
+		err = readOptionalCSV(filepath.Join(dir, fileContainerEvent), func(record []string) error {
+			ts, err := parseInt(record, 0)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 4, 5)
+			if err != nil {
+				return err
+			}
+			containers = append(containers, &containerRecord{
+				timestamp: ts,
+				id:        field(record, 2),
+				remove:    field(record, 1) == "Remove",
+				cpu:       values[0],
+				mem:       values[1],
+			})
+			return nil
+		})
+	case Version2018:
+		// container_id,machine_id,time_stamp,app_du,status,cpu_request,cpu_limit,mem_size
+		// 同一容器会有多条状态记录，仅第一次出现时视为创建
+		seen := make(map[string]bool)
+		err = readOptionalCSV(filepath.Join(dir, fileContainerMeta), func(record []string) error {
+			id := field(record, 0)
+			if seen[id] {
+				return nil
+			}
+			seen[id] = true
+			ts, err := parseInt(record, 2)
+			if err != nil {
+				return err
+			}
+			values, err := parseFloats(record, 5, 7)
+			if err != nil {
+				return err
+			}
+			containers = append(containers, &containerRecord{
+				timestamp: ts,
+				id:        id,
+				cpu:       values[0] / 100,
+				mem:       values[1] / 100,
+			})
+			return nil
+		})
+	default:
+		err = fmt.Errorf("unsupported version %d", version)
+	}
+	return containers, err
+}
+
+// readUsage 读取容器的资源使用数据，键为容器ID
+func readUsage(dir string, version Version) (map[string][]*usageRecord, error) {
+	var idCol, tsCol, cpuCol, memCol int
+	switch version {
+	case Version2017:
+		// ts,instance_id,cpu_util,mem_util,disk_util,load1,load5,load15,avg_cpi,avg_mpki,max_cpi,max_mpki
+		idCol, tsCol, cpuCol, memCol = 1, 0, 2, 3
+	case Version2018:
+		// container_id,machine_id,time_stamp,cpu_util_percent,mem_util_percent,cpi,mem_gps,mpki,net_in,net_out,disk_io_percent
+		idCol, tsCol, cpuCol, memCol = 0, 2, 3, 4
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	usage := make(map[string][]*usageRecord)
+	err := readOptionalCSV(filepath.Join(dir, fileContainerUsage), func(record []string) error {
+		ts, err := parseInt(record, tsCol)
+		if err != nil {
+			return err
+		}
+		values, err := parseFloats(record, cpuCol, memCol)
+		if err != nil {
+			return err
+		}
+		id := field(record, idCol)
+		usage[id] = append(usage[id], &usageRecord{
+			timestamp: ts,
+			cpuUtil:   values[0],
+			memUtil:   values[1],
+		})
+		return nil
+	})
+	return usage, err
+}
+
+func taskKey(job, task string) string {
+	return job + "/" + task
+}
@@ -0,0 +1,112 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewReplayController 构造回放数据集事件的控制器。控制器第一次调用Tick时视为第0个Tick，在每个Tick中将该Tick及之前
+// 尚未提交的事件通过kubernetes.Interface提交到集群。控制器应该注册为BeforeUpdate控制器，以便新的Pod能在节点更新之前
+// 得到调度。当前的Tick由模拟时钟得到，事件驱动模式下模拟器在下一个事件的Tick唤醒控制器。
+func NewReplayController(sim core.SchedulerSimulator, name string, events []*Event) core.WakeUpController {
+	SortEvents(events)
+	return &replayController{
+		name:   name,
+		sim:    sim,
+		events: events,
+	}
+}
+
+var _ core.StatefulController = &replayController{}
+
+type replayController struct {
+	name   string
+	sim    core.SchedulerSimulator
+	events []*Event
+	// next 下一个待提交的事件下标
+	next int
+	// start 第一次调用Tick时模拟时钟的Tick
+	start   int
+	started bool
+}
+
+func (c *replayController) Name() string {
+	return c.name
+}
+
+func (c *replayController) Tick() {
+	now := c.sim.GetClock().CurrentTick()
+	if !c.started {
+		c.started = true
+		c.start = now
+	}
+	tick := now - c.start
+	client := c.sim.GetKubernetesClient()
+	for ; c.next < len(c.events) && c.events[c.next].Tick <= tick; c.next++ {
+		ev := c.events[c.next]
+		var err error
+		switch ev.Type {
+		case NodeAdd:
+			logrus.Debugf("Trace %s: Adding node %s", c.name, ev.Node.Name)
+			_, err = client.CoreV1().Nodes().Create(context.TODO(), ev.Node, metav1.CreateOptions{})
+		case NodeRemove:
+			logrus.Debugf("Trace %s: Removing node %s", c.name, ev.Name)
+			err = client.CoreV1().Nodes().Delete(context.TODO(), ev.Name, metav1.DeleteOptions{})
+		case PodSubmit:
+			logrus.Debugf("Trace %s: Submitting pod %s", c.name, ev.Pod.Name)
+			_, err = client.CoreV1().Pods(core.DefaultNamespace).Create(context.TODO(), ev.Pod, metav1.CreateOptions{})
+		case PriorityClassAdd:
+			logrus.Debugf("Trace %s: Adding PriorityClass %s", c.name, ev.PriorityClass.Name)
+			_, err = client.SchedulingV1().PriorityClasses().Create(context.TODO(), ev.PriorityClass, metav1.CreateOptions{})
+		case PodFinish:
+			// Pod可能已经自行结束并被删除，此时忽略
+			if _, getErr := client.CoreV1().Pods(core.DefaultNamespace).Get(context.TODO(), ev.Name, metav1.GetOptions{}); getErr != nil {
+				continue
+			}
+			logrus.Debugf("Trace %s: Finishing pod %s", c.name, ev.Name)
+			err = client.CoreV1().Pods(core.DefaultNamespace).Delete(context.TODO(), ev.Name, metav1.DeleteOptions{})
+		}
+		if err != nil {
+			logrus.Errorf("Trace %s: error handling %s event at tick %d: %v", c.name, ev.Type, ev.Tick, err)
+		}
+	}
+}
+
+type replayState struct {
+	Next    int  `json:"next"`
+	Start   int  `json:"start"`
+	Started bool `json:"started"`
+}
+
+func (c *replayController) SaveState() ([]byte, error) {
+	return json.Marshal(&replayState{Next: c.next, Start: c.start, Started: c.started})
+}
+
+// RestoreState 恢复回放的进度，已经提交的事件不会再次提交
+func (c *replayController) RestoreState(state []byte) error {
+	saved := &replayState{}
+	if err := json.Unmarshal(state, saved); err != nil {
+		return err
+	}
+	if saved.Next > len(c.events) {
+		return fmt.Errorf("trace %s has only %d events, can not restore to event %d", c.name, len(c.events), saved.Next)
+	}
+	c.next, c.start, c.started = saved.Next, saved.Start, saved.Started
+	return nil
+}
+
+// NextWakeUp 第一次调用Tick之前回放还没有开始，start尚未确定，因此需要在下一个Tick唤醒。由ControllerDeployer延迟部署时，
+// 控制器在部署之后的第一个Tick开始回放
+func (c *replayController) NextWakeUp(tick int) int {
+	if c.next >= len(c.events) {
+		return -1
+	}
+	if !c.started {
+		return tick + 1
+	}
+	return c.start + c.events[c.next].Tick
+}
@@ -0,0 +1,47 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/controllers"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestReplayDeployedInEventDrivenMode(t *testing.T) {
+	sim := core.NewSchedulerSimulator(100)
+	sim.SetEventDriven(true)
+	sim.SetMetricsSinks()
+	sim.SetClusterMetricsSinks()
+	node := core.BuildNode("node-1", "8", "16G", "100", core.FairScheduler)
+	if _, err := sim.GetKubernetesClient().CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make([]*Event, 0)
+	for i, tick := range []int{0, 5} {
+		pod, err := BuildPod(fmt.Sprintf("pod-%d", i), 1, 1<<20, pods.BatchPod, "trace", &pods.BatchPodState{MemUsage: 1 << 20, TotalTick: 3}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, &Event{Tick: tick, Type: PodSubmit, Pod: pod})
+	}
+	deployer := controllers.NewControllerDeployer(sim)
+	sim.RegisterBeforeUpdateController(deployer)
+	deployer.DeployAt(NewReplayController(sim, "trace", events), 10, controllers.BeforeUpdate)
+	sim.Run()
+
+	// 控制器在第9个Tick部署，从第10个Tick开始回放
+	records := make(map[string]int)
+	for _, record := range sim.GetPodRecords() {
+		records[record.Name] = record.CreationTick
+	}
+	if tick, ok := records["pod-0"]; !ok || tick != 10 {
+		t.Errorf("pod-0 should be submitted at tick 10, got %d %v", tick, ok)
+	}
+	if tick, ok := records["pod-1"]; !ok || tick != 15 {
+		t.Errorf("pod-1 should be submitted at tick 15, got %d %v", tick, ok)
+	}
+}
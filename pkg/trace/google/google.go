@@ -0,0 +1,346 @@
+// google 解析谷歌集群数据集（https://github.com/google/cluster-data），支持ClusterData2011与ClusterData2019两个版本，
+// 并将机器的加入与删除、任务的提交与结束转换为模拟集群的事件。
+//
+// 2011年版本读取machine_events、task_events与task_usage三个表的CSV文件，2019年版本读取machine_events、
+// instance_events与instance_usage三个表从BigQuery导出的JSON Lines文件。每个表可以是同名目录下的多个分片文件，也可以是
+// 单个同名文件，文件可以使用gzip压缩。除machine_events外，其余表均为可选。
+//
+// 数据集中任务的优先级会转换为同名的PriorityClass，并在第0个Tick创建，使得调度器可以根据优先级进行抢占。
+package google
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
+	"sort"
+)
+
+type Version int
+
+const (
+	Version2011 = Version(2011)
+	Version2019 = Version(2019)
+)
+
+// ControllerName 回放控制器的名称，也是所创建Pod的DeploymentController
+const ControllerName = "google-trace"
+
+// PriorityClassPrefix 数据集优先级所对应的PriorityClass名称前缀
+const PriorityClassPrefix = "google-priority-"
+
+type Options struct {
+	Version Version
+	// TickSeconds 一个Tick对应数据集中的秒数
+	TickSeconds int64
+	// StartTime 回放的起始时间，单位为秒。在此之前加入的机器与提交且尚未结束的任务在第0个Tick加入。
+	StartTime int64
+	// EndTime 回放的结束时间，单位为秒，不包含。为0时不限制
+	EndTime int64
+	// CpuScale 归一化CPU为1时对应的核数
+	CpuScale float64
+	// MemoryScale 归一化内存为1时对应的字节数
+	MemoryScale int64
+	// PodsPerNode 每个节点能够运行的最多Pod数量
+	PodsPerNode int
+	// CoreScheduler 节点所使用的CoreScheduler
+	CoreScheduler string
+	// SchedulerName Pod所使用的调度器Profile
+	SchedulerName string
+	// MaxMachines 最多加入的节点数量，为0时不限制。用于缩小模拟的规模
+	MaxMachines int
+}
+
+// DefaultOptions 返回数据集版本的默认配置。两个版本的数据集均从第600秒开始记录。
+func DefaultOptions(version Version) *Options {
+	return &Options{
+		Version:       version,
+		TickSeconds:   1,
+		StartTime:     600,
+		CpuScale:      64,
+		MemoryScale:   256 << 30,
+		PodsPerNode:   256,
+		CoreScheduler: core.FairScheduler,
+		SchedulerName: v1.DefaultSchedulerName,
+	}
+}
+
+// NewController 读取dir目录下的数据集，构造回放集群事件的控制器。该控制器应注册为BeforeUpdate控制器。
+func NewController(sim core.SchedulerSimulator, dir string, opts *Options) (core.Controller, error) {
+	events, err := Load(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return trace.NewReplayController(sim, ControllerName, events), nil
+}
+
+// Load 读取dir目录下的数据集，并转换为按Tick排序的集群事件
+func Load(dir string, opts *Options) ([]*trace.Event, error) {
+	if opts == nil {
+		opts = DefaultOptions(Version2019)
+	}
+	if opts.TickSeconds <= 0 {
+		return nil, fmt.Errorf("TickSeconds must larger than 0")
+	}
+
+	l := &loader{opts: opts, events: make([]*trace.Event, 0, 1024)}
+
+	machineEvents, err := readMachineEvents(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading machine events")
+	}
+	taskEvents, err := readTaskEvents(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading task events")
+	}
+	usage, err := readTaskUsage(dir, opts.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading task usage")
+	}
+
+	l.addMachines(machineEvents)
+	if err = l.addTasks(taskEvents, usage); err != nil {
+		return nil, err
+	}
+
+	trace.SortEvents(l.events)
+	return l.events, nil
+}
+
+// PriorityClassName 返回数据集中优先级所对应的PriorityClass名称
+func PriorityClassName(priority int32) string {
+	return fmt.Sprintf("%s%d", PriorityClassPrefix, priority)
+}
+
+type loader struct {
+	opts   *Options
+	events []*trace.Event
+}
+
+// seconds 将微秒时间戳转换为秒
+func seconds(timestamp int64) int64 {
+	return timestamp / 1000000
+}
+
+func (l *loader) beforeEnd(timestamp int64) bool {
+	return timestamp != endOfTrace && (l.opts.EndTime == 0 || seconds(timestamp) < l.opts.EndTime)
+}
+
+// toTick 将微秒时间戳转换为Tick，早于StartTime的时间戳视为第0个Tick
+func (l *loader) toTick(timestamp int64) int {
+	sec := seconds(timestamp)
+	if sec < l.opts.StartTime {
+		return 0
+	}
+	return int((sec - l.opts.StartTime) / l.opts.TickSeconds)
+}
+
+func (l *loader) addMachines(machineEvents []*machineEvent) {
+	sort.SliceStable(machineEvents, func(i, j int) bool {
+		return machineEvents[i].time < machineEvents[j].time
+	})
+
+	added := make(map[string]bool)
+	// known 记录曾经加入过的机器，用于限制机器的数量
+	known := make(map[string]bool)
+	for _, ev := range machineEvents {
+		if !l.beforeEnd(ev.time) {
+			continue
+		}
+		name := fmt.Sprintf("machine-%s", ev.id)
+		switch ev.typ {
+		case machineAdd:
+			if added[ev.id] {
+				continue
+			}
+			if !known[ev.id] && l.opts.MaxMachines > 0 && len(known) >= l.opts.MaxMachines {
+				continue
+			}
+			cpu := int64(math.Round(ev.cpu * l.opts.CpuScale))
+			if cpu < 1 {
+				cpu = 1
+			}
+			mem := int64(ev.mem * float64(l.opts.MemoryScale))
+			added[ev.id] = true
+			known[ev.id] = true
+			l.events = append(l.events, &trace.Event{
+				Tick: l.toTick(ev.time),
+				Type: trace.NodeAdd,
+				Node: core.BuildNode(name, fmt.Sprintf("%d", cpu), fmt.Sprintf("%d", mem),
+					fmt.Sprintf("%d", l.opts.PodsPerNode), l.opts.CoreScheduler),
+			})
+		case machineRemove:
+			if !added[ev.id] {
+				continue
+			}
+			delete(added, ev.id)
+			l.events = append(l.events, &trace.Event{
+				Tick: l.toTick(ev.time),
+				Type: trace.NodeRemove,
+				Name: name,
+			})
+		}
+	}
+}
+
+// taskAttempt 任务的一次提交，从提交开始，到结束、失败或者被杀死为止
+type taskAttempt struct {
+	name     string
+	submit   int64
+	schedule int64
+	// end 结束时间，未结束时为endOfTrace
+	end      int64
+	finished bool
+	priority int32
+	cpu      float64
+	mem      float64
+	key      string
+}
+
+func (l *loader) addTasks(taskEvents []*taskEvent, usage map[string][]*usageRecord) error {
+	sort.SliceStable(taskEvents, func(i, j int) bool {
+		return taskEvents[i].time < taskEvents[j].time
+	})
+
+	attempts := make([]*taskAttempt, 0, len(taskEvents)/4)
+	running := make(map[string]*taskAttempt)
+	attemptCount := make(map[string]int)
+	for _, ev := range taskEvents {
+		key := taskKey(ev.job, ev.index)
+		attempt, alive := running[key]
+		switch ev.typ {
+		case taskSubmit:
+			// 被驱逐后重新提交时，模拟集群中的Pod仍在运行，因此忽略
+			if alive {
+				continue
+			}
+			name := fmt.Sprintf("task-%s", key)
+			if count := attemptCount[key]; count > 0 {
+				name = fmt.Sprintf("%s-%d", name, count)
+			}
+			attemptCount[key]++
+			attempt = &taskAttempt{
+				name:     name,
+				submit:   ev.time,
+				end:      endOfTrace,
+				priority: ev.priority,
+				cpu:      ev.cpu,
+				mem:      ev.mem,
+				key:      key,
+			}
+			running[key] = attempt
+			attempts = append(attempts, attempt)
+		case taskSchedule:
+			if alive && attempt.schedule == 0 {
+				attempt.schedule = ev.time
+			}
+		case taskFinish, taskFail, taskKill, taskLost:
+			if !alive {
+				continue
+			}
+			attempt.end = ev.time
+			attempt.finished = ev.typ == taskFinish
+			delete(running, key)
+		case taskUpdate:
+			if alive && ev.cpu > 0 {
+				attempt.cpu, attempt.mem = ev.cpu, ev.mem
+			}
+		}
+		// 驱逐由模拟集群的调度器决定，不予回放
+	}
+
+	priorities := make(map[int32]bool)
+	for _, attempt := range attempts {
+		if !l.beforeEnd(attempt.submit) || (attempt.end != endOfTrace && seconds(attempt.end) < l.opts.StartTime) {
+			continue
+		}
+		if !priorities[attempt.priority] {
+			priorities[attempt.priority] = true
+			l.events = append(l.events, &trace.Event{
+				Tick: 0,
+				Type: trace.PriorityClassAdd,
+				PriorityClass: &schedulingv1.PriorityClass{
+					ObjectMeta: metav1.ObjectMeta{Name: PriorityClassName(attempt.priority)},
+					Value:      attempt.priority,
+				},
+			})
+		}
+
+		cpu := attempt.cpu * l.opts.CpuScale
+		if cpu <= 0 {
+			cpu = 1
+		}
+		mem := int64(attempt.mem * float64(l.opts.MemoryScale))
+		pod, err := l.buildTaskPod(attempt, cpu, mem, usage[attempt.key])
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error building pod %s", attempt.name))
+		}
+		pod.Spec.PriorityClassName = PriorityClassName(attempt.priority)
+		l.events = append(l.events, &trace.Event{
+			Tick: l.toTick(attempt.submit),
+			Type: trace.PodSubmit,
+			Pod:  pod,
+		})
+
+		if !attempt.finished && attempt.end != endOfTrace && l.beforeEnd(attempt.end) {
+			l.events = append(l.events, &trace.Event{
+				Tick: l.toTick(attempt.end),
+				Type: trace.PodFinish,
+				Name: attempt.name,
+			})
+		}
+	}
+	return nil
+}
+
+// buildTaskPod 构造任务对应的Pod。有使用数据的任务使用TraceReplayPod回放数据集中的资源使用，否则使用BatchPod，
+// 并视为完全使用所请求的资源。
+func (l *loader) buildTaskPod(attempt *taskAttempt, cpu float64, mem int64, records []*usageRecord) (*v1.Pod, error) {
+	start := attempt.schedule
+	if start == 0 {
+		start = attempt.submit
+	}
+
+	// 只使用本次提交运行期间的使用数据，时刻从Pod加入集群时开始计算
+	startTime := start
+	if seconds(startTime) < l.opts.StartTime {
+		startTime = l.opts.StartTime * 1000000
+	}
+	samples := make([]*pods.UsageSample, 0, len(records))
+	for _, record := range records {
+		if record.time < start || record.time >= attempt.end {
+			continue
+		}
+		samples = append(samples, &pods.UsageSample{
+			Tick: float64(seconds(record.time-startTime)) / float64(l.opts.TickSeconds),
+			Cpu:  record.cpu * l.opts.CpuScale,
+			Mem:  int64(record.mem * float64(l.opts.MemoryScale)),
+		})
+	}
+	if len(samples) > 0 {
+		// 没有结束时间的任务回放到最后一个采样点为止
+		duration := float64(0)
+		if attempt.end != endOfTrace {
+			duration = float64(seconds(attempt.end-startTime)) / float64(l.opts.TickSeconds)
+		}
+		return trace.BuildPod(attempt.name, cpu, mem, pods.TraceReplayPod, ControllerName, &pods.TraceReplayPodState{
+			Samples:  samples,
+			Duration: duration,
+		}, l.opts.SchedulerName)
+	}
+
+	// 正常结束的任务在资源充足时运行与数据集中相同的时间，然后自行结束；其余任务一直运行，直到被删除
+	totalTick := float64(math.MaxInt32)
+	if attempt.finished {
+		totalTick = float64(l.toTick(attempt.end)-l.toTick(start)) * cpu
+	}
+	return trace.BuildPod(attempt.name, cpu, mem, pods.BatchPod, ControllerName, &pods.BatchPodState{
+		MemUsage:  mem,
+		TotalTick: totalTick,
+	}, l.opts.SchedulerName)
+}
@@ -0,0 +1,163 @@
+package google
+
+import (
+	"encoding/json"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/pods"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/trace"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "google")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+type expectedEvent struct {
+	tick int
+	typ  trace.EventType
+	name string
+}
+
+func checkEvents(t *testing.T, events []*trace.Event, expected []expectedEvent) {
+	if len(events) != len(expected) {
+		t.Fatalf("should have %d events, not %d", len(expected), len(events))
+	}
+	for i, ev := range events {
+		name := ev.Name
+		switch ev.Type {
+		case trace.NodeAdd:
+			name = ev.Node.Name
+		case trace.PodSubmit:
+			name = ev.Pod.Name
+		case trace.PriorityClassAdd:
+			name = ev.PriorityClass.Name
+		}
+		if ev.Tick != expected[i].tick || ev.Type != expected[i].typ || name != expected[i].name {
+			t.Errorf("event %d should be %v, not %d %s %s", i, expected[i], ev.Tick, ev.Type, name)
+		}
+	}
+}
+
+func TestLoad2011(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"machine_events/part-00000-of-00001.csv": "0,5,0,p,0.5,0.5\n" +
+			"0,6,0,p,1,1\n" +
+			"700000000,6,1,p,1,1\n",
+		"task_events/part-00000-of-00001.csv": "0,,100,0,,0,u,1,9,0.125,0.1,0,\n" +
+			"0,,100,0,5,1,u,1,9,0.125,0.1,0,\n" +
+			"650000000,,100,0,5,4,u,1,9,0.125,0.1,0,\n" +
+			"610000000,,101,0,,0,u,1,2,0.01,0.1,0,\n" +
+			"620000000,,101,0,5,1,u,1,2,0.01,0.1,0,\n" +
+			"630000000,,101,0,5,2,u,1,2,0.01,0.1,0,\n" +
+			"640000000,,101,0,,0,u,1,2,0.01,0.1,0,\n" +
+			"660000000,,101,0,5,5,u,1,2,0.01,0.1,0,\n",
+		"task_usage/part-00000-of-00001.csv": "600000000,630000000,100,0,5,0.0625,0.05\n" +
+			"630000000,660000000,100,0,5,0.125,0.05\n",
+	})
+	defer os.RemoveAll(dir)
+
+	events, err := Load(dir, DefaultOptions(Version2011))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, []expectedEvent{
+		{0, trace.NodeAdd, "machine-5"},
+		{0, trace.NodeAdd, "machine-6"},
+		{0, trace.PriorityClassAdd, PriorityClassName(9)},
+		{0, trace.PodSubmit, "task-100-0"},
+		{0, trace.PriorityClassAdd, PriorityClassName(2)},
+		{10, trace.PodSubmit, "task-101-0"},
+		{60, trace.PodFinish, "task-101-0"},
+		{100, trace.NodeRemove, "machine-6"},
+	})
+
+	node := events[0].Node
+	if cpu, _ := node.Status.Capacity.Cpu().AsInt64(); cpu != 32 {
+		t.Errorf("cpu should be 32, not %d", cpu)
+	}
+	if events[3].Pod.Spec.PriorityClassName != PriorityClassName(9) {
+		t.Errorf("wrong PriorityClassName %s", events[3].Pod.Spec.PriorityClassName)
+	}
+	if events[2].PriorityClass.Value != 9 {
+		t.Errorf("PriorityClass value should be 9")
+	}
+
+	pod := events[3].Pod
+	if pod.Annotations[core.PodAnnotationAlgorithm] != pods.TraceReplayPod {
+		t.Fatalf("task with usage should use %s, not %s", pods.TraceReplayPod, pod.Annotations[core.PodAnnotationAlgorithm])
+	}
+	state := &pods.TraceReplayPodState{}
+	if err = json.Unmarshal([]byte(pod.Annotations[core.PodAnnotationInitialState]), state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Samples) != 2 || state.Samples[1].Tick != 30 || state.Samples[1].Cpu != 8 || state.Duration != 50 {
+		t.Errorf("wrong usage samples %v, duration %f", state.Samples, state.Duration)
+	}
+	if events[5].Pod.Annotations[core.PodAnnotationAlgorithm] != pods.BatchPod {
+		t.Errorf("task without usage should use %s", pods.BatchPod)
+	}
+}
+
+func TestLoad2019(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"machine_events.json": `{"time":"0","machine_id":"42","type":1,"capacity":{"cpus":0.25,"memory":0.5}}` + "\n",
+		"instance_events.json": `{"time":"601000000","type":0,"collection_id":"7","instance_index":3,"priority":200,"resource_request":{"cpus":0.02,"memory":0.01}}` + "\n" +
+			`{"time":"603000000","type":3,"collection_id":"7","instance_index":3,"priority":200}` + "\n" +
+			`{"time":"613000000","type":6,"collection_id":"7","instance_index":3,"priority":200}` + "\n",
+	})
+	defer os.RemoveAll(dir)
+
+	events, err := Load(dir, DefaultOptions(Version2019))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, []expectedEvent{
+		{0, trace.NodeAdd, "machine-42"},
+		{0, trace.PriorityClassAdd, PriorityClassName(200)},
+		{1, trace.PodSubmit, "task-7-3"},
+	})
+}
+
+func TestReadTaskEvents2011Update(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"task_events/part-00000-of-00001.csv": "0,,100,0,,0,u,1,9,0.125,0.1,0,\n" +
+			"10,,100,0,,7,u,1,9,0.25,0.1,0,\n" +
+			"20,,100,0,5,1,u,1,9,0.25,0.1,0,\n" +
+			"30,,100,0,5,8,u,1,9,0.5,0.2,0,\n" +
+			"40,,100,0,5,9,u,1,9,0.5,0.2,0,\n",
+	})
+	defer os.RemoveAll(dir)
+
+	events, err := readTaskEvents(dir, Version2011)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []taskEventType{taskSubmit, taskUpdate, taskSchedule, taskUpdate}
+	if len(events) != len(expected) {
+		t.Fatalf("should have %d events, not %d", len(expected), len(events))
+	}
+	for i, ev := range events {
+		if ev.typ != expected[i] {
+			t.Errorf("event %d should be type %d, not %d", i, expected[i], ev.typ)
+		}
+	}
+	if events[3].cpu != 0.5 || events[3].mem != 0.2 {
+		t.Errorf("UPDATE_RUNNING should carry the new request, not cpu %f mem %f", events[3].cpu, events[3].mem)
+	}
+}
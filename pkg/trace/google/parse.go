@@ -0,0 +1,445 @@
+package google
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 数据集中各个表所在的目录或文件名（不含后缀）
+const (
+	tableMachineEvents2011 = "machine_events"
+	tableTaskEvents2011    = "task_events"
+	tableTaskUsage2011     = "task_usage"
+	tableMachineEvents2019 = "machine_events"
+	tableTaskEvents2019    = "instance_events"
+	tableTaskUsage2019     = "instance_usage"
+)
+
+// endOfTrace 数据集中代表“数据集结束之后”的时间戳
+const endOfTrace = int64(^uint64(0) >> 1)
+
+type machineEventType int
+
+const (
+	machineAdd = machineEventType(iota)
+	machineRemove
+	machineUpdate
+)
+
+type taskEventType int
+
+const (
+	taskSubmit = taskEventType(iota)
+	taskSchedule
+	taskEvict
+	taskFail
+	taskFinish
+	taskKill
+	taskLost
+	taskUpdate
+)
+
+// 2011年版本的事件类型编号。7与8分别为UPDATE_PENDING与UPDATE_RUNNING，都转换为taskUpdate
+var taskEventType2011 = map[int64]taskEventType{
+	0: taskSubmit,
+	1: taskSchedule,
+	2: taskEvict,
+	3: taskFail,
+	4: taskFinish,
+	5: taskKill,
+	6: taskLost,
+	7: taskUpdate, // UPDATE_PENDING
+	8: taskUpdate, // UPDATE_RUNNING
+}
+
+// 2019年版本的事件类型编号
+var taskEventType2019 = map[int64]taskEventType{
+	0:  taskSubmit,
+	1:  taskUpdate, // QUEUE
+	2:  taskUpdate, // ENABLE
+	3:  taskSchedule,
+	4:  taskEvict,
+	5:  taskFail,
+	6:  taskFinish,
+	7:  taskKill,
+	8:  taskLost,
+	9:  taskUpdate,
+	10: taskUpdate,
+}
+
+type machineEvent struct {
+	// time 单位为微秒
+	time int64
+	id   string
+	typ  machineEventType
+	// cpu 与mem均为归一化的值，取值0～1
+	cpu float64
+	mem float64
+}
+
+type taskEvent struct {
+	time     int64
+	job      string
+	index    int64
+	typ      taskEventType
+	priority int32
+	cpu      float64
+	mem      float64
+}
+
+// usageRecord 任务在一个测量窗口内的平均资源使用情况
+type usageRecord struct {
+	// time 测量窗口的开始时间
+	time int64
+	// cpu 与mem均为归一化的值
+	cpu float64
+	mem float64
+}
+
+// openTable 打开表的所有分片文件，支持gzip压缩。表可以是一个目录，也可以是单个文件。
+func openTable(dir, table string) ([]string, error) {
+	files := make([]string, 0, 16)
+	for _, pattern := range []string{filepath.Join(dir, table, "*"), filepath.Join(dir, table+".*")} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readLines(dir, table string, handle func(reader io.Reader) error) error {
+	files, err := openTable(dir, table)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		err = func() error {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			var reader io.Reader = file
+			if strings.HasSuffix(path, ".gz") {
+				gz, err := gzip.NewReader(file)
+				if err != nil {
+					return err
+				}
+				defer gz.Close()
+				reader = gz
+			}
+			return handle(reader)
+		}()
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error reading %s", path))
+		}
+	}
+	return nil
+}
+
+// readCSVTable 读取2011年版本的CSV表
+func readCSVTable(dir, table string, handle func(record []string) error) error {
+	return readLines(dir, table, func(reader io.Reader) error {
+		csvReader := csv.NewReader(reader)
+		csvReader.FieldsPerRecord = -1
+		csvReader.ReuseRecord = true
+		for line := 1; ; line++ {
+			record, err := csvReader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, fmt.Sprintf("line %d", line))
+			}
+			if err = handle(record); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("line %d", line))
+			}
+		}
+	})
+}
+
+// readJSONTable 读取2019年版本从BigQuery导出的JSON Lines表
+func readJSONTable(dir, table string, newRecord func() interface{}, handle func(record interface{}) error) error {
+	return readLines(dir, table, func(reader io.Reader) error {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			record := newRecord()
+			if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("line %d", line))
+			}
+			if err := handle(record); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("line %d", line))
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+func field(record []string, idx int) string {
+	if idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
+
+// 数据集中存在缺失值，缺失值均视为0
+func parseFloat(record []string, idx int) (float64, error) {
+	s := field(record, idx)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseInt(record []string, idx int) (int64, error) {
+	s := field(record, idx)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// jsonInt BigQuery导出的INT64字段使用字符串表示，此类型同时接受字符串与数字
+type jsonInt int64
+
+func (i *jsonInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), "\"")
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = jsonInt(v)
+	return nil
+}
+
+type jsonResources struct {
+	Cpus   float64 `json:"cpus"`
+	Memory float64 `json:"memory"`
+}
+
+type jsonMachineEvent struct {
+	Time      jsonInt        `json:"time"`
+	MachineId jsonInt        `json:"machine_id"`
+	Type      jsonInt        `json:"type"`
+	Capacity  *jsonResources `json:"capacity"`
+}
+
+type jsonInstanceEvent struct {
+	Time            jsonInt        `json:"time"`
+	Type            jsonInt        `json:"type"`
+	CollectionId    jsonInt        `json:"collection_id"`
+	InstanceIndex   jsonInt        `json:"instance_index"`
+	Priority        jsonInt        `json:"priority"`
+	ResourceRequest *jsonResources `json:"resource_request"`
+}
+
+type jsonInstanceUsage struct {
+	StartTime     jsonInt        `json:"start_time"`
+	CollectionId  jsonInt        `json:"collection_id"`
+	InstanceIndex jsonInt        `json:"instance_index"`
+	AverageUsage  *jsonResources `json:"average_usage"`
+}
+
+func readMachineEvents(dir string, version Version) ([]*machineEvent, error) {
+	events := make([]*machineEvent, 0, 1024)
+	switch version {
+	case Version2011:
+		// timestamp,machine ID,event type,platform ID,CPUs,Memory
+		err := readCSVTable(dir, tableMachineEvents2011, func(record []string) error {
+			ts, err := parseInt(record, 0)
+			if err != nil {
+				return err
+			}
+			typ, err := parseInt(record, 2)
+			if err != nil {
+				return err
+			}
+			cpu, err := parseFloat(record, 4)
+			if err != nil {
+				return err
+			}
+			mem, err := parseFloat(record, 5)
+			if err != nil {
+				return err
+			}
+			events = append(events, &machineEvent{
+				time: ts,
+				id:   field(record, 1),
+				typ:  machineEventType(typ),
+				cpu:  cpu,
+				mem:  mem,
+			})
+			return nil
+		})
+		return events, err
+	case Version2019:
+		err := readJSONTable(dir, tableMachineEvents2019, func() interface{} {
+			return &jsonMachineEvent{}
+		}, func(record interface{}) error {
+			ev := record.(*jsonMachineEvent)
+			// 2019年版本中1为ADD，2为REMOVE，3为UPDATE
+			if ev.Type < 1 || ev.Type > 3 {
+				return nil
+			}
+			me := &machineEvent{
+				time: int64(ev.Time),
+				id:   strconv.FormatInt(int64(ev.MachineId), 10),
+				typ:  machineEventType(ev.Type - 1),
+			}
+			if ev.Capacity != nil {
+				me.cpu, me.mem = ev.Capacity.Cpus, ev.Capacity.Memory
+			}
+			events = append(events, me)
+			return nil
+		})
+		return events, err
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+}
+
+func readTaskEvents(dir string, version Version) ([]*taskEvent, error) {
+	events := make([]*taskEvent, 0, 1024)
+	switch version {
+	case Version2011:
+		// timestamp,missing info,job ID,task index,machine ID,event type,user,scheduling class,priority,
+		// CPU request,memory request,disk space request,different machines restriction
+		err := readCSVTable(dir, tableTaskEvents2011, func(record []string) error {
+			ints := make([]int64, 4)
+			for i, col := range []int{0, 3, 5, 8} {
+				v, err := parseInt(record, col)
+				if err != nil {
+					return errors.Wrap(err, fmt.Sprintf("invalid column %d", col))
+				}
+				ints[i] = v
+			}
+			cpu, err := parseFloat(record, 9)
+			if err != nil {
+				return err
+			}
+			mem, err := parseFloat(record, 10)
+			if err != nil {
+				return err
+			}
+			typ, ok := taskEventType2011[ints[2]]
+			if !ok {
+				return nil
+			}
+			events = append(events, &taskEvent{
+				time:     ints[0],
+				job:      field(record, 2),
+				index:    ints[1],
+				typ:      typ,
+				priority: int32(ints[3]),
+				cpu:      cpu,
+				mem:      mem,
+			})
+			return nil
+		})
+		return events, err
+	case Version2019:
+		err := readJSONTable(dir, tableTaskEvents2019, func() interface{} {
+			return &jsonInstanceEvent{}
+		}, func(record interface{}) error {
+			ev := record.(*jsonInstanceEvent)
+			typ, ok := taskEventType2019[int64(ev.Type)]
+			if !ok {
+				return nil
+			}
+			te := &taskEvent{
+				time:     int64(ev.Time),
+				job:      strconv.FormatInt(int64(ev.CollectionId), 10),
+				index:    int64(ev.InstanceIndex),
+				typ:      typ,
+				priority: int32(ev.Priority),
+			}
+			if ev.ResourceRequest != nil {
+				te.cpu, te.mem = ev.ResourceRequest.Cpus, ev.ResourceRequest.Memory
+			}
+			events = append(events, te)
+			return nil
+		})
+		return events, err
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+}
+
+// readTaskUsage 读取任务在各个测量窗口的资源使用情况，键为taskKey
+func readTaskUsage(dir string, version Version) (map[string][]*usageRecord, error) {
+	usage := make(map[string][]*usageRecord)
+	add := func(job string, index int64, time int64, cpu, mem float64) {
+		key := taskKey(job, index)
+		usage[key] = append(usage[key], &usageRecord{
+			time: time,
+			cpu:  cpu,
+			mem:  mem,
+		})
+	}
+	switch version {
+	case Version2011:
+		// start time,end time,job ID,task index,machine ID,CPU rate,canonical memory usage,...
+		err := readCSVTable(dir, tableTaskUsage2011, func(record []string) error {
+			start, err := parseInt(record, 0)
+			if err != nil {
+				return err
+			}
+			index, err := parseInt(record, 3)
+			if err != nil {
+				return err
+			}
+			cpu, err := parseFloat(record, 5)
+			if err != nil {
+				return err
+			}
+			mem, err := parseFloat(record, 6)
+			if err != nil {
+				return err
+			}
+			add(field(record, 2), index, start, cpu, mem)
+			return nil
+		})
+		return usage, err
+	case Version2019:
+		err := readJSONTable(dir, tableTaskUsage2019, func() interface{} {
+			return &jsonInstanceUsage{}
+		}, func(record interface{}) error {
+			u := record.(*jsonInstanceUsage)
+			if u.AverageUsage == nil {
+				return nil
+			}
+			add(strconv.FormatInt(int64(u.CollectionId), 10), int64(u.InstanceIndex), int64(u.StartTime),
+				u.AverageUsage.Cpus, u.AverageUsage.Memory)
+			return nil
+		})
+		return usage, err
+	default:
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+}
+
+func taskKey(job string, index int64) string {
+	return fmt.Sprintf("%s-%d", job, index)
+}
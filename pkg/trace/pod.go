@@ -0,0 +1,31 @@
+package trace
+
+import (
+	"fmt"
+	"github.com/packagewjx/k8s-scheduler-sim/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// BuildPod 使用core.BuildV1Pod构造Pod，并加入一个请求cpu核与mem字节的容器，使得调度器能够根据数据集中的资源请求调度
+// 该Pod。
+func BuildPod(name string, cpu float64, mem int64, algorithm string, deploymentController string, initState interface{}, schedulerName string) (*v1.Pod, error) {
+	pod, err := core.BuildV1Pod(name, cpu, int(mem), algorithm, deploymentController, initState, schedulerName)
+	if err != nil {
+		return nil, err
+	}
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(fmt.Sprintf("%dm", int64(cpu*1000))),
+		v1.ResourceMemory: *resource.NewQuantity(mem, resource.BinarySI),
+	}
+	pod.Spec.Containers = []v1.Container{
+		{
+			Name: name,
+			Resources: v1.ResourceRequirements{
+				Limits:   resources,
+				Requests: resources.DeepCopy(),
+			},
+		},
+	}
+	return pod, nil
+}
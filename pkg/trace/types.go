@@ -0,0 +1,46 @@
+// trace 提供根据真实集群数据回放集群事件的通用工具。各个数据集的解析位于子包中，解析的结果统一转换为Event，交给
+// 回放控制器在指定的Tick提交到模拟集群。
+package trace
+
+import (
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"sort"
+)
+
+type EventType string
+
+const (
+	// NodeAdd 向集群加入新的节点
+	NodeAdd = EventType("NodeAdd")
+	// NodeRemove 从集群中删除节点
+	NodeRemove = EventType("NodeRemove")
+	// PodSubmit 提交新的Pod
+	PodSubmit = EventType("PodSubmit")
+	// PodFinish 数据集中Pod结束运行，需要删除该Pod
+	PodFinish = EventType("PodFinish")
+	// PriorityClassAdd 创建数据集中Pod所使用的PriorityClass
+	PriorityClassAdd = EventType("PriorityClassAdd")
+)
+
+// Event 数据集中在某一Tick发生的事件
+type Event struct {
+	// Tick 事件发生的时钟周期，从0开始
+	Tick int
+	Type EventType
+	// Node NodeAdd事件所创建的节点
+	Node *v1.Node
+	// Pod PodSubmit事件所提交的Pod
+	Pod *v1.Pod
+	// PriorityClass PriorityClassAdd事件所创建的PriorityClass
+	PriorityClass *schedulingv1.PriorityClass
+	// Name NodeRemove与PodFinish事件所删除的对象的名称
+	Name string
+}
+
+// SortEvents 将事件按照Tick排序，同一Tick内保持原有顺序
+func SortEvents(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Tick < events[j].Tick
+	})
+}
@@ -55,14 +55,16 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-func NewFakeKubernetesInterface() kubernetes.Interface {
-	_ = util.GetMessageQueue().NewTopic(util.TopicPod)
-	_ = util.GetMessageQueue().NewTopic(util.TopicNode)
-	return &fakeKubernetesInterface{}
+// NewFakeKubernetesInterface 构造将资源的变化发布到queue的kubernetes.Interface，queue应该与Informer订阅的消息队列相同
+func NewFakeKubernetesInterface(queue util.MessageQueue) kubernetes.Interface {
+	_ = queue.NewTopic(util.TopicPod)
+	_ = queue.NewTopic(util.TopicNode)
+	return &fakeKubernetesInterface{queue: queue}
 }
 
 // 无需任何依赖的kubernetes.Interface，主要测试各个Informer的功能
 type fakeKubernetesInterface struct {
+	queue util.MessageQueue
 }
 
 func (f *fakeKubernetesInterface) RESTClient() rest.Interface {
@@ -214,7 +216,7 @@ func (f *fakeKubernetesInterface) CoordinationV1() coordinationv1.CoordinationV1
 }
 
 func (f *fakeKubernetesInterface) CoreV1() corev1.CoreV1Interface {
-	return &fakeCoreV1Interface{}
+	return &fakeCoreV1Interface{queue: f.queue}
 }
 
 func (f *fakeKubernetesInterface) DiscoveryV1alpha1() discoveryv1alpha1.DiscoveryV1alpha1Interface {
@@ -298,6 +300,7 @@ func (f *fakeKubernetesInterface) StorageV1alpha1() storagev1alpha1.StorageV1alp
 }
 
 type fakeCoreV1Interface struct {
+	queue util.MessageQueue
 }
 
 func (f *fakeCoreV1Interface) RESTClient() rest.Interface {
@@ -329,7 +332,7 @@ func (f *fakeCoreV1Interface) Namespaces() corev1.NamespaceInterface {
 }
 
 func (f *fakeCoreV1Interface) Nodes() corev1.NodeInterface {
-	return &fakeNodeInterface{}
+	return &fakeNodeInterface{queue: f.queue}
 }
 
 func (f *fakeCoreV1Interface) PersistentVolumes() corev1.PersistentVolumeInterface {
@@ -341,7 +344,7 @@ func (f *fakeCoreV1Interface) PersistentVolumeClaims(_ string) corev1.Persistent
 }
 
 func (f *fakeCoreV1Interface) Pods(_ string) corev1.PodInterface {
-	return &fakePodInterface{}
+	return &fakePodInterface{queue: f.queue}
 }
 
 func (f *fakeCoreV1Interface) PodTemplates(_ string) corev1.PodTemplateInterface {
@@ -369,6 +372,7 @@ func (f *fakeCoreV1Interface) ServiceAccounts(_ string) corev1.ServiceAccountInt
 }
 
 type fakeNodeInterface struct {
+	queue util.MessageQueue
 }
 
 func (f *fakeNodeInterface) Create(_ context.Context, node *v1.Node, _ apimachineryv1.CreateOptions) (*v1.Node, error) {
@@ -376,7 +380,7 @@ func (f *fakeNodeInterface) Create(_ context.Context, node *v1.Node, _ apimachin
 		Type:   watch.Added,
 		Object: node,
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicNode, addEvent)
+	_ = f.queue.Publish(util.TopicNode, addEvent)
 	return node, nil
 }
 
@@ -385,7 +389,7 @@ func (f *fakeNodeInterface) Update(_ context.Context, node *v1.Node, _ apimachin
 		Type:   watch.Modified,
 		Object: node,
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicNode, updateEvent)
+	_ = f.queue.Publish(util.TopicNode, updateEvent)
 	return node, nil
 }
 
@@ -394,7 +398,7 @@ func (f *fakeNodeInterface) UpdateStatus(_ context.Context, node *v1.Node, _ api
 		Type:   watch.Modified,
 		Object: node,
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicNode, updateEvent)
+	_ = f.queue.Publish(util.TopicNode, updateEvent)
 	return node, nil
 }
 
@@ -403,7 +407,7 @@ func (f *fakeNodeInterface) Delete(_ context.Context, _ string, _ apimachineryv1
 		Type:   watch.Deleted,
 		Object: &v1.Node{},
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicNode, deleteEvent)
+	_ = f.queue.Publish(util.TopicNode, deleteEvent)
 	return nil
 }
 
@@ -426,7 +430,7 @@ func (f *fakeNodeInterface) List(_ context.Context, _ apimachineryv1.ListOptions
 }
 
 func (f *fakeNodeInterface) Watch(_ context.Context, _ apimachineryv1.ListOptions) (watch.Interface, error) {
-	return util.GetMessageQueue().Subscribe(util.TopicNode)
+	return f.queue.Subscribe(util.TopicNode)
 }
 
 func (f *fakeNodeInterface) Patch(_ context.Context, _ string, _ types.PatchType, _ []byte, _ apimachineryv1.PatchOptions, _ ...string) (result *v1.Node, err error) {
@@ -438,6 +442,7 @@ func (f *fakeNodeInterface) PatchStatus(_ context.Context, _ string, _ []byte) (
 }
 
 type fakePodInterface struct {
+	queue util.MessageQueue
 }
 
 func (f *fakePodInterface) Create(_ context.Context, pod *v1.Pod, _ apimachineryv1.CreateOptions) (*v1.Pod, error) {
@@ -445,7 +450,7 @@ func (f *fakePodInterface) Create(_ context.Context, pod *v1.Pod, _ apimachinery
 		Type:   watch.Added,
 		Object: pod,
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	_ = f.queue.Publish(util.TopicPod, ev)
 	return pod, nil
 }
 
@@ -454,7 +459,7 @@ func (f *fakePodInterface) Update(_ context.Context, pod *v1.Pod, _ apimachinery
 		Type:   watch.Modified,
 		Object: pod,
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	_ = f.queue.Publish(util.TopicPod, ev)
 	return pod, nil
 }
 
@@ -471,7 +476,7 @@ func (f *fakePodInterface) Delete(_ context.Context, name string, _ apimachinery
 			},
 		},
 	}
-	_ = util.GetMessageQueue().Publish(util.TopicPod, ev)
+	_ = f.queue.Publish(util.TopicPod, ev)
 	return nil
 }
 
@@ -484,11 +489,11 @@ func (f *fakePodInterface) Get(_ context.Context, _ string, _ apimachineryv1.Get
 }
 
 func (f *fakePodInterface) List(_ context.Context, _ apimachineryv1.ListOptions) (*v1.PodList, error) {
-	panic("implement me")
+	return &v1.PodList{Items: []v1.Pod{}}, nil
 }
 
 func (f *fakePodInterface) Watch(_ context.Context, _ apimachineryv1.ListOptions) (watch.Interface, error) {
-	return util.GetMessageQueue().Subscribe(util.TopicPod)
+	return f.queue.Subscribe(util.TopicPod)
 }
 
 func (f *fakePodInterface) Patch(_ context.Context, _ string, _ types.PatchType, _ []byte, _ apimachineryv1.PatchOptions, _ ...string) (result *v1.Pod, err error) {
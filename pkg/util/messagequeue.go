@@ -14,6 +14,8 @@ const (
 	TopicPriorityClass = "priorityClass"
 )
 
+// MessageQueue 资源变化事件的消息队列。每个模拟器拥有自己的消息队列，客户端将资源的变化发布到其中，Informer从中订阅，
+// 因此同一进程中的多个模拟器互不影响
 type MessageQueue interface {
 	// NewTopic 创建一个新的沟通话题，让订阅者和发布者进行沟通
 	NewTopic(topic string) error
@@ -27,16 +29,6 @@ type MessageQueue interface {
 	Shutdown()
 }
 
-var queue MessageQueue
-
-func init() {
-	queue = NewSynchronizedMessageQueue()
-}
-
-func GetMessageQueue() MessageQueue {
-	return queue
-}
-
 var _ MessageQueue = &synchronizedMessageQueue{}
 
 type watcher struct {
@@ -9,7 +9,7 @@ import (
 )
 
 func TestQueue(t *testing.T) {
-	messageQueue := GetMessageQueue()
+	messageQueue := NewSynchronizedMessageQueue()
 	recvTimes := 10
 
 	topics := []string{"Animation", "Comics", "Game"}